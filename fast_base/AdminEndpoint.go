@@ -0,0 +1,48 @@
+package fast_base
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 管理端扩展接口：fast_db 等模块注册，fast_web 在管理端口的 /admin 下提供，与内置管理接口使用相同的鉴权。
+
+// AdminHandler 管理端扩展接口，params 为查询参数，返回值以统一格式输出
+type AdminHandler func(ctx context.Context, params url.Values) (any, error)
+
+var adminLock sync.RWMutex
+var adminEndpoints = map[string]AdminHandler{}
+
+func adminEndpointKey(method, path string) string {
+	return strings.ToUpper(method) + " /" + strings.Trim(path, "/")
+}
+
+// RegisterAdminEndpoint 注册管理端接口，path 相对于 /admin，如 /db/sqlStats。同名覆盖
+func RegisterAdminEndpoint(method, path string, handler AdminHandler) {
+	adminLock.Lock()
+	defer adminLock.Unlock()
+	adminEndpoints[adminEndpointKey(method, path)] = handler
+}
+
+// FindAdminEndpoint 查找管理端接口，path 相对于 /admin
+func FindAdminEndpoint(method, path string) (AdminHandler, bool) {
+	adminLock.RLock()
+	defer adminLock.RUnlock()
+	h, ok := adminEndpoints[adminEndpointKey(method, path)]
+	return h, ok
+}
+
+// AdminEndpoints 已注册的管理端接口，格式为 "GET /db/sqlStats"
+func AdminEndpoints() []string {
+	adminLock.RLock()
+	defer adminLock.RUnlock()
+	list := make([]string, 0, len(adminEndpoints))
+	for k := range adminEndpoints {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
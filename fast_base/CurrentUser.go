@@ -0,0 +1,26 @@
+package fast_base
+
+import "context"
+
+// CurrentUser 当前登录用户，由 fast_web 的令牌中间件放入请求 context，
+// fast_db 据此填写 CreatedBy、UpdatedBy 等审计字段，两者不必互相依赖。
+type CurrentUser struct {
+	UserId int64
+	AppKey string
+}
+
+type currentUserKey struct{}
+
+// WithCurrentUser 把当前用户放入 context
+func WithCurrentUser(ctx context.Context, user CurrentUser) context.Context {
+	return context.WithValue(ctx, currentUserKey{}, user)
+}
+
+// CurrentUserFrom 获取 context 中的当前用户
+func CurrentUserFrom(ctx context.Context) (CurrentUser, bool) {
+	if ctx == nil {
+		return CurrentUser{}, false
+	}
+	user, ok := ctx.Value(currentUserKey{}).(CurrentUser)
+	return user, ok
+}
@@ -0,0 +1,21 @@
+package fast_base
+
+import "context"
+
+// 数据权限：fast_web 按接口注解(@dataScope)把权限码放入请求 context，fast_db 据此为列表查询追加数据范围条件。
+
+type dataScopeKey struct{}
+
+// WithDataScope 把数据权限码放入 context
+func WithDataScope(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, dataScopeKey{}, code)
+}
+
+// DataScopeFrom 获取 context 中的数据权限码
+func DataScopeFrom(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	code, ok := ctx.Value(dataScopeKey{}).(string)
+	return code, ok && code != ""
+}
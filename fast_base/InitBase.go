@@ -10,6 +10,9 @@ import (
 var Logger *zap.Logger
 var LoggerLevel zapcore.Level
 
+// LoggerAtomicLevel 日志核心使用的动态级别，可通过 SetLogLevel 在运行时修改
+var LoggerAtomicLevel = zap.NewAtomicLevel()
+
 // ConfigAll 存储所有配置
 var ConfigAll *viper.Viper
 
@@ -0,0 +1,175 @@
+package fast_base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 健康检查注册中心：各模块(数据库、令牌、代理等)注册检查项，由 fast_web 通过 /healthz、/readyz 对外暴露。
+// 放在 fast_base 中，避免 fast_db 与 fast_web 互相依赖。
+
+// ConfigHealth 健康检查相关配置
+var ConfigHealth = HealthConfig{Enable: true, Timeout: 3, CacheTtl: 2, DiskMinFreeMb: 100}
+
+type HealthConfig struct {
+	Enable        bool
+	Timeout       time.Duration // 单个检查项的超时时间，单位秒
+	CacheTtl      time.Duration // 检查结果缓存时间，单位秒，避免探针频繁访问数据库
+	DiskMinFreeMb uint64        // 日志目录所在磁盘的最小剩余空间，单位MB
+}
+
+// HealthKind 检查项类型
+type HealthKind int
+
+const (
+	// HealthLiveness 存活检查，失败说明进程需要重启，同时计入 /healthz 和 /readyz
+	HealthLiveness HealthKind = iota
+	// HealthReadiness 就绪检查，失败说明暂时不应接收流量，只计入 /readyz
+	HealthReadiness
+)
+
+const (
+	HealthUp   = "UP"
+	HealthDown = "DOWN"
+)
+
+// HealthCheck 检查函数，返回 nil 表示正常。ctx 已带超时
+type HealthCheck func(ctx context.Context) error
+
+type HealthResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type HealthReport struct {
+	Status string         `json:"status"`
+	Checks []HealthResult `json:"checks"`
+}
+
+func (r HealthReport) IsUp() bool {
+	return r.Status == HealthUp
+}
+
+type healthEntry struct {
+	name  string
+	kind  HealthKind
+	check HealthCheck
+
+	mu     sync.Mutex
+	last   HealthResult
+	lastAt time.Time
+}
+
+var healthLock sync.RWMutex
+var healthEntries = map[string]*healthEntry{}
+
+// RegisterHealthCheck 注册检查项，同名覆盖
+func RegisterHealthCheck(name string, kind HealthKind, check HealthCheck) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	healthEntries[name] = &healthEntry{name: name, kind: kind, check: check}
+}
+
+// UnregisterHealthCheck 移除检查项
+func UnregisterHealthCheck(name string) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	delete(healthEntries, name)
+}
+
+// CheckHealth 并发执行指定类型的检查项并汇总。readiness 包含所有检查项，liveness 只包含存活检查
+func CheckHealth(ctx context.Context, kind HealthKind) HealthReport {
+	healthLock.RLock()
+	entries := make([]*healthEntry, 0, len(healthEntries))
+	for _, e := range healthEntries {
+		if kind == HealthReadiness || e.kind == HealthLiveness {
+			entries = append(entries, e)
+		}
+	}
+	healthLock.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	report := HealthReport{Status: HealthUp, Checks: make([]HealthResult, len(entries))}
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *healthEntry) {
+			defer wg.Done()
+			report.Checks[i] = e.run(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		if r.Status != HealthUp {
+			report.Status = HealthDown
+			break
+		}
+	}
+	return report
+}
+
+func (e *healthEntry) run(ctx context.Context) HealthResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.lastAt.IsZero() && time.Since(e.lastAt) < ConfigHealth.CacheTtl*time.Second {
+		return e.last
+	}
+
+	timeout := ConfigHealth.Timeout * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- e.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("检查超时")
+	}
+
+	result := HealthResult{Name: e.name, Status: HealthUp, Duration: time.Since(start).Truncate(time.Microsecond).String()}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+	e.last = result
+	e.lastAt = time.Now()
+	return result
+}
+
+// LoadHealth 加载健康检查配置，并注册日志目录磁盘空间检查
+func LoadHealth() {
+	if ConfigAll != nil {
+		ConfigAll.UnmarshalKey("health", &ConfigHealth)
+	}
+	RegisterHealthCheck("disk", HealthReadiness, func(ctx context.Context) error {
+		free, err := diskFreeBytes(ConfigLog.Path)
+		if err != nil {
+			return err
+		}
+		if free >= 0 && uint64(free) < ConfigHealth.DiskMinFreeMb*1024*1024 {
+			return fmt.Errorf("日志目录 %s 剩余空间不足: %dMB", ConfigLog.Path, free/1024/1024)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package fast_base
+
+// diskFreeBytes 非 unix 平台暂不检查磁盘空间，返回 -1 表示未知
+func diskFreeBytes(path string) (int64, error) {
+	return -1, nil
+}
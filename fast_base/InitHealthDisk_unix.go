@@ -0,0 +1,14 @@
+//go:build unix
+
+package fast_base
+
+import "syscall"
+
+// diskFreeBytes 返回 path 所在磁盘对当前用户可用的空间
+func diskFreeBytes(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
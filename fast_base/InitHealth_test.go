@@ -0,0 +1,58 @@
+package fast_base
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckHealthSeparatesLivenessAndReadiness(t *testing.T) {
+	t.Cleanup(func() {
+		UnregisterHealthCheck("test.live")
+		UnregisterHealthCheck("test.ready")
+	})
+	RegisterHealthCheck("test.live", HealthLiveness, func(context.Context) error { return nil })
+	RegisterHealthCheck("test.ready", HealthReadiness, func(context.Context) error { return errors.New("db down") })
+
+	if report := CheckHealth(context.Background(), HealthLiveness); !report.IsUp() || len(report.Checks) != 1 {
+		t.Fatalf("liveness must ignore readiness checks: %#v", report)
+	}
+	report := CheckHealth(context.Background(), HealthReadiness)
+	if report.IsUp() || len(report.Checks) != 2 || report.Checks[1].Error != "db down" {
+		t.Fatalf("unexpected readiness report: %#v", report)
+	}
+}
+
+func TestCheckHealthTimesOutAndCaches(t *testing.T) {
+	previous := ConfigHealth
+	ConfigHealth.Timeout = 1
+	ConfigHealth.CacheTtl = 60
+	t.Cleanup(func() {
+		ConfigHealth = previous
+		UnregisterHealthCheck("test.slow")
+	})
+
+	var calls atomic.Int32
+	RegisterHealthCheck("test.slow", HealthLiveness, func(ctx context.Context) error {
+		calls.Add(1)
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	start := time.Now()
+	if report := CheckHealth(context.Background(), HealthLiveness); report.IsUp() {
+		t.Fatalf("slow check should time out: %#v", report)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatal("timeout was not enforced")
+	}
+	CheckHealth(context.Background(), HealthLiveness)
+	if calls.Load() != 1 {
+		t.Fatalf("result should be cached, got %d calls", calls.Load())
+	}
+}
@@ -28,7 +28,8 @@ func LoadLogger() error {
 		CoreLoggerLevel = LogLevelMap["info"]
 	}
 
-	core := zapcore.NewCore(encoder, writeSyncer, CoreLoggerLevel)
+	LoggerAtomicLevel.SetLevel(CoreLoggerLevel)
+	core := zapcore.NewCore(encoder, writeSyncer, LoggerAtomicLevel)
 
 	logger := zap.New(core, zap.AddCaller()) // zap.Addcaller() 输出日志打印文件和行数如： logger/logger_test.go:33
 	// 1. zap.ReplaceGlobals 函数将当前初始化的 logger 替换到全局的 logger,
@@ -43,6 +44,17 @@ func LoadLogger() error {
 	return nil
 }
 
+// SetLogLevel 运行时调整日志级别，无需重启服务。level 取值同配置文件：debug info warn error
+func SetLogLevel(level string) error {
+	l, ok := LogLevelMap[level]
+	if !ok {
+		return fmt.Errorf("不支持的日志级别: %s", level)
+	}
+	LoggerAtomicLevel.SetLevel(l)
+	ConfigLog.Level = level
+	return nil
+}
+
 // getEncoder 编码器(如何写入日志)
 func getEncoder(conf LogConfig) zapcore.Encoder {
 	encoderConfig := zap.NewProductionEncoderConfig()
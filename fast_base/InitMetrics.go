@@ -0,0 +1,284 @@
+package fast_base
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 轻量的指标注册中心，输出 Prometheus 文本格式(text/plain; version=0.0.4)。
+// 各模块在这里注册指标或采集函数，由 fast_web 通过 /metrics 暴露，避免 fast_db 依赖 fast_web。
+
+// ConfigMetrics 指标相关配置
+var ConfigMetrics = MetricsConfig{Enable: true, Path: "/metrics"}
+
+type MetricsConfig struct {
+	Enable bool
+	Path   string
+}
+
+// DefaultBuckets 耗时直方图默认分桶，单位秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+type metricSeries struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // 直方图各分桶计数(非累计)
+	sum         float64
+	count       uint64
+}
+
+type metricVec struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*metricSeries
+}
+
+// CounterVec 只增不减的计数器
+type CounterVec struct{ vec *metricVec }
+
+// GaugeVec 可增可减的瞬时值
+type GaugeVec struct{ vec *metricVec }
+
+// HistogramVec 分桶统计，通常用于耗时
+type HistogramVec struct{ vec *metricVec }
+
+// MetricsCollector 采集函数，每次输出指标时调用，适合连接池状态等拉取型数据
+type MetricsCollector func(w *MetricsWriter)
+
+var metricsLock sync.RWMutex
+var metricsVecs = map[string]*metricVec{}
+var metricsCollectors = map[string]MetricsCollector{}
+
+func registerMetric(name, help, kind string, buckets []float64, labelNames []string) *metricVec {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	// 重复注册返回已有指标，模块可被重复加载
+	if v, ok := metricsVecs[name]; ok {
+		return v
+	}
+	v := &metricVec{name: name, help: help, kind: kind, buckets: buckets, labelNames: labelNames, series: map[string]*metricSeries{}}
+	metricsVecs[name] = v
+	return v
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{registerMetric(name, help, metricCounter, nil, labelNames)}
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{registerMetric(name, help, metricGauge, nil, labelNames)}
+}
+
+// NewHistogramVec buckets 为空时使用 DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{registerMetric(name, help, metricHistogram, buckets, labelNames)}
+}
+
+// RegisterMetricsCollector 注册采集函数，同名覆盖
+func RegisterMetricsCollector(name string, collector MetricsCollector) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metricsCollectors[name] = collector
+}
+
+func (v *metricVec) with(labelValues []string, update func(s *metricSeries)) {
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: append([]string(nil), labelValues...)}
+		if v.kind == metricHistogram {
+			s.counts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	update(s)
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.vec.with(labelValues, func(s *metricSeries) { s.value += delta })
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *metricSeries) { s.value = value })
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *metricSeries) { s.value += delta })
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.vec.with(labelValues, func(s *metricSeries) {
+		for i, b := range h.vec.buckets {
+			if value <= b {
+				s.counts[i]++
+				break
+			}
+		}
+		s.sum += value
+		s.count++
+	})
+}
+
+// MetricsWriter 指标输出器
+type MetricsWriter struct {
+	w       *bufio.Writer
+	written map[string]bool
+}
+
+// Gauge 供采集函数输出瞬时值，labels 按 key, value 成对传入
+func (m *MetricsWriter) Gauge(name, help string, value float64, labels ...string) {
+	m.sample(name, help, metricGauge, value, labels...)
+}
+
+// Counter 供采集函数输出累计值，labels 按 key, value 成对传入
+func (m *MetricsWriter) Counter(name, help string, value float64, labels ...string) {
+	m.sample(name, help, metricCounter, value, labels...)
+}
+
+func (m *MetricsWriter) sample(name, help, kind string, value float64, labels ...string) {
+	m.header(name, help, kind)
+	m.line(name, labels, value)
+}
+
+func (m *MetricsWriter) header(name, help, kind string) {
+	if m.written[name] {
+		return
+	}
+	m.written[name] = true
+	m.w.WriteString("# HELP " + name + " " + strings.ReplaceAll(help, "\n", " ") + "\n")
+	m.w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func (m *MetricsWriter) line(name string, labels []string, value float64) {
+	m.w.WriteString(name)
+	if len(labels) > 1 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			m.w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(formatMetricValue(value))
+	m.w.WriteByte('\n')
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出所有指标
+func WriteMetrics(out io.Writer) error {
+	metricsLock.RLock()
+	vecs := make([]*metricVec, 0, len(metricsVecs))
+	for _, v := range metricsVecs {
+		vecs = append(vecs, v)
+	}
+	collectorNames := make([]string, 0, len(metricsCollectors))
+	for name := range metricsCollectors {
+		collectorNames = append(collectorNames, name)
+	}
+	collectors := make([]MetricsCollector, 0, len(collectorNames))
+	sort.Strings(collectorNames)
+	for _, name := range collectorNames {
+		collectors = append(collectors, metricsCollectors[name])
+	}
+	metricsLock.RUnlock()
+	sort.Slice(vecs, func(i, j int) bool { return vecs[i].name < vecs[j].name })
+
+	m := &MetricsWriter{w: bufio.NewWriter(out), written: map[string]bool{}}
+	for _, v := range vecs {
+		v.write(m)
+	}
+	for _, c := range collectors {
+		c(m)
+	}
+	return m.w.Flush()
+}
+
+func (v *metricVec) write(m *MetricsWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m.header(v.name, v.help, v.kind)
+	for _, k := range keys {
+		s := v.series[k]
+		labels := make([]string, 0, len(v.labelNames)*2+2)
+		for i, name := range v.labelNames {
+			value := ""
+			if i < len(s.labelValues) {
+				value = s.labelValues[i]
+			}
+			labels = append(labels, name, value)
+		}
+		if v.kind != metricHistogram {
+			m.line(v.name, labels, s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, b := range v.buckets {
+			cumulative += s.counts[i]
+			m.line(v.name+"_bucket", append(labels, "le", formatMetricValue(b)), float64(cumulative))
+		}
+		m.line(v.name+"_bucket", append(labels, "le", "+Inf"), float64(s.count))
+		m.line(v.name+"_sum", labels, s.sum)
+		m.line(v.name+"_count", labels, float64(s.count))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// LoadMetrics 加载指标配置
+func LoadMetrics() {
+	if ConfigAll != nil {
+		ConfigAll.UnmarshalKey("metrics", &ConfigMetrics)
+	}
+}
@@ -0,0 +1,42 @@
+package fast_base
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMetricsPrometheusText(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "requests", "route", "status")
+	counter.Inc("/users/:id", "200")
+	counter.Add(2, "/users/:id", "200")
+	counter.Inc(`/say"hi"`, "500")
+	histogram := NewHistogramVec("test_latency_seconds", "latency", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(3, "/a")
+	RegisterMetricsCollector("test", func(w *MetricsWriter) {
+		w.Gauge("test_pool_open", "open", 4, "source", "db")
+	})
+
+	var out strings.Builder
+	if err := WriteMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{route="/users/:id",status="200"} 3` + "\n",
+		`test_requests_total{route="/say\"hi\"",status="500"} 1` + "\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{route="/a",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{route="/a",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{route="/a",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{route="/a"} 3.55` + "\n",
+		`test_latency_seconds_count{route="/a"} 3` + "\n",
+		`test_pool_open{source="db"} 4` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package fast_base
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 分布式追踪：与 OpenTelemetry 数据模型兼容的最小实现。
+// 入站/出站使用 W3C traceparent 传播，span 通过可插拔的 TraceExporter 批量导出(OTLP/HTTP 或内存)。
+// 放在 fast_base 中，使 fast_web、fast_db、代理服务共享同一条链路。
+
+// ConfigTrace 追踪相关配置
+var ConfigTrace = TraceConfig{Enable: false, Exporter: "otlp", Endpoint: "http://127.0.0.1:4318/v1/traces", SampleRatio: 1, BatchSize: 512, FlushInterval: 5}
+
+type TraceConfig struct {
+	Enable        bool
+	Exporter      string            // otlp memory none
+	Endpoint      string            // OTLP/HTTP 地址，如 http://collector:4318/v1/traces
+	Headers       map[string]string // 导出时附加的请求头，如鉴权
+	ServiceName   string            // 为空时使用 ConfigEnv.GetApplicationId()
+	SampleRatio   float64           // 无上游采样决定时的采样比例 0~1
+	BatchSize     int
+	FlushInterval time.Duration // 单位秒
+}
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+func (s SpanID) IsValid() bool   { return s != SpanID{} }
+
+// SpanContext 跨进程传播的链路信息
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent 生成 W3C traceparent 头：00-{traceId}-{spanId}-{flags}
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent 解析 W3C traceparent 头
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// 版本 00 必须恰好 4 段，未知的更高版本允许追加字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	sc.Remote = true
+	return sc, true
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Span 一次操作的耗时记录
+type Span struct {
+	Name         string
+	Kind         SpanKind
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]any
+	StatusError  bool
+	StatusMsg    string
+
+	mu        sync.Mutex
+	recording bool
+	ended     bool
+}
+
+// SetAttribute 设置属性，值建议使用 string、bool、int64、float64
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError 标记失败
+func (s *Span) SetError(err error) {
+	if s == nil || !s.recording || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.StatusError = true
+	s.StatusMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End 结束并提交导出，重复调用只生效一次
+func (s *Span) End() {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+	traceProcessor.enqueue(s)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext 把上游(远端)链路信息放入 context
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFrom 获取当前链路信息
+func SpanContextFrom(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// StartSpan 创建子 span；context 中没有上游链路时创建新链路。追踪未启用时返回不记录的 span，仍可安全调用其方法
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent, hasParent := SpanContextFrom(ctx)
+	span := &Span{Name: name, Kind: kind, StartTime: time.Now(), Attributes: map[string]any{}}
+	if hasParent {
+		span.SpanContext.TraceID = parent.TraceID
+		span.SpanContext.Sampled = parent.Sampled
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.SpanContext.TraceID = newTraceID()
+		span.SpanContext.Sampled = ConfigTrace.SampleRatio >= 1 || rand.Float64() < ConfigTrace.SampleRatio
+	}
+	span.SpanContext.SpanID = newSpanID()
+	span.recording = ConfigTrace.Enable && span.SpanContext.Sampled
+	return ContextWithSpanContext(ctx, span.SpanContext), span
+}
+
+// InjectTraceparent 把当前链路写入出站请求头
+func InjectTraceparent(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFrom(ctx); ok {
+		header.Set("traceparent", sc.Traceparent())
+	}
+}
+
+// ExtractTraceparent 从入站请求头读取上游链路
+func ExtractTraceparent(ctx context.Context, header http.Header) context.Context {
+	if sc, ok := ParseTraceparent(header.Get("traceparent")); ok {
+		return ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// TraceTransport 包装出站 HTTP 客户端：为每个请求创建 client span 并注入 traceparent
+func TraceTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return traceTransport{base: base}
+}
+
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t traceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := StartSpan(r.Context(), "HTTP "+r.Method, SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.URL.String())
+	span.SetAttribute("net.peer.name", r.URL.Host)
+
+	// 不修改调用方的请求对象
+	r = r.Clone(ctx)
+	InjectTraceparent(ctx, r.Header)
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return resp, err
+	}
+	span.SetAttribute("http.status_code", int64(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("HTTP %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// TraceFields 当前链路的日志字段，日志与链路可互相检索
+func TraceFields(ctx context.Context) []zap.Field {
+	sc, ok := SpanContextFrom(ctx)
+	if !ok {
+		return nil
+	}
+	return []zap.Field{zap.String("trace_id", sc.TraceID.String()), zap.String("span_id", sc.SpanID.String())}
+}
+
+// LoggerWithContext 带链路字段的日志器
+func LoggerWithContext(ctx context.Context) *zap.Logger {
+	if fields := TraceFields(ctx); fields != nil {
+		return Logger.With(fields...)
+	}
+	return Logger
+}
+
+// PrintfWithContext 同 PrintfWithCaller，并附加链路字段
+func PrintfWithContext(ctx context.Context, level zapcore.Level, caller *zapcore.EntryCaller, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if ce := Logger.Check(level, message); ce != nil {
+		if caller != nil {
+			ce.Entry.Caller = *caller
+		}
+		ce.Write(TraceFields(ctx)...)
+	}
+}
+
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		putUint64(t[:8], rand.Uint64())
+		putUint64(t[8:], rand.Uint64())
+	}
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		putUint64(s[:], rand.Uint64())
+	}
+	return s
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
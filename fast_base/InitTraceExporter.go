@@ -0,0 +1,268 @@
+package fast_base
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceExporter span 导出器，可自行实现接入其他后端
+type TraceExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+// LoadTrace 加载追踪配置并按配置创建导出器
+func LoadTrace() {
+	if ConfigAll != nil {
+		ConfigAll.UnmarshalKey("trace", &ConfigTrace)
+	}
+	if !ConfigTrace.Enable {
+		return
+	}
+	switch ConfigTrace.Exporter {
+	case "otlp":
+		SetTraceExporter(NewOTLPExporter(ConfigTrace.Endpoint, ConfigTrace.Headers))
+	case "memory":
+		SetTraceExporter(NewInMemoryExporter())
+	default:
+		SetTraceExporter(nil)
+	}
+	Logger.Info("链路追踪已启用，导出方式：" + ConfigTrace.Exporter)
+}
+
+// SetTraceExporter 替换导出器，nil 表示丢弃
+func SetTraceExporter(exporter TraceExporter) {
+	traceProcessor.setExporter(exporter)
+}
+
+// ShutdownTrace 导出缓冲区中剩余的 span 并关闭导出器
+func ShutdownTrace(ctx context.Context) error {
+	return traceProcessor.shutdown(ctx)
+}
+
+// FlushTrace 立即导出缓冲区中的 span
+func FlushTrace(ctx context.Context) error {
+	return traceProcessor.flush(ctx)
+}
+
+// ////////////////////////////////// 批量处理 //////////////////////////////////
+
+var traceProcessor = &batchProcessor{}
+
+type batchProcessor struct {
+	mu       sync.Mutex
+	exporter TraceExporter
+	buffer   []*Span
+	timer    *time.Timer
+}
+
+func (p *batchProcessor) setExporter(exporter TraceExporter) {
+	p.mu.Lock()
+	p.exporter = exporter
+	p.buffer = nil
+	p.mu.Unlock()
+}
+
+func (p *batchProcessor) enqueue(span *Span) {
+	p.mu.Lock()
+	if p.exporter == nil {
+		p.mu.Unlock()
+		return
+	}
+	size := ConfigTrace.BatchSize
+	if size <= 0 {
+		size = 512
+	}
+	// 导出跟不上时丢弃，避免内存无限增长
+	if len(p.buffer) >= size*4 {
+		p.mu.Unlock()
+		return
+	}
+	p.buffer = append(p.buffer, span)
+	full := len(p.buffer) >= size
+	if !full && p.timer == nil {
+		interval := ConfigTrace.FlushInterval * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		p.timer = time.AfterFunc(interval, func() { p.flush(context.Background()) })
+	}
+	p.mu.Unlock()
+	if full {
+		go p.flush(context.Background())
+	}
+}
+
+func (p *batchProcessor) flush(ctx context.Context) error {
+	p.mu.Lock()
+	spans, exporter := p.buffer, p.exporter
+	p.buffer = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+	if exporter == nil || len(spans) == 0 {
+		return nil
+	}
+	err := exporter.ExportSpans(ctx, spans)
+	if err != nil && Logger != nil {
+		Logger.Warn("导出链路数据失败：" + err.Error())
+	}
+	return err
+}
+
+func (p *batchProcessor) shutdown(ctx context.Context) error {
+	err := p.flush(ctx)
+	p.mu.Lock()
+	exporter := p.exporter
+	p.exporter = nil
+	p.mu.Unlock()
+	if exporter != nil {
+		if e := exporter.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ////////////////////////////////// 内存导出器，用于测试 //////////////////////////////////
+
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *InMemoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// Spans 已导出的 span 副本
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// ////////////////////////////////// OTLP/HTTP(JSON) 导出器 //////////////////////////////////
+
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{Endpoint: endpoint, Headers: headers, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	body, err := Json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP 导出失败: HTTP %d %s", resp.StatusCode, msg)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.Client.CloseIdleConnections()
+	return nil
+}
+
+// otlpRequest 按 OTLP JSON 编码组装请求体。traceId/spanId 使用十六进制，时间为字符串形式的纳秒
+func otlpRequest(spans []*Span) map[string]any {
+	serviceName := ConfigTrace.ServiceName
+	if serviceName == "" {
+		serviceName = ConfigEnv.GetApplicationId()
+	}
+	items := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		item := map[string]any{
+			"traceId":           s.SpanContext.TraceID.String(),
+			"spanId":            s.SpanContext.SpanID.String(),
+			"name":              s.Name,
+			"kind":              int(s.Kind),
+			"startTimeUnixNano": strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+		}
+		if s.ParentSpanID.IsValid() {
+			item["parentSpanId"] = s.ParentSpanID.String()
+		}
+		if s.StatusError {
+			item["status"] = map[string]any{"code": 2, "message": s.StatusMsg}
+		}
+		s.mu.Unlock()
+		items = append(items, item)
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource": map[string]any{"attributes": otlpAttributes(map[string]any{"service.name": serviceName})},
+		"scopeSpans": []any{map[string]any{
+			"scope": map[string]any{"name": "github.com/tdwu/fast_go"},
+			"spans": items,
+		}},
+	}}}
+}
+
+func otlpAttributes(attributes map[string]any) []any {
+	list := make([]any, 0, len(attributes))
+	for k, v := range attributes {
+		var value map[string]any
+		switch t := v.(type) {
+		case bool:
+			value = map[string]any{"boolValue": t}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(t)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(t, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": t}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(t)}
+		}
+		list = append(list, map[string]any{"key": k, "value": value})
+	}
+	return list
+}
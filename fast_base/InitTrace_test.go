@@ -0,0 +1,78 @@
+package fast_base
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok || !sc.Sampled || !sc.Remote {
+		t.Fatalf("parse failed: %#v", sc)
+	}
+	if sc.Traceparent() != header {
+		t.Fatalf("round trip mismatch: %s", sc.Traceparent())
+	}
+	for _, bad := range []string{"", "00-0000000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x"} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStartSpanLinksParentAndExports(t *testing.T) {
+	previous := ConfigTrace
+	ConfigTrace.Enable, ConfigTrace.SampleRatio = true, 1
+	exporter := NewInMemoryExporter()
+	SetTraceExporter(exporter)
+	t.Cleanup(func() {
+		ConfigTrace = previous
+		SetTraceExporter(nil)
+	})
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ExtractTraceparent(context.Background(), header)
+
+	ctx, parent := StartSpan(ctx, "parent", SpanKindServer)
+	_, child := StartSpan(ctx, "child", SpanKindInternal)
+	child.End()
+	parent.End()
+	parent.End()
+	if err := FlushTrace(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[1].SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || spans[1].ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span did not continue upstream trace: %#v", spans[1].SpanContext)
+	}
+	if spans[0].ParentSpanID != spans[1].SpanContext.SpanID {
+		t.Fatal("child span should point at parent")
+	}
+
+	out := http.Header{}
+	InjectTraceparent(ctx, out)
+	if out.Get("traceparent") != parent.SpanContext.Traceparent() {
+		t.Fatalf("unexpected injected header %q", out.Get("traceparent"))
+	}
+}
+
+func TestStartSpanDisabledIsNoop(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	SetTraceExporter(exporter)
+	t.Cleanup(func() { SetTraceExporter(nil) })
+
+	_, span := StartSpan(context.Background(), "noop", SpanKindInternal)
+	span.SetAttribute("k", "v")
+	span.End()
+	FlushTrace(context.Background())
+	if len(exporter.Spans()) != 0 {
+		t.Fatal("disabled tracing must not export spans")
+	}
+}
@@ -0,0 +1,43 @@
+package fast_base
+
+import (
+	"context"
+	"sync"
+)
+
+// 关闭钩子：各模块(数据库租约等)注册清理函数，由 fast_web 在优雅关闭时调用，
+// 放在 fast_base 中，避免 fast_db 与 fast_web 互相依赖。
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+var shutdownLock sync.Mutex
+var shutdownHooks []shutdownHook
+
+// RegisterShutdownHook 注册关闭钩子，同名覆盖
+func RegisterShutdownHook(name string, fn func(ctx context.Context) error) {
+	shutdownLock.Lock()
+	defer shutdownLock.Unlock()
+	for i, h := range shutdownHooks {
+		if h.name == name {
+			shutdownHooks[i].fn = fn
+			return
+		}
+	}
+	shutdownHooks = append(shutdownHooks, shutdownHook{name: name, fn: fn})
+}
+
+// RunShutdownHooks 按注册的相反顺序执行关闭钩子，并清空列表。单个钩子失败只记录日志
+func RunShutdownHooks(ctx context.Context) {
+	shutdownLock.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownLock.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			Logger.Error("关闭钩子执行失败, name=" + hooks[i].name + ", error=" + err.Error())
+		}
+	}
+}
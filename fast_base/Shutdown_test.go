@@ -0,0 +1,34 @@
+package fast_base
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRunShutdownHooksReverseOrder(t *testing.T) {
+	Logger = zap.NewNop()
+	var order []string
+	hook := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	RegisterShutdownHook("a", hook("a", nil))
+	RegisterShutdownHook("b", hook("b", errors.New("boom")))
+	RegisterShutdownHook("c", hook("c", nil))
+	RegisterShutdownHook("a", hook("a2", nil))
+
+	RunShutdownHooks(context.Background())
+	if want := []string{"c", "b", "a2"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	RunShutdownHooks(context.Background())
+	if len(order) != 3 {
+		t.Fatal("hooks should run only once")
+	}
+}
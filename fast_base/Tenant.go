@@ -0,0 +1,21 @@
+package fast_base
+
+import "context"
+
+// 租户：由 fast_web 根据令牌或请求头放入请求 context，fast_db 据此做数据隔离。
+
+type tenantKey struct{}
+
+// WithTenant 把租户ID放入 context
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantId)
+}
+
+// TenantFrom 获取 context 中的租户ID
+func TenantFrom(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantId, ok := ctx.Value(tenantKey{}).(string)
+	return tenantId, ok && tenantId != ""
+}
@@ -2,19 +2,36 @@ package fast_db
 
 import (
 	"fmt"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"net/url"
+	"strings"
 	"time"
 )
 
-var ConfigDataSource = DataSourceConfig{Enable: true, LogLevel: "info", Host: "127.0.0.1", Port: "3306", DriverName: "mysql", Params: "charset=utf8mb4&parseTime=true", MaxIdleConns: 5, MaxOpenConns: 100, MaxIdleTime: 0, ConnMaxLifetime: 60 * 60}
-var ConfigSnowWorker = SnowWorkerConfig{WorkId: 0, CenterId: 0}
+var ConfigDataSource = newDataSourceConfig("./conf/db/migration")
+
+// ConfigDataSources 所有已配置的数据源，key 为名称(viper 中 key 不区分大小写，统一为小写)
+var ConfigDataSources = map[string]DataSourceConfig{}
+
+// ConfigTenant 多租户配置，默认关闭
+var ConfigTenant = TenantConfig{Strategy: TenantColumn, Column: "tenant_id", Pattern: "tenant_%s", Required: true}
+
+// ConfigCache 查询缓存配置，默认关闭
+var ConfigCache = CacheConfig{TTL: 60, MaxEntries: 10000}
+
+// ConfigSqlStats SQL 统计配置，默认开启
+var ConfigSqlStats = SqlStatsConfig{Enable: true, MaxFingerprints: 1000}
+var ConfigSnowWorker = SnowWorkerConfig{WorkId: 0, CenterId: 0, WorkerBits: defaultWorkerBits, SequenceBits: defaultSequenceBits, Epoch: defaultEpoch, MaxBackward: 10, LeaseTTL: 30}
 
 var SnowMaker *SnowWorker
 var DB *gorm.DB
 
 type DataSourceConfig struct {
 	Enable          bool   `yaml:"enable"`
-	DriverName      string `yaml:"driverName"`
+	DriverName      string `yaml:"driverName"` // mysql postgres sqlite
 	Host            string `yaml:"host"`
 	Port            string `yaml:"port"`
 	Database        string `yaml:"database"`
@@ -26,13 +43,183 @@ type DataSourceConfig struct {
 	MaxIdleTime     time.Duration // 单位秒
 	ConnMaxLifetime time.Duration // 单位秒
 	LogLevel        string        // 日志打印级别 debug  info  warning  error
+	MigrationDir    string        // 数据库迁移脚本目录，为空且未注册内嵌脚本时不执行迁移
+	AutoMigrate     bool          // 启动时自动执行待执行的迁移，默认 true
+
+	MigrationLockTimeout   time.Duration // 等待迁移锁的超时时间，单位秒，默认 60
+	MigrationBackupCommand string        // 执行迁移前运行的备份命令(sh -c)，连接信息通过 FAST_DB_* 环境变量传入
+
+	Replicas             []ReplicaConfig // 只读副本，查询自动路由到副本，写操作和事务留在主库
+	ReplicaPolicy        string          // 副本选择策略 roundRobin leastConn，默认 roundRobin
+	ReplicaCheckInterval time.Duration   // 副本心跳间隔，单位秒，心跳失败的副本暂停使用
+
+	SlowThreshold time.Duration // 慢查询阈值，单位毫秒，默认 200，0 表示不记录慢查询
+	ExplainSlow   bool          // 每个 SQL 指纹第一次慢查询时执行 EXPLAIN
 }
 
+// ReplicaConfig 只读副本，未配置的项沿用主库配置
+type ReplicaConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Database string `yaml:"database"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Params   string `yaml:"params"`
+}
+
+// replica 合并主库与副本配置
+func (t DataSourceConfig) replica(r ReplicaConfig) DataSourceConfig {
+	c := t
+	c.MigrationDir, c.Replicas = "", nil
+	c.Host = firstNonEmpty(r.Host, c.Host)
+	c.Port = firstNonEmpty(r.Port, c.Port)
+	c.Database = firstNonEmpty(r.Database, c.Database)
+	c.Username = firstNonEmpty(r.Username, c.Username)
+	c.Password = firstNonEmpty(r.Password, c.Password)
+	c.Params = firstNonEmpty(r.Params, c.Params)
+	return c
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// newDataSourceConfig 数据源默认配置，各数据源在此基础上覆盖
+func newDataSourceConfig(migrationDir string) DataSourceConfig {
+	return DataSourceConfig{Enable: true, LogLevel: "info", Host: "127.0.0.1", Port: mysqlDefaultPort, DriverName: DriverMysql, Params: mysqlDefaultParams, MaxIdleConns: 5, MaxOpenConns: 100, MaxIdleTime: 0, ConnMaxLifetime: 60 * 60, MigrationDir: migrationDir, AutoMigrate: true, MigrationLockTimeout: 60, ReplicaPolicy: ReplicaRoundRobin, ReplicaCheckInterval: 10, SlowThreshold: 200}
+}
+
+const (
+	DriverMysql    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSqlite   = "sqlite"
+)
+
+// 默认配置中的 Port、Params 是 MySQL 的，其他驱动未显式配置时替换为各自的默认值
+const mysqlDefaultPort, mysqlDefaultParams = "3306", "charset=utf8mb4&parseTime=true"
+
+// DNS 按驱动生成 gorm 使用的 DSN。sqlite 的 Database 为文件路径，如 ./data/app.db 或 file::memory:?cache=shared
 func (t DataSourceConfig) DNS() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", t.Username, t.Password, t.Host, t.Port, t.Database, t.Params)
+	switch t.DriverName {
+	case DriverPostgres:
+		// key=value 形式，Params 可写成 sslmode=disable&TimeZone=Asia/Shanghai
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s", pqQuote(t.Host), pqQuote(t.Port), pqQuote(t.Username), pqQuote(t.Password), pqQuote(t.Database))
+		for _, p := range strings.Split(t.Params, "&") {
+			if k, v, ok := strings.Cut(p, "="); ok {
+				dsn += " " + k + "=" + pqQuote(v)
+			}
+		}
+		return dsn
+	case DriverSqlite:
+		return joinParams(t.Database, t.Params)
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", t.Username, t.Password, t.Host, t.Port, t.Database, t.Params)
+	}
+}
+
+// MigrateURL golang-migrate 使用的数据库地址
+func (t DataSourceConfig) MigrateURL() string {
+	switch t.DriverName {
+	case DriverPostgres:
+		u := url.URL{Scheme: "postgres", User: url.UserPassword(t.Username, t.Password), Host: t.Host + ":" + t.Port, Path: "/" + t.Database, RawQuery: t.Params}
+		return u.String()
+	case DriverSqlite:
+		return "sqlite3://" + joinParams(strings.TrimPrefix(t.Database, "file:"), t.Params)
+	default:
+		return "mysql://" + t.DNS()
+	}
+}
+
+// Dialector 按驱动创建 gorm 方言
+func (t DataSourceConfig) Dialector() (gorm.Dialector, error) {
+	switch t.DriverName {
+	case DriverMysql, "":
+		return mysql.Open(t.DNS()), nil
+	case DriverPostgres:
+		return postgres.Open(t.DNS()), nil
+	case DriverSqlite:
+		return sqlite.Open(t.DNS()), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", t.DriverName)
+	}
+}
+
+// applyDriverDefaults 非 MySQL 驱动沿用了 MySQL 默认端口和参数时，替换为该驱动的默认值
+func (t DataSourceConfig) applyDriverDefaults() DataSourceConfig {
+	switch t.DriverName {
+	case DriverPostgres:
+		if t.Port == mysqlDefaultPort {
+			t.Port = "5432"
+		}
+		if t.Params == mysqlDefaultParams {
+			t.Params = "sslmode=disable"
+		}
+	case DriverSqlite:
+		if t.Params == mysqlDefaultParams {
+			t.Params = "_foreign_keys=on&_busy_timeout=5000"
+		}
+	}
+	return t
+}
+
+// pqQuote 按 libpq 规则转义 key=value 中的值：为空或含空白、单引号、反斜杠时加单引号，并用反斜杠转义
+func pqQuote(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\r'\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func joinParams(base, params string) string {
+	if params == "" {
+		return base
+	}
+	if strings.Contains(base, "?") {
+		return base + "&" + params
+	}
+	return base + "?" + params
+}
+
+type TenantConfig struct {
+	Enable   bool
+	Strategy string   // column、schema、database
+	Column   string   // column 策略的租户字段(列名或字段名)
+	Pattern  string   // schema、database 策略中 schema 或数据源的名称，%s 替换为租户ID
+	Shared   []string // schema 策略中所有租户共用的表
+	Required bool     // 访问租户数据时 context 中必须有租户，否则返回 ErrTenantMissing
+}
+
+type CacheConfig struct {
+	Enable     bool
+	TTL        time.Duration // 缓存有效期，单位秒
+	MaxEntries int           // 内存缓存的最大条目数，超过时淘汰最久未使用的条目
+}
+
+type SqlStatsConfig struct {
+	Enable          bool
+	MaxFingerprints int // 最多统计的指纹数，超出后新指纹合并为 <other>
 }
 
 type SnowWorkerConfig struct {
 	WorkId   int64
 	CenterId int64
+	PerTable bool // 自动填充主键时每张表使用独立的生成器(GetIdForTable)，默认共用 SnowMaker
+
+	CenterBits   uint8         // 数据中心位数，默认 0
+	WorkerBits   uint8         // 机器位数，默认 10
+	SequenceBits uint8         // 每毫秒序号位数，默认 12
+	Epoch        int64         // 起始时间(毫秒)，上线后不可修改
+	MaxBackward  time.Duration // 允许等待的时钟回拨，单位毫秒，超过时拒绝生成
+
+	Lease    bool          // 从默认数据源的租约表领取机器号，忽略 WorkId
+	LeaseTTL time.Duration // 租约有效期，单位秒，每 1/3 周期续约一次
+}
+
+func (t SnowWorkerConfig) layout() SnowLayout {
+	return SnowLayout{CenterBits: t.CenterBits, WorkerBits: t.WorkerBits, SequenceBits: t.SequenceBits, Epoch: t.Epoch}
 }
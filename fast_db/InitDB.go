@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"github.com/tdwu/fast_go/fast_base"
 	"go.uber.org/zap/zapcore"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
@@ -18,29 +17,129 @@ import (
 // LoadDataSource 包初始化函数，golang特性，每个包初始化的时候会自动执行init函数，这里用来初始化gorm。
 func LoadDataSource() {
 
-	fast_base.ConfigAll.UnmarshalKey("dataSource", &ConfigDataSource)
+	ConfigDataSources = loadDataSourceConfigs()
 	fast_base.ConfigAll.UnmarshalKey("snowWorker", &ConfigSnowWorker)
+	fast_base.ConfigAll.UnmarshalKey("tenant", &ConfigTenant)
+	strategy, err := newTenantStrategy(ConfigTenant)
+	if err != nil {
+		panic("多租户配置错误, error=" + err.Error())
+	}
+	SetTenantStrategy(strategy)
+	loadCache()
+	fast_base.ConfigAll.UnmarshalKey("sqlStats", &ConfigSqlStats)
+	registerSqlStatsAdmin()
+
+	for _, name := range sortedDataSourceNames(ConfigDataSources) {
+		conf := ConfigDataSources[name]
+		if !conf.Enable {
+			fast_base.Logger.Info("数据源 " + name + " 未启用")
+			continue
+		}
+		db, err := OpenDataSource(name, conf)
+		if err != nil {
+			panic("连接数据库失败, name=" + name + ", error=" + err.Error())
+		}
+		// 注册到数据源列表，default 同时设置为全局 DB
+		RegisterDataSource(name, db)
+	}
 
-	if !ConfigDataSource.Enable {
+	if DB == nil {
 		fast_base.Logger.Info("数据库 未启用")
 		return
 	}
 
+	// 启用雪花算法
+	snowMaker, err := newSnowMaker()
+	if err != nil {
+		panic("雪花算法配置错误, error=" + err.Error())
+	}
+	SnowMaker = snowMaker
+
+	fast_base.DictQueryBySql = func(sql string, p ...interface{}) string {
+		v, err := cachedLoad(context.Background(), "dict", func() ([]string, []any) {
+			return sqlTables(sql), []any{normalizeSQL(sql), p}
+		}, func() (string, error) {
+			var v string
+			err := DB.Raw(sql, p...).Scan(&v).Error
+			return v, err
+		})
+		if err != nil {
+			return err.Error()
+		}
+		return v
+	}
+}
+
+// OpenDataSource 按配置执行迁移、建立连接池，并注册健康检查、指标和链路追踪。不会注册到数据源列表
+func OpenDataSource(name string, conf DataSourceConfig) (*gorm.DB, error) {
 	// 数据库版本管理工具
-	migrateDB()
+	if hasMigrations(name, conf) {
+		run := verifyMigrations
+		if conf.AutoMigrate {
+			run = autoMigrate
+		}
+		if err := run(name, conf); err != nil {
+			return nil, err
+		}
+	}
 
-	// 启用雪花算法
-	SnowMaker = NewSnowWorker(ConfigSnowWorker.WorkId)
+	_db, err := openGorm(conf)
+	if err != nil {
+		return nil, err
+	}
 
-	// 连接MYSQL, 获得DB类型实例，用于后面的数据库读写操作。
-	level, ok := fast_base.LogLevelMap[ConfigDataSource.LogLevel] // 日志打印级别
+	// 心跳：数据库连通性和迁移版本交给健康检查(/readyz)
+	registerHealthChecks(name, _db)
+	// 指标：SQL 耗时与连接池状态
+	registerMetrics(name, _db)
+	// 链路追踪：SQL span
+	registerTrace(name, _db)
+	// SQL 指纹统计与慢查询执行计划
+	if err := registerSqlStats(name, conf, _db); err != nil {
+		return nil, err
+	}
+	// 自动主键、审计字段、乐观锁
+	if err := _db.Use(&AuditPlugin{}); err != nil {
+		return nil, err
+	}
+	// 分表路由
+	if err := _db.Use(&ShardPlugin{}); err != nil {
+		return nil, err
+	}
+	// 多租户隔离
+	if err := _db.Use(&TenantPlugin{}); err != nil {
+		return nil, err
+	}
+	// 写操作后使查询缓存失效
+	if err := _db.Use(&CachePlugin{}); err != nil {
+		return nil, err
+	}
+	// 读写分离：查询路由到只读副本
+	if len(conf.Replicas) > 0 {
+		if err := registerReplicas(name, conf, _db); err != nil {
+			return nil, err
+		}
+	}
+
+	return _db, nil
+}
+
+// openGorm 建立连接并设置连接池
+func openGorm(conf DataSourceConfig) (*gorm.DB, error) {
+	// 连接数据库, 获得DB类型实例，用于后面的数据库读写操作。
+	level, ok := fast_base.LogLevelMap[conf.LogLevel] // 日志打印级别
 	if !ok {
 		level = fast_base.LogLevelMap["info"]
 	}
 
+	dialector, err := conf.Dialector()
+	if err != nil {
+		return nil, err
+	}
+
 	// 获得一个*grom.DB对象
 	_db, err := gorm.Open(
-		mysql.Open(ConfigDataSource.DNS()),
+		dialector,
 		//日志框架替换
 		&gorm.Config{
 			NamingStrategy: schema.NamingStrategy{SingularTable: true}, // 表名不加s.
@@ -49,7 +148,7 @@ func LoadDataSource() {
 			//	Strict: true,
 			// 定制化logger,与zap日志框架集成
 			Logger: customGormLogger(logger.Config{
-				SlowThreshold: 200 * time.Millisecond, //设定慢查询时间阈值为1ms
+				SlowThreshold: conf.SlowThreshold * time.Millisecond, //慢查询阈值，数据源配置 slowThreshold
 				//设置日志级别，只有Warn和Info级别会输出慢查询日志
 				//LogLevel:                  logger.Warn,
 				IgnoreRecordNotFoundError: false,
@@ -57,46 +156,37 @@ func LoadDataSource() {
 			}, level),
 		})
 	if err != nil {
-		panic("连接数据库失败, error=" + err.Error())
+		return nil, err
 	}
 
-	// 设置为全局
-	DB = _db
-
 	// 连接池配置(Go DataBase Api 自带了连接池)
-	sqlDB, _ := _db.DB()
+	sqlDB, err := _db.DB()
+	if err != nil {
+		return nil, err
+	}
 
 	//连接池最多同时打开的连接数。
 	//这个maxOpenConns理应要设置得比mysql服务器的max_connections值要小。
 	//一般设置为： 服务器cpu核心数 * 2 + 服务器有效磁盘数。参考这里
 	//可用show variables like ‘max_connections’; 查看服务器当前设置的最大连接数。
-	sqlDB.SetMaxOpenConns(ConfigDataSource.MaxOpenConns)
+	sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
 
 	//连接池最大允许的空闲连接数。必须要比maxOpenConns小，超过的连接会被连接池关闭。
-	sqlDB.SetMaxIdleConns(ConfigDataSource.MaxIdleConns)
+	sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
 
 	//连接池里面的连接最大空闲时长。
 	//当连接持续空闲时长达到maxIdleTime后，该连接就会被关闭并从连接池移除，【注意】哪怕当前空闲连接数已经小于SetMaxIdleConns(maxIdleConns)设置的值。
 	//连接每次被使用后，持续空闲时长会被重置，从0开始从新计算；
 	//用show processlist; 可用查看mysql服务器上的连接信息，Command表示连接的当前状态，Command为Sleep时表示休眠、空闲状态，Time表示此状态的已持续时长；
 	//建议设置为0，不启用
-	sqlDB.SetConnMaxIdleTime(ConfigDataSource.MaxIdleTime * time.Second)
+	sqlDB.SetConnMaxIdleTime(conf.MaxIdleTime * time.Second)
 
 	//连接池里面的连接最大存活时长。
 	//maxLifeTime必须要比mysql服务器设置的wait_timeout小，否则会导致golang侧连接池依然保留已被mysql服务器关闭了的连接。
 	//mysql服务器的wait_timeout默认是8 hour，可通过show variables like 'wait_timeout’查看。
-	sqlDB.SetConnMaxLifetime(ConfigDataSource.ConnMaxLifetime * time.Second)
+	sqlDB.SetConnMaxLifetime(conf.ConnMaxLifetime * time.Second)
 
-	// 【问题】没心跳sql？
-
-	fast_base.DictQueryBySql = func(sql string, p ...interface{}) string {
-		var v string
-		err = DB.Raw(sql, p...).Scan(&v).Error
-		if err != nil {
-			return err.Error()
-		}
-		return v
-	}
+	return _db, nil
 }
 
 type Model struct {
@@ -106,6 +196,13 @@ type Model struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// AuditModel 带创建人、修改人的 Model，由审计插件根据当前用户自动填写
+type AuditModel struct {
+	Model
+	CreatedBy int64
+	UpdatedBy int64
+}
+
 // //////////////////////////////////定制化日志器//////////////////////////////////////////////////////////
 func customGormLogger(config logger.Config, level zapcore.Level) logger.Interface {
 	var (
@@ -167,21 +264,21 @@ func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
 // Info print info
 func (l GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= logger.Info {
-		fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), msg, data...)
+		fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), msg, data...)
 	}
 }
 
 // Warn print warn messages
 func (l GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= logger.Warn {
-		fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), msg, data...)
+		fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), msg, data...)
 	}
 }
 
 // Error print error messages
 func (l GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= logger.Error {
-		fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), msg, data...)
+		fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), msg, data...)
 	}
 }
 
@@ -196,29 +293,29 @@ func (l GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (strin
 	case err != nil && l.LogLevel >= logger.Error && (!errors.Is(err, logger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
 		sql, rows := fc()
 		if rows == -1 {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 			//	l.Printf(l.traceErrStr, utils.FileWithLineNum(), err, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceErrStr, err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 			//	l.Printf(l.traceErrStr, utils.FileWithLineNum(), err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= logger.Warn:
 		sql, rows := fc()
 		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
 		if rows == -1 {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 			//l.Printf(l.traceWarnStr, utils.FileWithLineNum(), slowLog, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceWarnStr, slowLog, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 			//l.Printf(l.traceWarnStr, utils.FileWithLineNum(), slowLog, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	case l.LogLevel == logger.Info:
 		sql, rows := fc()
 		if rows == -1 {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceStr, float64(elapsed.Nanoseconds())/1e6, "-", sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceStr, float64(elapsed.Nanoseconds())/1e6, "-", sql)
 			//l.Printf(l.traceStr, utils.FileWithLineNum(), float64(elapsed.Nanoseconds())/1e6, "-", sql)
 		} else {
-			fast_base.PrintfWithCaller(l.ZapLevel, findGormCaller(), l.traceStr, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+			fast_base.PrintfWithContext(ctx, l.ZapLevel, findGormCaller(), l.traceStr, float64(elapsed.Nanoseconds())/1e6, rows, sql)
 			//l.Printf(l.traceStr, utils.FileWithLineNum(), float64(elapsed.Nanoseconds())/1e6, rows, sql)
 		}
 	}
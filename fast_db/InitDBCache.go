@@ -0,0 +1,504 @@
+package fast_db
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// 查询缓存(配置 cache，默认关闭)：GetById 按模型和主键缓存，GetOne、DictQueryBySql 按规范化后的 SQL 和参数缓存。
+// 每条缓存以表名为标签，GORM 的新增、修改、删除以及 Exec 执行的写语句使该表的缓存失效。
+// 事务中的查询不使用缓存，事务内的写操作在提交后再次失效，避免并发读取把旧数据写回缓存。
+//
+// 缓存值以 JSON 保存，带 json:"-"、未导出字段或接口的类型无法完整还原，这类查询不缓存。
+//
+// 内存缓存只在本进程内失效，多实例部署时其他实例的写入依赖 TTL 过期；可通过 SetQueryCache 替换为共享缓存。
+
+var dbCacheRequests = fast_base.NewCounterVec("db_cache_requests_total", "查询缓存请求次数", "result")
+
+// QueryCache 查询缓存后端。tags 为数据所属的表，Invalidate 删除带有任一标签的缓存
+type QueryCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string)
+	Invalidate(ctx context.Context, tags ...string)
+}
+
+var cacheLock sync.RWMutex
+var queryCache QueryCache
+
+// SetQueryCache 设置缓存后端，nil 表示关闭缓存
+func SetQueryCache(c QueryCache) {
+	cacheLock.Lock()
+	queryCache = c
+	cacheLock.Unlock()
+}
+
+// GetQueryCache 当前缓存后端，未启用时返回 nil
+func GetQueryCache() QueryCache {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+	return queryCache
+}
+
+type noCacheKey struct{}
+
+// NoCache 跳过缓存，直接查询数据库
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// InvalidateCache 使表的缓存失效，用于缓存无法感知的修改(如其他系统直接写库)
+func InvalidateCache(ctx context.Context, tables ...string) {
+	tags := make([]string, 0, len(tables))
+	for _, t := range tables {
+		tags = append(tags, cacheTag(t))
+	}
+	invalidateTags(ctx, tags)
+}
+
+// 标签版本号：查询前记录，写入缓存前比较，查询期间发生失效时不写入
+var cacheGenLock sync.Mutex
+var cacheGens = map[string]uint64{}
+
+func cacheGen(tags []string) uint64 {
+	cacheGenLock.Lock()
+	defer cacheGenLock.Unlock()
+	var sum uint64
+	for _, t := range tags {
+		sum += cacheGens[t]
+	}
+	return sum
+}
+
+func invalidateTags(ctx context.Context, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	cacheGenLock.Lock()
+	for _, t := range tags {
+		cacheGens[t]++
+	}
+	cacheGenLock.Unlock()
+	if c := GetQueryCache(); c != nil {
+		c.Invalidate(ctx, tags...)
+	}
+}
+
+// cacheTag 表名去掉 schema 和引号，统一小写
+func cacheTag(table string) string {
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		table = table[i+1:]
+	}
+	return strings.ToLower(strings.Trim(table, "`\"[]"))
+}
+
+// cacheUsable 事务中、跳过缓存或未启用时不使用缓存
+func cacheUsable(ctx context.Context) (QueryCache, bool) {
+	c := GetQueryCache()
+	if c == nil {
+		return nil, false
+	}
+	if skip, _ := ctx.Value(noCacheKey{}).(bool); skip {
+		return nil, false
+	}
+	if _, inTx := TxFrom(ctx); inTx {
+		return nil, false
+	}
+	return c, true
+}
+
+// cacheKey 租户隔离时不同租户的缓存互不可见
+func cacheKey(ctx context.Context, kind string, parts ...any) (string, bool) {
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	prefix := "fast_db:" + kind + ":"
+	if GetTenantStrategy() != nil && !tenantSkipped(ctx) {
+		if tenantId, ok := fast_base.TenantFrom(ctx); ok {
+			prefix += tenantId + ":"
+		}
+	}
+	return prefix + hex.EncodeToString(sum[:]), true
+}
+
+// cachedLoad 读取缓存，未命中时执行 load 并写入缓存。target 返回缓存标签(表名)和组成缓存键的内容，
+// 只在缓存可用时调用；没有标签或 T 不能经 JSON 完整还原时不缓存。load 出错时不缓存
+func cachedLoad[T any](ctx context.Context, kind string, target func() ([]string, []any), load func() (T, error)) (T, error) {
+	c, ok := cacheUsable(ctx)
+	if !ok || !jsonLossless(reflect.TypeFor[T]()) {
+		return load()
+	}
+	tags, parts := target()
+	key, ok := cacheKey(ctx, kind, parts...)
+	if !ok || len(tags) == 0 {
+		return load()
+	}
+	if b, hit := c.Get(ctx, key); hit {
+		var v T
+		if err := json.Unmarshal(b, &v); err == nil {
+			dbCacheRequests.Inc("hit")
+			return v, nil
+		}
+	}
+	dbCacheRequests.Inc("miss")
+	gen := cacheGen(tags)
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+	if cacheGen(tags) != gen {
+		return v, nil
+	}
+	if b, err := json.Marshal(v); err == nil {
+		c.Set(ctx, key, b, ConfigCache.TTL*time.Second, tags)
+	}
+	return v, nil
+}
+
+var jsonLosslessTypes sync.Map
+
+var jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+
+// jsonLossless 类型经 JSON 序列化再反序列化后是否保持不变：不含 json:"-"、未导出的字段和接口。
+// 自定义 MarshalJSON 的类型(如 time.Time、gorm.DeletedAt)视为可还原
+func jsonLossless(t reflect.Type) bool {
+	if v, ok := jsonLosslessTypes.Load(t); ok {
+		return v.(bool)
+	}
+	ok := checkJSONLossless(t, map[reflect.Type]bool{})
+	jsonLosslessTypes.Store(t, ok)
+	return ok
+}
+
+func checkJSONLossless(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return true
+	}
+	visiting[t] = true
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return checkJSONLossless(t.Elem(), visiting)
+	case reflect.Interface, reflect.Chan, reflect.Func:
+		// 接口中的数字、时间反序列化后类型改变，通道和函数无法序列化
+		return false
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() && !f.Anonymous {
+				return false
+			}
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name == "-" {
+				return false
+			}
+			if !checkJSONLossless(f.Type, visiting) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// modelTable 模型对应的表名
+func modelTable[T any](db *gorm.DB) string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return ""
+	}
+	return stmt.Schema.Table
+}
+
+// normalizeSQL 去掉注释并合并空白，格式不同的同一查询使用同一缓存
+func normalizeSQL(sql string) string {
+	var b strings.Builder
+	for i, t := range significant(tokenizeSQL(sql, false)) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// 表名前可能出现的修饰词
+var sqlTableModifiers = map[string]bool{
+	"only": true, "ignore": true, "low_priority": true, "high_priority": true, "delayed": true, "quick": true,
+	"if": true, "not": true, "exists": true, "lateral": true, "table": true, "into": true, "from": true,
+}
+
+// sqlTables SQL 中 FROM、JOIN、INTO、UPDATE、TABLE 之后的表名，用于缓存标签与写语句失效。
+// 只做识别，宁多勿少：子查询中的表同样计入
+func sqlTables(sql string) []string {
+	sig := significant(tokenizeSQL(sql, false))
+	seen := map[string]bool{}
+	var tables []string
+	// name 解析 sig[i] 开始的表名(可带 schema)，返回表名和之后的位置
+	name := func(i int) (string, int) {
+		for i < len(sig) && sig[i].kind == tokWord && sqlTableModifiers[strings.ToLower(sig[i].text)] {
+			i++
+		}
+		last := ""
+		for i < len(sig) && (sig[i].kind == tokWord || sig[i].kind == tokString) {
+			last = sig[i].text
+			if i+1 < len(sig) && sig[i+1].text == "." {
+				i += 2
+				continue
+			}
+			i++
+			break
+		}
+		return last, i
+	}
+	add := func(t string) {
+		if t = cacheTag(t); t != "" && !seen[t] {
+			seen[t] = true
+			tables = append(tables, t)
+		}
+	}
+	for i := 0; i < len(sig); i++ {
+		t := sig[i]
+		if t.kind != tokWord {
+			continue
+		}
+		switch strings.ToLower(t.text) {
+		case "from":
+			// FROM a x, b y
+			for j := i + 1; j < len(sig); {
+				n, next := name(j)
+				if n == "" {
+					break
+				}
+				add(n)
+				// 跳过别名，遇到同层逗号时继续
+				for next < len(sig) && sig[next].depth == t.depth && sig[next].kind == tokWord && !isClauseWord(sig[next].text) {
+					next++
+				}
+				if next < len(sig) && sig[next].text == "," && sig[next].depth == t.depth {
+					j = next + 1
+					continue
+				}
+				break
+			}
+		case "join", "into", "update", "table":
+			if n, _ := name(i + 1); n != "" {
+				add(n)
+			}
+		}
+	}
+	return tables
+}
+
+// isClauseWord 别名之后可能出现的关键字
+func isClauseWord(word string) bool {
+	switch strings.ToLower(word) {
+	case "where", "join", "inner", "left", "right", "full", "cross", "natural", "on", "using", "group", "order",
+		"having", "limit", "offset", "union", "intersect", "except", "window", "for", "fetch", "set", "returning":
+		return true
+	}
+	return false
+}
+
+// sqlWrites 是否为写语句，写语句执行后按 sqlTables 失效。WITH 开头时查找顶层的写关键字
+func sqlWrites(sql string) bool {
+	for i, t := range significant(tokenizeSQL(sql, false)) {
+		if t.kind != tokWord || t.depth != 0 {
+			continue
+		}
+		switch strings.ToLower(t.text) {
+		case "insert", "update", "delete", "replace", "merge", "truncate", "alter", "drop":
+			return true
+		}
+		if i == 0 && !t.is("with") {
+			return false
+		}
+	}
+	return false
+}
+
+type txCacheTagsKey struct{}
+
+// txCacheTags 事务中写过的表，提交后再次失效
+type txCacheTags struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+func (t *txCacheTags) add(tags []string) {
+	t.mu.Lock()
+	t.tags = append(t.tags, tags...)
+	t.mu.Unlock()
+}
+
+// CachePlugin GORM 插件，LoadDataSource 中为每个数据源注册，写操作后使相关表的缓存失效
+type CachePlugin struct{}
+
+func (p *CachePlugin) Name() string {
+	return "fast:cache"
+}
+
+func (p *CachePlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("fast:cache_create", p.invalidate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("fast:cache_update", p.invalidate); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("fast:cache_delete", p.invalidate); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("fast:cache_raw", p.invalidateRaw)
+}
+
+func (p *CachePlugin) invalidate(tx *gorm.DB) {
+	table := tx.Statement.Table
+	if table == "" && tx.Statement.Schema != nil {
+		table = tx.Statement.Schema.Table
+	}
+	if table != "" {
+		p.apply(tx, []string{cacheTag(table)})
+	}
+}
+
+func (p *CachePlugin) invalidateRaw(tx *gorm.DB) {
+	sql := tx.Statement.SQL.String()
+	if sqlWrites(sql) {
+		p.apply(tx, sqlTables(sql))
+	}
+}
+
+func (p *CachePlugin) apply(tx *gorm.DB, tags []string) {
+	ctx := tx.Statement.Context
+	if pending, ok := ctx.Value(txCacheTagsKey{}).(*txCacheTags); ok {
+		pending.add(tags)
+	}
+	invalidateTags(ctx, tags)
+}
+
+// withTxCache 事务提交后使事务中写过的表再次失效
+func withTxCache(ctx context.Context, run func(ctx context.Context) error) error {
+	pending := &txCacheTags{}
+	err := run(context.WithValue(ctx, txCacheTagsKey{}, pending))
+	if err == nil {
+		invalidateTags(ctx, pending.tags)
+	}
+	return err
+}
+
+// MemoryCache 进程内 LRU 缓存，超过 maxEntries 时淘汰最久未使用的条目
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+	tags       map[string]map[string]struct{}
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// NewMemoryCache maxEntries 不大于 0 时不限制条目数
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{maxEntries: maxEntries, lru: list.New(), entries: map[string]*list.Element{}, tags: map[string]map[string]struct{}{}, now: time.Now}
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		m.remove(el)
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return e.value, true
+}
+
+func (m *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	e := &memoryEntry{key: key, value: value, tags: tags}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = m.lru.PushFront(e)
+	for _, t := range tags {
+		keys, ok := m.tags[t]
+		if !ok {
+			keys = map[string]struct{}{}
+			m.tags[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *MemoryCache) Invalidate(_ context.Context, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tags {
+		for key := range m.tags[t] {
+			if el, ok := m.entries[key]; ok {
+				m.remove(el)
+			}
+		}
+		delete(m.tags, t)
+	}
+}
+
+// Len 当前条目数
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+func (m *MemoryCache) remove(el *list.Element) {
+	e := m.lru.Remove(el).(*memoryEntry)
+	delete(m.entries, e.key)
+	for _, t := range e.tags {
+		if keys, ok := m.tags[t]; ok {
+			delete(keys, e.key)
+			if len(keys) == 0 {
+				delete(m.tags, t)
+			}
+		}
+	}
+}
+
+// loadCache 按配置启用内存缓存，已通过 SetQueryCache 设置后端时保持不变
+func loadCache() {
+	fast_base.ConfigAll.UnmarshalKey("cache", &ConfigCache)
+	if ConfigCache.Enable && GetQueryCache() == nil {
+		SetQueryCache(NewMemoryCache(ConfigCache.MaxEntries))
+		fast_base.Logger.Info(fmt.Sprintf("查询缓存已启用, ttl=%ds, maxEntries=%d", ConfigCache.TTL, ConfigCache.MaxEntries))
+	}
+}
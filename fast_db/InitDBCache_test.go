@@ -0,0 +1,216 @@
+package fast_db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openCacheTest(t *testing.T) *MemoryCache {
+	t.Helper()
+	openTestSqlite(t)
+	c := NewMemoryCache(100)
+	SetQueryCache(c)
+	t.Cleanup(func() { SetQueryCache(nil) })
+	return c
+}
+
+// rawRename 绕过 GORM 回调直接修改，缓存无法感知
+func rawRename(t *testing.T, id int64, name string) {
+	t.Helper()
+	sqlDB, _ := DB.DB()
+	if _, err := sqlDB.Exec("UPDATE user SET name = ? WHERE id = ?", name, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetByIdCacheInvalidatedByWrites(t *testing.T) {
+	openCacheTest(t)
+	ctx := context.Background()
+	if err := insertUser(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if u := GetById[testUser](1); u == nil || u.Name != "a" {
+		t.Fatalf("first read: %+v", u)
+	}
+	rawRename(t, 1, "raw")
+	if u := GetById[testUser](1); u == nil || u.Name != "a" {
+		t.Fatalf("second read should hit the cache: %+v", u)
+	}
+	if u := GetByIdContext[testUser](NoCache(ctx), 1); u == nil || u.Name != "raw" {
+		t.Fatalf("NoCache should read the database: %+v", u)
+	}
+
+	if err := DB.Model(&testUser{}).Where("id = ?", 1).Update("name", "b").Error; err != nil {
+		t.Fatal(err)
+	}
+	if u := GetById[testUser](1); u == nil || u.Name != "b" {
+		t.Fatalf("update should invalidate: %+v", u)
+	}
+	if err := DB.Delete(&testUser{}, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u := GetById[testUser](1); u != nil {
+		t.Fatalf("delete should invalidate: %+v", u)
+	}
+}
+
+func TestGetOneCacheNormalizesSQL(t *testing.T) {
+	c := openCacheTest(t)
+	if err := insertUser(context.Background(), 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if u := GetOne[testUser]("SELECT * FROM user WHERE id = ?", 1); u == nil || u.Name != "a" {
+		t.Fatalf("first read: %+v", u)
+	}
+	rawRename(t, 1, "raw")
+	if u := GetOne[testUser]("SELECT *\n  FROM user -- comment\n WHERE id = ?", 1); u == nil || u.Name != "a" {
+		t.Fatalf("reformatted SQL should hit the cache: %+v", u)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("entries: %d", c.Len())
+	}
+	if u := GetOne[testUser]("SELECT * FROM user WHERE id = ?", 2); u != nil {
+		t.Fatalf("other arguments use another key: %+v", u)
+	}
+
+	// Exec 执行的写语句同样失效
+	if err := DB.Exec("UPDATE `user` SET name = ? WHERE id = ?", "b", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if u := GetOne[testUser]("SELECT * FROM user WHERE id = ?", 1); u == nil || u.Name != "b" {
+		t.Fatalf("exec should invalidate: %+v", u)
+	}
+}
+
+type secretUser struct {
+	Model
+	Name  string
+	Email string `json:"-"`
+}
+
+func (secretUser) TableName() string {
+	return "user"
+}
+
+func TestCacheSkipsTypesLostByJSON(t *testing.T) {
+	c := openCacheTest(t)
+	if err := DB.Create(&secretUser{Model: Model{ID: 1}, Name: "a", Email: "a@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if u := GetById[secretUser](1); u == nil || u.Email != "a@example.com" {
+			t.Fatalf("read %d lost the json:\"-\" field: %+v", i+1, u)
+		}
+	}
+	if c.Len() != 0 {
+		t.Fatalf("entries: %d", c.Len())
+	}
+	GetById[testUser](1)
+	if c.Len() != 1 {
+		t.Fatalf("types without lost fields should still be cached, entries: %d", c.Len())
+	}
+}
+
+func TestJSONLossless(t *testing.T) {
+	type nested struct {
+		Users []*secretUser
+	}
+	cases := []struct {
+		name string
+		typ  reflect.Type
+		want bool
+	}{
+		{"model", reflect.TypeFor[testUser](), true},
+		{"string", reflect.TypeFor[string](), true},
+		{"json ignored field", reflect.TypeFor[secretUser](), false},
+		{"nested ignored field", reflect.TypeFor[nested](), false},
+		{"unexported field", reflect.TypeFor[struct{ name string }](), false},
+		{"interface values", reflect.TypeFor[map[string]any](), false},
+	}
+	for _, tc := range cases {
+		if got := jsonLossless(tc.typ); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCacheBypassedInTx(t *testing.T) {
+	openCacheTest(t)
+	ctx := context.Background()
+	if err := insertUser(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	GetById[testUser](1)
+	err := WithTx(ctx, func(ctx context.Context) error {
+		if err := DBFrom(ctx).Model(&testUser{}).Where("id = ?", 1).Update("name", "b").Error; err != nil {
+			return err
+		}
+		if u := GetByIdContext[testUser](ctx, 1); u == nil || u.Name != "b" {
+			t.Errorf("reads in tx should see uncommitted rows: %+v", u)
+		}
+		// 提交前的并发读取把旧数据写回缓存
+		if u := GetById[testUser](1); u == nil || u.Name != "a" {
+			t.Errorf("outside tx: %+v", u)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := GetById[testUser](1); u == nil || u.Name != "b" {
+		t.Fatalf("commit should invalidate: %+v", u)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	c := NewMemoryCache(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Second, []string{"user"})
+	c.Set(ctx, "b", []byte("2"), 0, []string{"order"})
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0, []string{"order"})
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	now = now.Add(time.Second)
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("expired entry returned")
+	}
+	c.Invalidate(ctx, "order")
+	if c.Len() != 0 || len(c.tags) != 0 {
+		t.Fatalf("entries=%d tags=%d", c.Len(), len(c.tags))
+	}
+}
+
+func TestSqlTables(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM user u JOIN `order` o ON o.user_id = u.id":           {"user", "order"},
+		"SELECT * FROM a x, public.b AS y WHERE x.id = y.id":                {"a", "b"},
+		"SELECT * FROM (SELECT id FROM dept) d LEFT JOIN \"Role\" r ON 1=1": {"dept", "role"},
+		"INSERT INTO user (id) SELECT id FROM tmp":                          {"user", "tmp"},
+		"UPDATE LOW_PRIORITY user SET name = 'from x'":                      {"user"},
+		"DELETE FROM user WHERE id IN (SELECT id FROM ban)":                 {"user", "ban"},
+		"SELECT 1": nil,
+	}
+	for sql, want := range cases {
+		if got := sqlTables(sql); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", sql, got, want)
+		}
+	}
+	for sql, want := range map[string]bool{
+		"UPDATE user SET name = 'a'":                     true,
+		"with t as (select 1) delete from user":          true,
+		"SELECT * FROM user FOR UPDATE":                  false,
+		"WITH t AS (SELECT 1 FROM user) SELECT * FROM t": false,
+		"/* update */ SELECT 'delete' FROM user":         false,
+	} {
+		if got := sqlWrites(sql); got != want {
+			t.Errorf("sqlWrites(%s) = %v", sql, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 数据权限：按权限码注册规则，规则根据当前用户的数据范围生成 GORM scope。
+// 当前用户来自令牌(fast_base.CurrentUser)，部门、数据范围等由 SetDataScopeUserProvider 注册的函数提供。
+//
+//	fast_db.RegisterDataScope("order:list", fast_db.DeptDataScope("dept_id", "created_by"))
+//	db.Scopes(fast_db.DataScope(ctx, "order:list")).Find(&orders)
+//
+// 接口注解 @dataScope 放入 context 的权限码作用于 QueryPageListByDB 和 Repository.Page，
+// Repository.WithDataScope 作用于仓储的所有查询、更新和删除。
+
+// 数据范围
+const (
+	DataScopeAll             = "all"             // 全部数据
+	DataScopeDeptAndChildren = "deptAndChildren" // 本部门及下级部门
+	DataScopeDept            = "dept"            // 本部门
+	DataScopeSelf            = "self"            // 仅本人
+	DataScopeNone            = "none"            // 无权限
+)
+
+var (
+	// ErrDataScopeDenied 没有当前用户或无法确定数据范围
+	ErrDataScopeDenied = errors.New("无法确定数据权限，拒绝访问")
+	// ErrDataScopeUnknown 权限码没有注册规则
+	ErrDataScopeUnknown = errors.New("数据权限规则未注册")
+)
+
+// DataScopeUser 计算数据范围用到的用户信息
+type DataScopeUser struct {
+	UserId  int64
+	DeptId  int64
+	DeptIds []int64 // 本部门及下级部门
+	Level   string  // 数据范围，DataScopeAll 等
+}
+
+// DataScopeUserProvider 根据当前用户和权限码提供数据范围，通常按用户角色查询并缓存
+type DataScopeUserProvider func(ctx context.Context, user fast_base.CurrentUser, code string) (*DataScopeUser, error)
+
+// DataScopeRule 把数据范围转换为查询条件
+type DataScopeRule func(user *DataScopeUser) (func(*gorm.DB) *gorm.DB, error)
+
+var dataScopeLock sync.RWMutex
+var dataScopeRules = map[string]DataScopeRule{}
+var dataScopeProvider DataScopeUserProvider = func(_ context.Context, user fast_base.CurrentUser, _ string) (*DataScopeUser, error) {
+	return &DataScopeUser{UserId: user.UserId, Level: DataScopeSelf}, nil
+}
+
+// RegisterDataScope 注册权限码对应的规则，同名覆盖
+func RegisterDataScope(code string, rule DataScopeRule) {
+	dataScopeLock.Lock()
+	dataScopeRules[code] = rule
+	dataScopeLock.Unlock()
+}
+
+// SetDataScopeUserProvider 设置用户数据范围的提供者，默认只有本人数据
+func SetDataScopeUserProvider(provider DataScopeUserProvider) {
+	dataScopeLock.Lock()
+	dataScopeProvider = provider
+	dataScopeLock.Unlock()
+}
+
+// DataScope 返回权限码对应的 GORM scope。无法确定数据范围时向 db 添加错误，查询不会执行
+func DataScope(ctx context.Context, code string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		scope, err := resolveDataScope(ctx, code)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		return scope(db)
+	}
+}
+
+func resolveDataScope(ctx context.Context, code string) (func(*gorm.DB) *gorm.DB, error) {
+	dataScopeLock.RLock()
+	rule, ok := dataScopeRules[code]
+	provider := dataScopeProvider
+	dataScopeLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDataScopeUnknown, code)
+	}
+	user, ok := fast_base.CurrentUserFrom(ctx)
+	if !ok {
+		return nil, ErrDataScopeDenied
+	}
+	scopeUser, err := provider(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if scopeUser == nil {
+		return nil, ErrDataScopeDenied
+	}
+	return rule(scopeUser)
+}
+
+// DeptDataScope 常用的部门数据权限：按用户的数据范围过滤部门列 deptColumn 或创建人列 userColumn
+func DeptDataScope(deptColumn, userColumn string) DataScopeRule {
+	column := func(name string) clause.Column {
+		return clause.Column{Table: clause.CurrentTable, Name: name}
+	}
+	return func(user *DataScopeUser) (func(*gorm.DB) *gorm.DB, error) {
+		var expr clause.Expression
+		switch user.Level {
+		case DataScopeAll:
+			return func(db *gorm.DB) *gorm.DB { return db }, nil
+		case DataScopeDeptAndChildren:
+			ids := make([]any, 0, len(user.DeptIds)+1)
+			ids = append(ids, user.DeptId)
+			for _, id := range user.DeptIds {
+				if id != user.DeptId {
+					ids = append(ids, id)
+				}
+			}
+			expr = clause.IN{Column: column(deptColumn), Values: ids}
+		case DataScopeDept:
+			expr = clause.Eq{Column: column(deptColumn), Value: user.DeptId}
+		case DataScopeSelf:
+			expr = clause.Eq{Column: column(userColumn), Value: user.UserId}
+		default:
+			// 无权限或未知范围：不返回任何数据
+			expr = clause.Expr{SQL: "1 = 0"}
+		}
+		return func(db *gorm.DB) *gorm.DB { return db.Where(expr) }, nil
+	}
+}
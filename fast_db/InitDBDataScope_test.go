@@ -0,0 +1,165 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+type scopedOrder struct {
+	AuditModel
+	DeptId int64
+	Name   string
+}
+
+// openDataScopeTest 用户 1 属于部门 10(下级部门 11)，用户 2 属于部门 11，用户 3 属于部门 20
+func openDataScopeTest(t *testing.T, levels map[int64]string) {
+	t.Helper()
+	openTestSqlite(t)
+	if err := DB.Exec("CREATE TABLE scoped_order (id INTEGER PRIMARY KEY, dept_id INTEGER, name TEXT, created_by INTEGER, updated_by INTEGER, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)").Error; err != nil {
+		t.Fatal(err)
+	}
+	rows := []scopedOrder{
+		{AuditModel: AuditModel{Model: Model{ID: 1}, CreatedBy: 1}, DeptId: 10, Name: "a"},
+		{AuditModel: AuditModel{Model: Model{ID: 2}, CreatedBy: 2}, DeptId: 11, Name: "b"},
+		{AuditModel: AuditModel{Model: Model{ID: 3}, CreatedBy: 3}, DeptId: 20, Name: "c"},
+		{AuditModel: AuditModel{Model: Model{ID: 4}, CreatedBy: 2}, DeptId: 10, Name: "d"},
+	}
+	if err := DB.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	depts := map[int64]int64{1: 10, 2: 11, 3: 20}
+	RegisterDataScope("order:list", DeptDataScope("dept_id", "created_by"))
+	SetDataScopeUserProvider(func(_ context.Context, user fast_base.CurrentUser, code string) (*DataScopeUser, error) {
+		u := &DataScopeUser{UserId: user.UserId, DeptId: depts[user.UserId], Level: levels[user.UserId]}
+		if u.DeptId == 10 {
+			u.DeptIds = []int64{10, 11}
+		}
+		return u, nil
+	})
+	t.Cleanup(func() {
+		dataScopeLock.Lock()
+		delete(dataScopeRules, "order:list")
+		dataScopeLock.Unlock()
+		SetDataScopeUserProvider(func(_ context.Context, user fast_base.CurrentUser, _ string) (*DataScopeUser, error) {
+			return &DataScopeUser{UserId: user.UserId, Level: DataScopeSelf}, nil
+		})
+	})
+}
+
+func userCtx(userId int64) context.Context {
+	return fast_base.WithCurrentUser(context.Background(), fast_base.CurrentUser{UserId: userId})
+}
+
+func scopedNames(t *testing.T, ctx context.Context, code string) []string {
+	t.Helper()
+	var list []scopedOrder
+	if err := DB.WithContext(ctx).Scopes(DataScope(ctx, code)).Order("id").Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(list))
+	for i, o := range list {
+		names[i] = o.Name
+	}
+	return names
+}
+
+func TestDeptDataScopeLevels(t *testing.T) {
+	cases := []struct {
+		level string
+		user  int64
+		want  string
+	}{
+		{DataScopeAll, 1, "abcd"},
+		{DataScopeDeptAndChildren, 1, "abd"},
+		{DataScopeDept, 1, "ad"},
+		{DataScopeSelf, 2, "bd"},
+		{DataScopeNone, 1, ""},
+		{"unknown", 1, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.level, func(t *testing.T) {
+			openDataScopeTest(t, map[int64]string{c.user: c.level})
+			got := ""
+			for _, n := range scopedNames(t, userCtx(c.user), "order:list") {
+				got += n
+			}
+			if got != c.want {
+				t.Fatalf("level %s got %q, want %q", c.level, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDataScopeFailsClosed(t *testing.T) {
+	openDataScopeTest(t, map[int64]string{1: DataScopeAll})
+	var list []scopedOrder
+
+	err := DB.Scopes(DataScope(context.Background(), "order:list")).Find(&list).Error
+	if !errors.Is(err, ErrDataScopeDenied) {
+		t.Fatalf("missing user: %v", err)
+	}
+	err = DB.Scopes(DataScope(userCtx(1), "order:missing")).Find(&list).Error
+	if !errors.Is(err, ErrDataScopeUnknown) {
+		t.Fatalf("unknown code: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("rows returned on error: %d", len(list))
+	}
+}
+
+func TestRepositoryWithDataScope(t *testing.T) {
+	openDataScopeTest(t, map[int64]string{1: DataScopeDept})
+	ctx := userCtx(1)
+	repo := NewRepository[scopedOrder]()
+	scoped := repo.WithDataScope("order:list")
+
+	list, err := scoped.FindByIds(ctx, 1, 2, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("scoped list: %d", len(list))
+	}
+	if _, err := scoped.FindById(ctx, 3); !errors.Is(err, ErrNotFound) {
+		t.Fatal("row of other dept should not be visible")
+	}
+	// 权限外的数据不会被删除
+	if err := scoped.Delete(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FindById(ctx, 3); err != nil {
+		t.Fatalf("row of other dept was deleted: %v", err)
+	}
+	// 原仓储不受影响
+	all, err := repo.FindByIds(ctx, 1, 2, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("unscoped list: %d", len(all))
+	}
+}
+
+func TestDataScopeFromContext(t *testing.T) {
+	openDataScopeTest(t, map[int64]string{2: DataScopeSelf})
+	ctx := fast_base.WithDataScope(userCtx(2), "order:list")
+
+	page, err := NewRepository[scopedOrder]().Page(ctx, PageQuery{PageParams: fast_base.PageParams{PageIndex: 1, PageSize: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalRows != 2 {
+		t.Fatalf("repository page total: %d", page.TotalRows)
+	}
+
+	result, err := QueryPageListByDB[scopedOrder](fast_base.PageParams{PageIndex: 1, PageSize: 10}, DB.WithContext(ctx).Model(&scopedOrder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalRows != 2 || len(*result.List) != 2 {
+		t.Fatalf("page total: %d", result.TotalRows)
+	}
+}
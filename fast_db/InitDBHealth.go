@@ -0,0 +1,42 @@
+package fast_db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// registerHealthChecks 注册数据库连通性与迁移版本检查，供 /readyz 使用。检查项名称为 db.{数据源名称}
+func registerHealthChecks(name string, db *gorm.DB) {
+	name = "db." + name
+	fast_base.RegisterHealthCheck(name, fast_base.HealthReadiness, func(ctx context.Context) error {
+		return pingGorm(ctx, db)
+	})
+	fast_base.RegisterHealthCheck(name+".migration", fast_base.HealthReadiness, func(ctx context.Context) error {
+		return checkMigrationVersion(ctx, db)
+	})
+}
+
+// checkMigrationVersion 检查 golang-migrate 的版本表，dirty 说明上次迁移中途失败，需要人工处理
+func checkMigrationVersion(ctx context.Context, db *gorm.DB) error {
+	if !db.WithContext(ctx).Migrator().HasTable("schema_migrations") {
+		// 未使用数据库迁移
+		return nil
+	}
+	var version sql.NullInt64
+	var dirty bool
+	row := db.WithContext(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Row()
+	if err := row.Scan(&version, &dirty); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("迁移版本 %d 处于 dirty 状态", version.Int64)
+	}
+	return nil
+}
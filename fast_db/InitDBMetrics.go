@@ -0,0 +1,108 @@
+package fast_db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	dbStatementDuration = fast_base.NewHistogramVec("db_statement_duration_seconds", "SQL 执行耗时(秒)", nil, "source", "table", "operation")
+	dbStatementErrors   = fast_base.NewCounterVec("db_statement_errors_total", "SQL 执行失败次数", "source", "table", "operation")
+)
+
+const metricsStartKey = "fast:metrics_start"
+
+// registerMetrics 通过 GORM 回调统计每张表、每类操作的耗时，并采集连接池状态(sql.DBStats)
+func registerMetrics(name string, db *gorm.DB) {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(metricsStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(metricsStartKey)
+			if !ok {
+				return
+			}
+			table := tx.Statement.Table
+			if table == "" {
+				table = "raw"
+			}
+			dbStatementDuration.Observe(time.Since(v.(time.Time)).Seconds(), name, table, operation)
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				dbStatementErrors.Inc(name, table, operation)
+			}
+		}
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("*").Register("fast:metrics_before_create", before)
+	cb.Create().After("*").Register("fast:metrics_after_create", after("create"))
+	cb.Query().Before("*").Register("fast:metrics_before_query", before)
+	cb.Query().After("*").Register("fast:metrics_after_query", after("query"))
+	cb.Update().Before("*").Register("fast:metrics_before_update", before)
+	cb.Update().After("*").Register("fast:metrics_after_update", after("update"))
+	cb.Delete().Before("*").Register("fast:metrics_before_delete", before)
+	cb.Delete().After("*").Register("fast:metrics_after_delete", after("delete"))
+	cb.Row().Before("*").Register("fast:metrics_before_row", before)
+	cb.Row().After("*").Register("fast:metrics_after_row", after("row"))
+	cb.Raw().Before("*").Register("fast:metrics_before_raw", before)
+	cb.Raw().After("*").Register("fast:metrics_after_raw", after("raw"))
+
+	registerPoolMetrics(name, db)
+}
+
+// registerPoolMetrics 采集连接池状态，只读副本也单独注册
+func registerPoolMetrics(name string, db *gorm.DB) {
+	metricsPoolsLock.Lock()
+	metricsPools[name] = db
+	metricsPoolsLock.Unlock()
+	fast_base.RegisterMetricsCollector("db", collectPoolMetrics)
+}
+
+var metricsPoolsLock sync.Mutex
+var metricsPools = map[string]*gorm.DB{}
+
+// collectPoolMetrics 输出所有数据源的连接池状态。同一指标的样本需连续输出，因此按指标逐个遍历数据源
+func collectPoolMetrics(w *fast_base.MetricsWriter) {
+	metricsPoolsLock.Lock()
+	names := make([]string, 0, len(metricsPools))
+	stats := map[string]sql.DBStats{}
+	for name, db := range metricsPools {
+		if sqlDB, err := db.DB(); err == nil {
+			names = append(names, name)
+			stats[name] = sqlDB.Stats()
+		}
+	}
+	metricsPoolsLock.Unlock()
+	sort.Strings(names)
+
+	metrics := []struct {
+		name, help string
+		counter    bool
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_pool_max_open_connections", "连接池最大连接数", false, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_pool_open_connections", "连接池当前连接数", false, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_pool_in_use_connections", "使用中的连接数", false, func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_pool_idle_connections", "空闲连接数", false, func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"db_pool_wait_count_total", "等待连接的总次数", true, func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_pool_wait_duration_seconds_total", "等待连接的总耗时(秒)", true, func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_pool_max_idle_closed_total", "因超过最大空闲数关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_pool_max_idle_time_closed_total", "因超过最大空闲时长关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_pool_max_lifetime_closed_total", "因超过最大存活时长关闭的连接数", true, func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, m := range metrics {
+		for _, name := range names {
+			if m.counter {
+				w.Counter(m.name, m.help, m.value(stats[name]), "source", name)
+			} else {
+				w.Gauge(m.name, m.help, m.value(stats[name]), "source", name)
+			}
+		}
+	}
+}
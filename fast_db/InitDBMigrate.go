@@ -1,68 +1,397 @@
 package fast_db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/mysql"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/golang-migrate/migrate/v4/database/mysql"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/tdwu/fast_go/fast_base"
 )
 
-func migrateDB() {
+// 数据库迁移：脚本可以来自目录(migrationDir)，也可以通过 RegisterMigrations 注册 embed.FS 随二进制发布。
+// 启动时按 autoMigrate 自动执行 Up；其余操作(回滚、跳转、修复 dirty)通过 Migrator 或 MigrateCommand 执行。
+
+// ErrNoMigrations 数据源没有配置迁移脚本
+var ErrNoMigrations = errors.New("未配置迁移脚本")
+
+var migrationsLock sync.RWMutex
+var migrationsFS = map[string]fs.FS{}
+
+// RegisterMigrations 为数据源注册内嵌的迁移脚本，优先于 migrationDir。需在 LoadDataSource 之前调用：
+//
+//	//go:embed db/migration/*.sql
+//	var migrations embed.FS
+//	sub, _ := fs.Sub(migrations, "db/migration")
+//	fast_db.RegisterMigrations("default", sub)
+func RegisterMigrations(name string, fsys fs.FS) {
+	migrationsLock.Lock()
+	defer migrationsLock.Unlock()
+	migrationsFS[strings.ToLower(name)] = fsys
+}
+
+func hasMigrations(name string, conf DataSourceConfig) bool {
+	migrationsLock.RLock()
+	defer migrationsLock.RUnlock()
+	return migrationsFS[strings.ToLower(name)] != nil || conf.MigrationDir != ""
+}
+
+// MigrationFile 一个迁移版本
+type MigrationFile struct {
+	Version  uint   `json:"version"`
+	Name     string `json:"name"`
+	Applied  bool   `json:"applied"`
+	Checksum string `json:"checksum"` // up 脚本的 SHA-256
+	Modified bool   `json:"modified"` // 已执行后脚本内容被修改
+}
 
-	m, err := migrate.New(fmt.Sprintf("file://%s", "./conf/db/migration"), "mysql://"+ConfigDataSource.DNS())
-	defer func() {
+// MigrationStatus 数据库当前版本与所有迁移脚本
+type MigrationStatus struct {
+	Version uint            `json:"version"` // 0 表示尚未执行任何迁移
+	Dirty   bool            `json:"dirty"`
+	Files   []MigrationFile `json:"files"`
+}
+
+// Migrator 数据源的迁移操作，用完需 Close
+type Migrator struct {
+	Name string
+	conf DataSourceConfig
+	src  source.Driver
+	m    *migrate.Migrate
+	db   *sql.DB // 迁移锁与校验和使用的独立连接
+}
+
+// NewMigrator 按已加载的数据源配置创建迁移器
+func NewMigrator(name string) (*Migrator, error) {
+	conf, ok := ConfigDataSources[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.New("数据源不存在: " + name)
+	}
+	return NewMigratorWithConfig(name, conf)
+}
+
+// NewMigratorWithConfig 按指定配置创建迁移器
+func NewMigratorWithConfig(name string, conf DataSourceConfig) (*Migrator, error) {
+	src, err := openMigrationSource(name, conf)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("migrations", src, conf.MigrateURL())
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	m.Log = migrateLogger{name: name}
+	db, err := sql.Open(sqlDriverName(conf.DriverName), conf.DNS())
+	if err != nil {
 		m.Close()
-	}()
+		return nil, err
+	}
+	return &Migrator{Name: name, conf: conf, src: src, m: m, db: db}, nil
+}
 
+func openMigrationSource(name string, conf DataSourceConfig) (source.Driver, error) {
+	migrationsLock.RLock()
+	fsys := migrationsFS[strings.ToLower(name)]
+	migrationsLock.RUnlock()
+	if fsys != nil {
+		return iofs.New(fsys, ".")
+	}
+	if conf.MigrationDir == "" {
+		return nil, ErrNoMigrations
+	}
+	return (&file.File{}).Open("file://" + conf.MigrationDir)
+}
+
+func (t *Migrator) Close() error {
+	t.db.Close()
+	srcErr, dbErr := t.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
+
+// Status 当前版本及每个脚本是否已执行
+func (t *Migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := t.version()
+	if err != nil {
+		return nil, err
+	}
+	files, err := t.files()
 	if err != nil {
-		fast_base.Logger.Fatal("migration failed...\n" + err.Error())
-		panic("migration failed..." + err.Error())
+		return nil, err
+	}
+	checksums, err := t.appliedChecksums(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		files[i].Applied = version > 0 && files[i].Version <= version
+		recorded, ok := checksums[files[i].Version]
+		files[i].Modified = files[i].Applied && ok && recorded != files[i].Checksum
 	}
+	return &MigrationStatus{Version: version, Dirty: dirty, Files: files}, nil
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		fast_base.Logger.Fatal("An error occurred while syncing the database...\n" + err.Error())
-		panic("An error occurred while syncing the database...\n" + err.Error())
+// Pending 尚未执行的脚本
+func (t *Migrator) Pending() ([]MigrationFile, error) {
+	status, err := t.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []MigrationFile
+	for _, f := range status.Files {
+		if !f.Applied {
+			pending = append(pending, f)
+		}
 	}
+	return pending, nil
 }
 
-func migrateDBStepByStep() {
-	// 连接数据库
-	db, err := sql.Open("mysql", ConfigDataSource.DNS())
-	defer func() {
-		db.Close()
-	}()
+// DryRun 只输出待执行的脚本，不修改数据库
+func (t *Migrator) DryRun(out io.Writer) error {
+	pending, err := t.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Fprintf(out, "[%s] 没有待执行的迁移\n", t.Name)
+		return nil
+	}
+	for _, f := range pending {
+		fmt.Fprintf(out, "[%s] 待执行: %s\n", t.Name, f.fileName())
+	}
+	return nil
+}
 
+// Up 执行 n 个版本，n <= 0 时执行全部
+func (t *Migrator) Up(n int) error {
+	return t.guard("up", func() error {
+		if n <= 0 {
+			return t.m.Up()
+		}
+		return t.m.Steps(n)
+	})
+}
+
+// Down 回滚 n 个版本。不提供全部回滚，避免误删所有表
+func (t *Migrator) Down(n int) error {
+	if n <= 0 {
+		return errors.New("回滚需要指定大于 0 的步数")
+	}
+	return t.guard("down", func() error { return t.m.Steps(-n) })
+}
+
+// Goto 迁移到指定版本，可升可降
+func (t *Migrator) Goto(version uint) error {
+	return t.guard("goto", func() error { return t.m.Migrate(version) })
+}
+
+// Force 强制设置版本并清除 dirty 标记，不执行脚本。用于手工修复失败的迁移后恢复，-1 表示清空版本。
+// 同时按当前脚本重新记录校验和，即确认脚本的修改
+func (t *Migrator) Force(version int) error {
+	timeout := t.conf.MigrationLockTimeout * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx := context.Background()
+	unlock, err := t.lock(ctx, timeout)
 	if err != nil {
-		fast_base.Logger.Fatal("could not ping DB...\n" + err.Error())
-		panic("could not ping DB..." + err.Error())
-	}
-	if err := db.Ping(); err != nil {
-		fast_base.Logger.Fatal("could not ping DB...\n" + err.Error())
-		panic("could not ping DB..." + err.Error())
-	}
-
-	// 开始迁移
-	driver, _ := mysql.WithInstance(db, &mysql.Config{})
-	defer func() {
-		driver.Close()
-	}()
-	m, err := migrate.NewWithDatabaseInstance(
-		fmt.Sprintf("file://%s", "./conf/db/migration"), // file://path/to/directory
-		"mysql", driver)
+		return err
+	}
+	defer unlock()
+	if err := t.m.Force(version); err != nil {
+		return err
+	}
+	return t.syncChecksums(ctx, true)
+}
+
+func (t *Migrator) version() (uint, bool, error) {
+	version, dirty, err := t.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (t *Migrator) files() ([]MigrationFile, error) {
+	var files []MigrationFile
+	version, err := t.src.First()
+	for err == nil {
+		f := MigrationFile{Version: version}
+		if r, identifier, e := t.src.ReadUp(version); e == nil {
+			content, e := io.ReadAll(r)
+			r.Close()
+			if e != nil {
+				return nil, e
+			}
+			f.Name, f.Checksum = identifier, checksum(content)
+		}
+		files = append(files, f)
+		version, err = t.src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (t *Migrator) wrap(err error) error {
+	var dirty migrate.ErrDirty
+	switch {
+	case err == nil, errors.Is(err, migrate.ErrNoChange):
+		return nil
+	case errors.As(err, &dirty):
+		return fmt.Errorf("数据源 %s 的迁移版本 %d 处于 dirty 状态，请手工修复数据库后执行 migrate force <版本号>", t.Name, dirty.Version)
+	default:
+		return err
+	}
+}
+
+func (f MigrationFile) fileName() string {
+	return fmt.Sprintf("%d_%s.up.sql", f.Version, f.Name)
+}
+
+// autoMigrate 启动时执行全部待执行的迁移
+func autoMigrate(name string, conf DataSourceConfig) error {
+	m, err := NewMigratorWithConfig(name, conf)
 	if err != nil {
-		fast_base.Logger.Fatal("migration failed...\n" + err.Error())
-		panic("migration failed..." + err.Error())
+		return err
 	}
-	defer func() {
-		m.Close()
-	}()
+	defer m.Close()
+	return m.Up(0)
+}
 
-	// 执行操作: up --> 更新,  down --> 回滚
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		fast_base.Logger.Fatal("An error occurred while syncing the database...\n" + err.Error())
-		panic("An error occurred while syncing the database...\n" + err.Error())
+// verifyMigrations 不自动迁移时，仍拒绝已执行脚本被修改的情况
+func verifyMigrations(name string, conf DataSourceConfig) error {
+	m, err := NewMigratorWithConfig(name, conf)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.VerifyChecksums()
+}
+
+type migrateLogger struct {
+	name string
+}
+
+func (l migrateLogger) Printf(format string, v ...interface{}) {
+	fast_base.Logger.Info("[migrate:" + l.name + "] " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
+
+const migrateUsage = `用法: migrate [-source 数据源] <命令>
+  status       查看当前版本和脚本执行情况
+  up [N]       执行 N 个版本，省略时执行全部
+  down N       回滚 N 个版本
+  goto V       迁移到版本 V
+  force V      强制设置版本为 V，清除 dirty 标记并重新记录校验和(不执行脚本)
+  dry-run      列出待执行的脚本`
+
+// MigrateCommand 执行迁移子命令，需先 LoadConfig、LoadLogger。应用可在 main 中接入：
+//
+//	if flag.Arg(0) == "migrate" {
+//		if err := fast_db.MigrateCommand(flag.Args()[1:], os.Stdout); err != nil {
+//			fmt.Println(err)
+//			os.Exit(1)
+//		}
+//		return
+//	}
+func MigrateCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	sourceName := flags.String("source", DefaultDataSource, "数据源名称")
+	flags.Usage = func() { fmt.Fprintln(out, migrateUsage) }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("缺少迁移命令")
+	}
+	if len(ConfigDataSources) == 0 {
+		ConfigDataSources = loadDataSourceConfigs()
+	}
+
+	m, err := NewMigrator(*sourceName)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	command, arg := flags.Arg(0), flags.Arg(1)
+	switch command {
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(out, m.Name, status)
+		return nil
+	case "up":
+		n := 0
+		if arg != "" {
+			if n, err = strconv.Atoi(arg); err != nil {
+				return errors.New("步数必须是整数: " + arg)
+			}
+		}
+		return m.Up(n)
+	case "down":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return errors.New("回滚需要指定步数: migrate down N")
+		}
+		return m.Down(n)
+	case "goto":
+		v, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return errors.New("版本号必须是非负整数: " + arg)
+		}
+		return m.Goto(uint(v))
+	case "force":
+		v, err := strconv.Atoi(arg)
+		if err != nil {
+			return errors.New("版本号必须是整数: " + arg)
+		}
+		return m.Force(v)
+	case "dry-run":
+		return m.DryRun(out)
+	default:
+		flags.Usage()
+		return errors.New("未知的迁移命令: " + command)
+	}
+}
+
+func printMigrationStatus(out io.Writer, name string, status *MigrationStatus) {
+	state := ""
+	if status.Dirty {
+		state = " (dirty)"
+	}
+	fmt.Fprintf(out, "数据源: %s  当前版本: %d%s\n", name, status.Version, state)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED\tNAME")
+	for _, f := range status.Files {
+		fmt.Fprintf(w, "%d\t%v\t%s\n", f.Version, f.Applied, f.Name)
 	}
+	w.Flush()
 }
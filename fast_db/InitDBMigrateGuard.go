@@ -0,0 +1,347 @@
+package fast_db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+// 迁移安全：多实例同时启动时用数据库咨询锁串行执行迁移；记录已执行脚本的校验和，
+// 脚本被修改后拒绝启动；执行迁移前可运行备份命令或自定义钩子。
+
+const migrationChecksumTable = "schema_migrations_checksum"
+
+// PreMigrateEvent 迁移前钩子的参数
+type PreMigrateEvent struct {
+	Source    string           // 数据源名称
+	Config    DataSourceConfig // 数据源配置
+	Operation string           // up down goto
+	Version   uint             // 当前版本
+	Pending   []MigrationFile  // up 时待执行的脚本
+}
+
+// PreMigrateHook 迁移前钩子，返回错误时放弃本次迁移
+type PreMigrateHook func(ctx context.Context, event PreMigrateEvent) error
+
+var preMigrateHooksLock sync.RWMutex
+var preMigrateHooks []PreMigrateHook
+
+// RegisterPreMigrateHook 注册迁移前钩子(如备份)，在获得迁移锁之后、执行脚本之前调用
+func RegisterPreMigrateHook(hook PreMigrateHook) {
+	preMigrateHooksLock.Lock()
+	defer preMigrateHooksLock.Unlock()
+	preMigrateHooks = append(preMigrateHooks, hook)
+}
+
+// guard 加锁并校验，执行 apply 后同步校验和
+func (t *Migrator) guard(operation string, apply func() error) error {
+	timeout := t.conf.MigrationLockTimeout * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx := context.Background()
+	unlock, err := t.lock(ctx, timeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.ensureChecksumTable(ctx); err != nil {
+		return err
+	}
+	if err := t.VerifyChecksums(); err != nil {
+		return err
+	}
+
+	version, _, err := t.version()
+	if err != nil {
+		return err
+	}
+	event := PreMigrateEvent{Source: t.Name, Config: t.conf, Operation: operation, Version: version}
+	if operation == "up" {
+		if event.Pending, err = t.Pending(); err != nil {
+			return err
+		}
+		if len(event.Pending) == 0 {
+			return t.syncChecksums(ctx, false)
+		}
+	}
+	if err := t.beforeMigrate(ctx, event); err != nil {
+		return fmt.Errorf("迁移前钩子执行失败，已放弃迁移: %w", err)
+	}
+
+	err = t.wrap(apply())
+	// 失败时也同步，已成功执行的版本需要记录
+	if syncErr := t.syncChecksums(ctx, false); err == nil {
+		err = syncErr
+	}
+	return err
+}
+
+func (t *Migrator) beforeMigrate(ctx context.Context, event PreMigrateEvent) error {
+	if t.conf.MigrationBackupCommand != "" {
+		if err := runBackupCommand(ctx, t.conf.MigrationBackupCommand, event); err != nil {
+			return err
+		}
+	}
+	preMigrateHooksLock.RLock()
+	hooks := append([]PreMigrateHook(nil), preMigrateHooks...)
+	preMigrateHooksLock.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBackupCommand 通过 shell 执行备份命令，连接信息以环境变量传入，避免密码出现在配置的命令行中
+func runBackupCommand(ctx context.Context, command string, event PreMigrateEvent) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"FAST_DB_SOURCE="+event.Source,
+		"FAST_DB_DRIVER="+event.Config.DriverName,
+		"FAST_DB_HOST="+event.Config.Host,
+		"FAST_DB_PORT="+event.Config.Port,
+		"FAST_DB_DATABASE="+event.Config.Database,
+		"FAST_DB_USER="+event.Config.Username,
+		"FAST_DB_PASSWORD="+event.Config.Password,
+		"FAST_DB_VERSION="+strconv.FormatUint(uint64(event.Version), 10),
+		"FAST_DB_OPERATION="+event.Operation,
+	)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		fast_base.Logger.Info("[migrate:" + event.Source + "] 备份命令输出：" + strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// ////////////////////////////////// 咨询锁 //////////////////////////////////
+
+// sqlite 只有单机场景，用进程内锁
+var localMigrationLocks sync.Map
+
+func (t *Migrator) lock(ctx context.Context, timeout time.Duration) (func(), error) {
+	name := "fast_db_migrate:" + t.conf.Database
+	timeoutErr := fmt.Errorf("等待迁移锁超时(%s)，可能有其他实例正在执行迁移", timeout)
+
+	switch t.conf.DriverName {
+	case DriverSqlite:
+		v, _ := localMigrationLocks.LoadOrStore(name, make(chan struct{}, 1))
+		ch := v.(chan struct{})
+		select {
+		case ch <- struct{}{}:
+			return func() { <-ch }, nil
+		case <-time.After(timeout):
+			return nil, timeoutErr
+		}
+	}
+
+	// 咨询锁与连接绑定，加锁和解锁必须使用同一个连接
+	conn, err := t.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch t.conf.DriverName {
+	case DriverPostgres:
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		key := int64(h.Sum64())
+		deadline := time.Now().Add(timeout)
+		for {
+			var ok bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				conn.Close()
+				return nil, timeoutErr
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			conn.Close()
+		}, nil
+	default:
+		var ok sql.NullInt64
+		seconds := int(timeout.Seconds())
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, seconds).Scan(&ok); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if ok.Int64 != 1 {
+			conn.Close()
+			return nil, timeoutErr
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			conn.Close()
+		}, nil
+	}
+}
+
+// ////////////////////////////////// 校验和 //////////////////////////////////
+
+func (t *Migrator) ensureChecksumTable(ctx context.Context) error {
+	_, err := t.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationChecksumTable+
+		" (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum CHAR(64) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	return err
+}
+
+// appliedChecksums 已记录的校验和，表不存在时返回空。权限、超时、断连等错误原样返回，不能跳过校验
+func (t *Migrator) appliedChecksums(ctx context.Context) (map[uint]string, error) {
+	exists, err := t.tableExists(ctx, migrationChecksumTable)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// 尚未执行过带校验的迁移
+		return map[uint]string{}, nil
+	}
+	rows, err := t.db.QueryContext(ctx, "SELECT version, checksum FROM "+migrationChecksumTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	checksums := map[uint]string{}
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[uint(version)] = strings.TrimSpace(checksum)
+	}
+	return checksums, rows.Err()
+}
+
+// tableExists 查询表是否存在。不使用 gorm 的 HasTable，它把查询错误当作表不存在
+func (t *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	var query string
+	switch t.conf.DriverName {
+	case DriverPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1"
+	case DriverSqlite:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"
+	}
+	var count int64
+	if err := t.db.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// VerifyChecksums 已执行的脚本内容被修改时返回错误
+func (t *Migrator) VerifyChecksums() error {
+	status, err := t.Status()
+	if err != nil {
+		return err
+	}
+	var modified []string
+	for _, f := range status.Files {
+		if f.Modified {
+			modified = append(modified, f.fileName())
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("数据源 %s 已执行的迁移脚本被修改: %s。请恢复原脚本，或确认无误后执行 migrate force <当前版本> 重新记录校验和", t.Name, strings.Join(modified, ", "))
+	}
+	return nil
+}
+
+// syncChecksums 记录已执行版本的校验和，删除已回滚版本的记录。overwrite 为 true 时覆盖已有记录
+func (t *Migrator) syncChecksums(ctx context.Context, overwrite bool) error {
+	if err := t.ensureChecksumTable(ctx); err != nil {
+		return err
+	}
+	version, _, err := t.version()
+	if err != nil {
+		return err
+	}
+	files, err := t.files()
+	if err != nil {
+		return err
+	}
+	recorded, err := t.appliedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, t.rebind("DELETE FROM "+migrationChecksumTable+" WHERE version > ?"), int64(version)); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if version == 0 || f.Version > version {
+			continue
+		}
+		if _, ok := recorded[f.Version]; ok && !overwrite {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, t.rebind("DELETE FROM "+migrationChecksumTable+" WHERE version = ?"), int64(f.Version)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, t.rebind("INSERT INTO "+migrationChecksumTable+" (version, name, checksum) VALUES (?, ?, ?)"), int64(f.Version), f.Name, f.Checksum); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// rebind PostgreSQL 使用 $n 占位符
+func (t *Migrator) rebind(query string) string {
+	if t.conf.DriverName != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// sqlDriverName database/sql 中注册的驱动名
+func sqlDriverName(driverName string) string {
+	switch driverName {
+	case DriverPostgres:
+		return "pgx"
+	case DriverSqlite:
+		return "sqlite3"
+	default:
+		return "mysql"
+	}
+}
@@ -0,0 +1,164 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+// newGuardTestConfig 复制 testdata 中的脚本到临时目录，便于修改
+func newGuardTestConfig(t *testing.T) DataSourceConfig {
+	t.Helper()
+	fast_base.Logger = zap.NewNop()
+	dir := t.TempDir()
+	entries, err := os.ReadDir("./testdata/migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		content, _ := os.ReadFile(filepath.Join("./testdata/migration", e.Name()))
+		os.WriteFile(filepath.Join(dir, e.Name()), content, 0o644)
+	}
+	conf := newDataSourceConfig(dir)
+	conf.DriverName = DriverSqlite
+	conf.Database = filepath.Join(t.TempDir(), "guard.db")
+	conf.MigrationLockTimeout = 1
+	return conf.applyDriverDefaults()
+}
+
+// latestTestMigration testdata 中最新的迁移版本，版本号连续
+func latestTestMigration(t *testing.T) uint {
+	t.Helper()
+	files, err := filepath.Glob("./testdata/migration/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint(len(files))
+}
+
+func TestMigrationChecksumMismatchIsRefused(t *testing.T) {
+	conf := newGuardTestConfig(t)
+	if err := autoMigrate("guard_test", conf); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(conf.MigrationDir, "1_create_user.up.sql"), []byte("CREATE TABLE user (id INTEGER);"), 0o644)
+	err := autoMigrate("guard_test", conf)
+	if err == nil || !strings.Contains(err.Error(), "1_create_user.up.sql") {
+		t.Fatalf("modified migration should be refused, got %v", err)
+	}
+	if err := verifyMigrations("guard_test", conf); err == nil {
+		t.Fatal("verification without auto migrate should fail too")
+	}
+
+	m, err := NewMigratorWithConfig("guard_test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.Force(int(latestTestMigration(t))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.VerifyChecksums(); err != nil {
+		t.Fatalf("force should accept the edited file: %v", err)
+	}
+}
+
+func TestAppliedChecksumsReportsQueryErrors(t *testing.T) {
+	conf := newGuardTestConfig(t)
+	m, err := NewMigratorWithConfig("checksum_test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if checksums, err := m.appliedChecksums(context.Background()); err != nil || len(checksums) != 0 {
+		t.Fatalf("missing table should mean no checksums: %v %v", checksums, err)
+	}
+	if err := autoMigrate("checksum_test", conf); err != nil {
+		t.Fatal(err)
+	}
+	if checksums, err := m.appliedChecksums(context.Background()); err != nil || len(checksums) != int(latestTestMigration(t)) {
+		t.Fatalf("checksums after migrate: %v %v", checksums, err)
+	}
+	// 连接不可用时必须报错，不能当作没有校验和而跳过校验
+	m.db.Close()
+	if _, err := m.appliedChecksums(context.Background()); err == nil {
+		t.Fatal("query errors should not be treated as a missing table")
+	}
+}
+
+func TestPreMigrateHooks(t *testing.T) {
+	conf := newGuardTestConfig(t)
+	marker := filepath.Join(t.TempDir(), "backup")
+	conf.MigrationBackupCommand = `echo "$FAST_DB_SOURCE $FAST_DB_OPERATION" > ` + marker
+
+	var events []PreMigrateEvent
+	fail := false
+	RegisterPreMigrateHook(func(ctx context.Context, event PreMigrateEvent) error {
+		if event.Source != "hook_test" {
+			return nil
+		}
+		events = append(events, event)
+		if fail {
+			return errors.New("backup failed")
+		}
+		return nil
+	})
+
+	if err := autoMigrate("hook_test", conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || uint(len(events[0].Pending)) != latestTestMigration(t) {
+		t.Fatalf("hook should see pending files: %+v", events)
+	}
+	if content, _ := os.ReadFile(marker); strings.TrimSpace(string(content)) != "hook_test up" {
+		t.Fatalf("backup command not executed: %q", content)
+	}
+
+	// 没有待执行的脚本时不调用钩子
+	if err := autoMigrate("hook_test", conf); err != nil || len(events) != 1 {
+		t.Fatalf("hook should be skipped without pending files: %v %d", err, len(events))
+	}
+
+	fail = true
+	m, err := NewMigratorWithConfig("hook_test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if err := m.Down(1); err == nil {
+		t.Fatal("failing hook should abort migration")
+	}
+	if status, _ := m.Status(); status.Version != latestTestMigration(t) {
+		t.Fatalf("version must not change when hook fails, got %d", status.Version)
+	}
+}
+
+func TestMigrationLockTimeout(t *testing.T) {
+	conf := newGuardTestConfig(t)
+	m, err := NewMigratorWithConfig("lock_test", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	unlock, err := m.lock(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	start := time.Now()
+	if err := autoMigrate("lock_test", conf); err == nil || !strings.Contains(err.Error(), "迁移锁") {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+	if time.Since(start) < time.Second {
+		t.Fatal("should wait for the configured timeout")
+	}
+}
@@ -0,0 +1,114 @@
+package fast_db
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+func newTestMigrator(t *testing.T, name string) *Migrator {
+	t.Helper()
+	fast_base.Logger = zap.NewNop()
+	conf := newDataSourceConfig("./testdata/migration")
+	conf.DriverName = DriverSqlite
+	conf.Database = filepath.Join(t.TempDir(), "migrate.db")
+	conf = conf.applyDriverDefaults()
+	m, err := NewMigratorWithConfig(name, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMigratorCommands(t *testing.T) {
+	m := newTestMigrator(t, "migrate_test")
+
+	var out bytes.Buffer
+	if err := m.DryRun(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "1_create_user.up.sql") || !strings.Contains(out.String(), "2_add_email.up.sql") {
+		t.Fatalf("dry-run should list both files: %s", out.String())
+	}
+
+	if err := m.Up(1); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 1 || status.Dirty || !status.Files[0].Applied || status.Files[1].Applied {
+		t.Fatalf("unexpected status after up 1: %+v", status)
+	}
+
+	if err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(0); err != nil {
+		t.Fatalf("up without pending files should be a no-op: %v", err)
+	}
+	if err := m.Down(0); err == nil {
+		t.Fatal("down without steps must be rejected")
+	}
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Goto(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Force(1); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = m.Status(); status.Version != 1 {
+		t.Fatalf("force should set version, got %d", status.Version)
+	}
+}
+
+func TestMigratorEmbeddedSource(t *testing.T) {
+	RegisterMigrations("embedded_test", fstest.MapFS{
+		"1_create_item.up.sql":   {Data: []byte("CREATE TABLE item (id INTEGER PRIMARY KEY);")},
+		"1_create_item.down.sql": {Data: []byte("DROP TABLE item;")},
+	})
+	m := newTestMigrator(t, "embedded_test")
+	if err := m.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Files) != 1 || status.Files[0].Name != "create_item" || status.Version != 1 {
+		t.Fatalf("embedded migrations should take precedence: %+v", status)
+	}
+}
+
+func TestMigrateCommandStatus(t *testing.T) {
+	fast_base.Logger = zap.NewNop()
+	conf := newDataSourceConfig("./testdata/migration")
+	conf.DriverName = DriverSqlite
+	conf.Database = filepath.Join(t.TempDir(), "command.db")
+	previous := ConfigDataSources
+	ConfigDataSources = map[string]DataSourceConfig{"report": conf.applyDriverDefaults()}
+	t.Cleanup(func() { ConfigDataSources = previous })
+
+	var out bytes.Buffer
+	if err := MigrateCommand([]string{"-source", "report", "up", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateCommand([]string{"-source", "report", "status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "当前版本: 1") {
+		t.Fatalf("unexpected status output: %s", out.String())
+	}
+	if err := MigrateCommand([]string{"-source", "report", "drop"}, &out); err == nil {
+		t.Fatal("unknown command should fail")
+	}
+}
@@ -0,0 +1,171 @@
+package fast_db
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrVersionConflict 乐观锁冲突：更新时数据已被其他请求修改
+var ErrVersionConflict = errors.New("数据已被修改，请刷新后重试")
+
+const (
+	auditCreatedBy = "CreatedBy"
+	auditUpdatedBy = "UpdatedBy"
+	auditVersion   = "Version"
+	versionLockKey = "fast:version_lock"
+)
+
+// AuditPlugin GORM 插件，LoadDataSource 中为每个数据源注册：
+//   - 插入时为零值的 fast_base.StringInt64 主键(如 Model.ID)或声明了 snowflake 标签的整数主键填充雪花 ID，
+//     其他整数主键由 GORM 视为自增主键，不做处理
+//   - 按 context 中的 fast_base.CurrentUser 填写 CreatedBy、UpdatedBy；CreatedAt、UpdatedAt 由 GORM 自动维护
+//   - 模型有 Version 字段时启用乐观锁：更新条件带上原版本号并加 1，未更新到数据时返回 ErrVersionConflict
+type AuditPlugin struct{}
+
+func (p *AuditPlugin) Name() string {
+	return "fast:audit"
+}
+
+func (p *AuditPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("fast:audit_create", p.beforeCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("fast:audit_update", p.beforeUpdate); err != nil {
+		return err
+	}
+	return cb.Update().After("gorm:update").Register("fast:version_check", p.afterUpdate)
+}
+
+func (p *AuditPlugin) beforeCreate(tx *gorm.DB) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil {
+		return
+	}
+	user, hasUser := fast_base.CurrentUserFrom(stmt.Context)
+	pk := stmt.Schema.PrioritizedPrimaryField
+	fillId := pk != nil && isSnowflakeField(pk)
+	createdBy := stmt.Schema.LookUpField(auditCreatedBy)
+	updatedBy := stmt.Schema.LookUpField(auditUpdatedBy)
+	version := stmt.Schema.LookUpField(auditVersion)
+
+	eachRecord(stmt, func(rv reflect.Value) {
+		if fillId {
+			if _, zero := pk.ValueOf(stmt.Context, rv); zero {
+				id, err := nextId(stmt.Schema.Table)
+				if err != nil {
+					tx.AddError(err)
+					return
+				}
+				setIfZero(tx, pk, rv, id)
+			}
+		}
+		if hasUser {
+			setIfZero(tx, createdBy, rv, user.UserId)
+			setIfZero(tx, updatedBy, rv, user.UserId)
+		}
+		setIfZero(tx, version, rv, 1)
+	})
+}
+
+func (p *AuditPlugin) beforeUpdate(tx *gorm.DB) {
+	stmt := tx.Statement
+	if tx.Error != nil || stmt.Schema == nil {
+		return
+	}
+	if user, ok := fast_base.CurrentUserFrom(stmt.Context); ok && stmt.Schema.LookUpField(auditUpdatedBy) != nil {
+		stmt.SetColumn(auditUpdatedBy, user.UserId, true)
+	}
+
+	version := stmt.Schema.LookUpField(auditVersion)
+	if version == nil || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	current, zero := version.ValueOf(stmt.Context, stmt.ReflectValue)
+	if zero {
+		// 未携带版本号(如按条件批量更新)时不做乐观锁
+		return
+	}
+	n, ok := toInt64(current)
+	if !ok {
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: version.DBName}, Value: n}}})
+	stmt.SetColumn(version.DBName, n+1, true)
+	tx.InstanceSet(versionLockKey, n)
+}
+
+func (p *AuditPlugin) afterUpdate(tx *gorm.DB) {
+	previous, ok := tx.InstanceGet(versionLockKey)
+	if !ok || tx.Error != nil || tx.RowsAffected > 0 {
+		return
+	}
+	// 冲突时还原内存中的版本号
+	if version := tx.Statement.Schema.LookUpField(auditVersion); version != nil {
+		version.Set(tx.Statement.Context, tx.Statement.ReflectValue, previous)
+	}
+	tx.AddError(ErrVersionConflict)
+}
+
+// nextId 按配置使用全局生成器或每张表的生成器
+func nextId(table string) (int64, error) {
+	if ConfigSnowWorker.PerTable || SnowMaker == nil {
+		return NextIdForTable(table)
+	}
+	return SnowMaker.NextId()
+}
+
+var stringInt64Type = reflect.TypeOf(fast_base.StringInt64(0))
+
+// isSnowflakeField 主键是否由插件填充雪花 ID。普通 int64 主键需显式声明 gorm:"primaryKey;snowflake"
+func isSnowflakeField(f *schema.Field) bool {
+	if _, ok := f.TagSettings["AUTOINCREMENT"]; ok {
+		return false
+	}
+	if f.FieldType == stringInt64Type {
+		return true
+	}
+	_, ok := f.TagSettings["SNOWFLAKE"]
+	return ok && (f.FieldType.Kind() == reflect.Int64 || f.FieldType.Kind() == reflect.Uint64)
+}
+
+func eachRecord(stmt *gorm.Statement, fn func(rv reflect.Value)) {
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			rv := reflect.Indirect(stmt.ReflectValue.Index(i))
+			if rv.Kind() == reflect.Struct {
+				fn(rv)
+			}
+		}
+	case reflect.Struct:
+		fn(stmt.ReflectValue)
+	}
+}
+
+func setIfZero(tx *gorm.DB, f *schema.Field, rv reflect.Value, value any) {
+	if f == nil {
+		return
+	}
+	if _, zero := f.ValueOf(tx.Statement.Context, rv); zero {
+		if err := f.Set(tx.Statement.Context, rv, value); err != nil {
+			tx.AddError(err)
+		}
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+type auditUser struct {
+	AuditModel
+	Name    string
+	Version int64
+}
+
+func (auditUser) TableName() string {
+	return "user"
+}
+
+func TestAuditPluginFillsIdsAndUsers(t *testing.T) {
+	openTestSqlite(t)
+	ctx := fast_base.WithCurrentUser(context.Background(), fast_base.CurrentUser{UserId: 42})
+
+	users := []*auditUser{{Name: "a"}, {Name: "b"}}
+	if err := DB.WithContext(ctx).Create(users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if users[0].ID == 0 || users[1].ID == 0 || users[0].ID == users[1].ID {
+		t.Fatalf("snowflake ids not assigned: %d %d", users[0].ID, users[1].ID)
+	}
+	if users[0].CreatedBy != 42 || users[0].UpdatedBy != 42 || users[0].Version != 1 || users[0].CreatedAt.IsZero() {
+		t.Fatalf("audit fields not filled: %+v", users[0])
+	}
+
+	editor := fast_base.WithCurrentUser(context.Background(), fast_base.CurrentUser{UserId: 7})
+	if err := DB.WithContext(editor).Model(&auditUser{}).Where("id = ?", users[1].ID).Update("name", "b2").Error; err != nil {
+		t.Fatal(err)
+	}
+	var stored auditUser
+	DB.First(&stored, users[1].ID)
+	if stored.UpdatedBy != 7 || stored.CreatedBy != 42 {
+		t.Fatalf("updated_by should follow the editor: %+v", stored)
+	}
+}
+
+func TestAuditPluginOptimisticLock(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	user := auditUser{Name: "a"}
+	if err := DB.WithContext(ctx).Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second auditUser
+	DB.First(&first, user.ID)
+	DB.First(&second, user.ID)
+
+	first.Name = "first"
+	if err := DB.WithContext(ctx).Save(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 2 {
+		t.Fatalf("version should be incremented, got %d", first.Version)
+	}
+
+	second.Name = "second"
+	if err := DB.WithContext(ctx).Save(&second).Error; !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected version conflict, got %v", err)
+	}
+	if second.Version != 1 {
+		t.Fatalf("version should be restored after conflict, got %d", second.Version)
+	}
+	var stored auditUser
+	DB.First(&stored, user.ID)
+	if stored.Name != "first" || stored.Version != 2 {
+		t.Fatalf("stale write must not be applied: %+v", stored)
+	}
+}
+
+type autoIncrementUser struct {
+	ID   int64 `gorm:"primaryKey"`
+	Name string
+}
+
+func (autoIncrementUser) TableName() string {
+	return "user"
+}
+
+type snowflakeUser struct {
+	ID   int64 `gorm:"primaryKey;snowflake"`
+	Name string
+}
+
+func (snowflakeUser) TableName() string {
+	return "user"
+}
+
+func TestAuditPluginLeavesAutoIncrementIds(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	plain := autoIncrementUser{Name: "a"}
+	if err := DB.WithContext(ctx).Create(&plain).Error; err != nil {
+		t.Fatal(err)
+	}
+	if plain.ID != 1 {
+		t.Fatalf("untagged int64 primary key should be left to the database, got %d", plain.ID)
+	}
+	tagged := snowflakeUser{Name: "b"}
+	if err := DB.WithContext(ctx).Create(&tagged).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tagged.ID <= 1<<22 {
+		t.Fatalf("snowflake tag should assign a snowflake id, got %d", tagged.ID)
+	}
+}
@@ -0,0 +1,158 @@
+package fast_db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 分页查询的过滤与排序：前端传字段名、操作符和值，字段只能是模型中的字段(Go 字段名、数据库列名或 json 名)，
+// 不会把前端传入的内容拼接进 SQL。
+//
+//	{"pageIndex":1,"pageSize":20,
+//	 "filters":[{"field":"name","op":"like","value":"张"},{"field":"status","op":"in","value":[1,2]}],
+//	 "sorts":[{"field":"createdAt","desc":true}]}
+
+// ErrInvalidQuery 过滤或排序条件不合法
+var ErrInvalidQuery = errors.New("查询条件不合法")
+
+const (
+	OpEq      = "eq"
+	OpNe      = "ne"
+	OpGt      = "gt"
+	OpGte     = "gte"
+	OpLt      = "lt"
+	OpLte     = "lte"
+	OpLike    = "like"    // 包含，自动转义通配符
+	OpIn      = "in"      // value 为数组
+	OpNotIn   = "notIn"   // value 为数组
+	OpBetween = "between" // value 为两个元素的数组
+	OpIsNull  = "isNull"
+	OpNotNull = "notNull"
+)
+
+type Filter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+type Sort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// PageQuery 带过滤和排序的分页参数
+type PageQuery struct {
+	fast_base.PageParams
+	Filters []Filter `json:"filters"`
+	Sorts   []Sort   `json:"sorts"`
+}
+
+// ApplyQuery 把过滤和排序条件加到 query 上，sch 为模型的 schema
+func ApplyQuery(query *gorm.DB, sch *schema.Schema, filters []Filter, sorts []Sort) (*gorm.DB, error) {
+	for _, f := range filters {
+		field := lookUpField(sch, f.Field)
+		if field == nil {
+			return nil, fmt.Errorf("%w: 字段 %s 不存在", ErrInvalidQuery, f.Field)
+		}
+		expr, err := filterExpr(clause.Column{Table: clause.CurrentTable, Name: field.DBName}, f)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(expr)
+	}
+	for _, s := range sorts {
+		field := lookUpField(sch, s.Field)
+		if field == nil {
+			return nil, fmt.Errorf("%w: 排序字段 %s 不存在", ErrInvalidQuery, s.Field)
+		}
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Desc: s.Desc})
+	}
+	return query, nil
+}
+
+func filterExpr(column clause.Column, f Filter) (clause.Expression, error) {
+	switch f.Op {
+	case OpEq, "":
+		return clause.Eq{Column: column, Value: f.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: column, Value: f.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: column, Value: f.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: column, Value: f.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: column, Value: f.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: column, Value: f.Value}, nil
+	case OpLike:
+		s, ok := f.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: like 的值必须是字符串", ErrInvalidQuery)
+		}
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []any{column, "%" + likeEscaper.Replace(s) + "%"}}, nil
+	case OpIn, OpNotIn:
+		values, ok := toSlice(f.Value)
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%w: %s 的值必须是非空数组", ErrInvalidQuery, f.Op)
+		}
+		if f.Op == OpIn {
+			return clause.IN{Column: column, Values: values}, nil
+		}
+		return clause.Not(clause.IN{Column: column, Values: values}), nil
+	case OpBetween:
+		values, ok := toSlice(f.Value)
+		if !ok || len(values) != 2 {
+			return nil, fmt.Errorf("%w: between 的值必须是两个元素的数组", ErrInvalidQuery)
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{column, values[0], values[1]}}, nil
+	case OpIsNull:
+		return clause.Eq{Column: column, Value: nil}, nil
+	case OpNotNull:
+		return clause.Neq{Column: column, Value: nil}, nil
+	default:
+		return nil, fmt.Errorf("%w: 不支持的操作符 %s", ErrInvalidQuery, f.Op)
+	}
+}
+
+// 转义字符用 !，各数据库对 '\' 的字符串转义规则不同
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+func toSlice(value any) ([]any, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return nil, false
+	}
+	values := make([]any, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, true
+}
+
+// lookUpField 按 Go 字段名、数据库列名、json 名查找可查询的字段
+func lookUpField(sch *schema.Schema, name string) *schema.Field {
+	if name == "" {
+		return nil
+	}
+	if f := sch.LookUpField(name); f != nil && f.DBName != "" {
+		return f
+	}
+	for _, f := range sch.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(f.Name, name) || strings.EqualFold(f.DBName, name) || (jsonName != "" && jsonName != "-" && jsonName == name) {
+			return f
+		}
+	}
+	return nil
+}
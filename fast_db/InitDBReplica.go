@@ -0,0 +1,166 @@
+package fast_db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// 读写分离：在 GORM 的查询回调中把 SELECT 的连接池替换为只读副本。
+// 只有使用主库默认连接池的语句才会路由，事务(Begin/Transaction)和 Connection 中的语句始终留在主库。
+
+const (
+	ReplicaRoundRobin = "roundRobin"
+	ReplicaLeastConn  = "leastConn"
+)
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	policy   string
+	primary  gorm.ConnPool
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type primaryHintKey struct{}
+
+// UsePrimary 返回的 context 中，后续查询全部走主库(读自己的写)。
+// 在 Web 请求中写回 c.Request 即可对本次请求剩余的处理生效：
+//
+//	c.Request = c.Request.WithContext(fast_db.UsePrimary(c.Request.Context()))
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryHintKey{}, true)
+}
+
+// IsUsePrimary context 中是否要求走主库
+func IsUsePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(primaryHintKey{}).(bool)
+	return v
+}
+
+// registerReplicas 连接所有副本，注册路由回调并启动副本心跳
+func registerReplicas(name string, conf DataSourceConfig, primary *gorm.DB) error {
+	set := &replicaSet{policy: conf.ReplicaPolicy, primary: primary.Statement.ConnPool}
+	for i, r := range conf.Replicas {
+		db, err := openGorm(conf.replica(r))
+		if err != nil {
+			return err
+		}
+		rep := &replica{name: name + ".replica" + strconv.Itoa(i+1), db: db}
+		rep.healthy.Store(true)
+		set.replicas = append(set.replicas, rep)
+		registerPoolMetrics(rep.name, db)
+	}
+
+	route := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.ConnPool != set.primary || IsUsePrimary(tx.Statement.Context) {
+			return
+		}
+		// Raw(...).Scan 等会带着原始 SQL 进入 Row 回调，需要确认是只读语句
+		if tx.Statement.SQL.Len() > 0 && !isReadOnlySQL(tx.Statement.SQL.String()) {
+			return
+		}
+		if _, locking := tx.Statement.Clauses["FOR"]; locking {
+			return
+		}
+		if r := set.pick(); r != nil {
+			tx.Statement.ConnPool = r.db.Statement.ConnPool
+		}
+	}
+	cb := primary.Callback()
+	cb.Query().Before("gorm:query").Register("fast:replica_query", route)
+	cb.Row().Before("gorm:row").Register("fast:replica_row", route)
+
+	interval := conf.ReplicaCheckInterval * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go set.checkLoop(interval)
+	return nil
+}
+
+// pick 按策略选择健康的副本，全部不可用时返回 nil(回落到主库)
+func (s *replicaSet) pick() *replica {
+	n := len(s.replicas)
+	if s.policy == ReplicaLeastConn {
+		var best *replica
+		bestInUse := 0
+		for _, r := range s.replicas {
+			if !r.healthy.Load() {
+				continue
+			}
+			inUse := 0
+			if sqlDB, err := r.db.DB(); err == nil {
+				inUse = sqlDB.Stats().InUse
+			}
+			if best == nil || inUse < bestInUse {
+				best, bestInUse = r, inUse
+			}
+		}
+		return best
+	}
+	start := s.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := s.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *replicaSet) checkLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.check()
+	}
+}
+
+// check 心跳失败的副本移出轮询，恢复后重新加入
+func (s *replicaSet) check() {
+	timeout := fast_base.ConfigHealth.Timeout * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	for _, r := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := pingGorm(ctx, r.db)
+		cancel()
+		if err != nil && r.healthy.Swap(false) {
+			fast_base.Logger.Warn("只读副本 " + r.name + " 心跳失败，暂停使用：" + err.Error())
+		} else if err == nil && !r.healthy.Swap(true) {
+			fast_base.Logger.Info("只读副本 " + r.name + " 已恢复")
+		}
+	}
+}
+
+func pingGorm(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// isReadOnlySQL 只把 SELECT/WITH 查询路由到副本，加锁读(FOR UPDATE/FOR SHARE)留在主库
+func isReadOnlySQL(sql string) bool {
+	s := strings.ToUpper(strings.TrimLeft(sql, " \t\r\n("))
+	if !strings.HasPrefix(s, "SELECT") && !strings.HasPrefix(s, "WITH") {
+		return false
+	}
+	return !strings.Contains(s, "FOR UPDATE") && !strings.Contains(s, "FOR SHARE") && !strings.Contains(s, "LOCK IN SHARE MODE")
+}
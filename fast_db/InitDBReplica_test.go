@@ -0,0 +1,59 @@
+package fast_db
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestIsReadOnlySQL(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT * FROM user": true,
+		"  (select id from user) union (select id from role)": true,
+		"WITH t AS (SELECT 1) SELECT * FROM t":                true,
+		"select * from user for update":                       false,
+		"SELECT * FROM user LOCK IN SHARE MODE":               false,
+		"UPDATE user SET name = ?":                            false,
+		"INSERT INTO user VALUES (?)":                         false,
+	}
+	for sql, expected := range cases {
+		if isReadOnlySQL(sql) != expected {
+			t.Errorf("isReadOnlySQL(%q) should be %v", sql, expected)
+		}
+	}
+}
+
+func TestReplicaSetSkipsUnhealthyReplicas(t *testing.T) {
+	set := &replicaSet{policy: ReplicaRoundRobin}
+	for _, name := range []string{"r1", "r2", "r3"} {
+		r := &replica{name: name, db: &gorm.DB{}}
+		r.healthy.Store(true)
+		set.replicas = append(set.replicas, r)
+	}
+	set.replicas[1].healthy.Store(false)
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[set.pick().name]++
+	}
+	if seen["r2"] != 0 || seen["r1"] == 0 || seen["r3"] == 0 {
+		t.Fatalf("unexpected rotation: %v", seen)
+	}
+
+	for _, r := range set.replicas {
+		r.healthy.Store(false)
+	}
+	if set.pick() != nil {
+		t.Fatal("expected fallback to primary when no replica is healthy")
+	}
+}
+
+func TestUsePrimaryHint(t *testing.T) {
+	if IsUsePrimary(context.Background()) {
+		t.Fatal("background context must not force primary")
+	}
+	if !IsUsePrimary(UsePrimary(context.Background())) {
+		t.Fatal("hint not propagated")
+	}
+}
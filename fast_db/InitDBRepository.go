@@ -0,0 +1,262 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNotFound 记录不存在，同时满足 errors.Is(err, gorm.ErrRecordNotFound)
+var ErrNotFound = fmt.Errorf("fast_db: %w", gorm.ErrRecordNotFound)
+
+// ErrFieldNotAllowed 更新了白名单以外的字段
+var ErrFieldNotAllowed = errors.New("字段不允许更新")
+
+// Repository 单表的通用增删改查，所有方法都会加入 ctx 中的事务(见 WithTx)。
+// 模型包含 gorm.DeletedAt 字段时，Delete 为软删除，可用 Restore 恢复
+type Repository[T any] struct {
+	// DB 为空时使用默认数据源。ctx 中有事务时总是使用该事务
+	DB *gorm.DB
+	// BatchSize CreateBatch 每批条数，默认 500
+	BatchSize int
+	// dataScope 数据权限码，见 WithDataScope
+	dataScope string
+}
+
+// NewRepository 使用默认数据源
+func NewRepository[T any]() *Repository[T] {
+	return &Repository[T]{}
+}
+
+// NewRepositoryWithDB 使用指定数据源，如 fast_db.Use("report")
+func NewRepositoryWithDB[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{DB: db}
+}
+
+// WithDataScope 返回按数据权限过滤的仓储副本，查询、更新、删除均追加权限码对应的条件
+func (r *Repository[T]) WithDataScope(code string) *Repository[T] {
+	c := *r
+	c.dataScope = code
+	return &c
+}
+
+func (r *Repository[T]) db(ctx context.Context) *gorm.DB {
+	db := DBFrom(ctx)
+	if _, inTx := TxFrom(ctx); !inTx && r.DB != nil {
+		db = r.DB.WithContext(ctx)
+	}
+	if r.dataScope != "" {
+		db = db.Scopes(DataScope(ctx, r.dataScope))
+	}
+	return db
+}
+
+func (r *Repository[T]) schema(db *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("%s 没有主键", stmt.Schema.Name)
+	}
+	return stmt.Schema, nil
+}
+
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.db(ctx).Create(entity).Error
+}
+
+// CreateBatch 分批插入，每批一条 INSERT 语句
+func (r *Repository[T]) CreateBatch(ctx context.Context, entities []*T) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	size := r.BatchSize
+	if size <= 0 {
+		size = 500
+	}
+	return r.db(ctx).CreateInBatches(entities, size).Error
+}
+
+// Upsert 插入，主键或唯一键冲突时更新 columns(数据库列名)，columns 为空时更新全部字段
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, columns ...string) error {
+	onConflict := clause.OnConflict{UpdateAll: true}
+	if len(columns) > 0 {
+		onConflict = clause.OnConflict{DoUpdates: clause.AssignmentColumns(columns)}
+	}
+	return r.db(ctx).Clauses(onConflict).Create(entity).Error
+}
+
+// UpdateFields 按主键更新指定字段，fields 的 key 必须在 allowed 白名单中(Go 字段名、列名或 json 名)，
+// 防止前端传入的 map 修改主键、审计字段等。记录不存在时返回 ErrNotFound
+func (r *Repository[T]) UpdateFields(ctx context.Context, id any, fields map[string]any, allowed ...string) error {
+	db := r.db(ctx)
+	sch, err := r.schema(db)
+	if err != nil {
+		return err
+	}
+	whitelist := map[string]bool{}
+	for _, name := range allowed {
+		if f := lookUpField(sch, name); f != nil {
+			whitelist[f.DBName] = true
+		}
+	}
+	updates := map[string]any{}
+	for name, value := range fields {
+		f := lookUpField(sch, name)
+		if f == nil || !whitelist[f.DBName] {
+			return fmt.Errorf("%w: %s", ErrFieldNotAllowed, name)
+		}
+		updates[f.DBName] = value
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	byId := clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName}, Value: id}
+	result := db.Model(new(T)).Where(byId).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// MySQL 未开启 clientFoundRows 时，写入相同的值影响行数为 0，需要再确认记录是否存在
+		var count int64
+		if err := db.Model(new(T)).Where(byId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// Delete 按主键删除，模型有 gorm.DeletedAt 时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, ids ...any) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	db := r.db(ctx)
+	sch, err := r.schema(db)
+	if err != nil {
+		return err
+	}
+	return db.Where(r.inIds(sch, ids)).Delete(new(T)).Error
+}
+
+// Restore 恢复软删除的记录
+func (r *Repository[T]) Restore(ctx context.Context, ids ...any) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	db := r.db(ctx)
+	sch, err := r.schema(db)
+	if err != nil {
+		return err
+	}
+	var deletedAt *schema.Field
+	for _, f := range sch.Fields {
+		if f.FieldType == deletedAtType {
+			deletedAt = f
+			break
+		}
+	}
+	if deletedAt == nil {
+		return fmt.Errorf("%s 不支持软删除", sch.Name)
+	}
+	return db.Unscoped().Model(new(T)).Where(r.inIds(sch, ids)).Update(deletedAt.DBName, nil).Error
+}
+
+// FindById 记录不存在时返回 ErrNotFound
+func (r *Repository[T]) FindById(ctx context.Context, id any) (*T, error) {
+	db := r.db(ctx)
+	sch, err := r.schema(db)
+	if err != nil {
+		return nil, err
+	}
+	var result T
+	err = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName}, Value: id}).Take(&result).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindByIds 按主键批量查询，不存在的主键忽略
+func (r *Repository[T]) FindByIds(ctx context.Context, ids ...any) ([]T, error) {
+	results := []T{}
+	if len(ids) == 0 {
+		return results, nil
+	}
+	db := r.db(ctx)
+	sch, err := r.schema(db)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Where(r.inIds(sch, ids)).Find(&results).Error
+	return results, err
+}
+
+// Exists 按条件判断记录是否存在，条件写法同 gorm 的 Where
+func (r *Repository[T]) Exists(ctx context.Context, query any, args ...any) (bool, error) {
+	var found []int
+	err := r.db(ctx).Model(new(T)).Select("1").Where(query, args...).Limit(1).Scan(&found).Error
+	if err != nil {
+		return false, err
+	}
+	return len(found) > 0, nil
+}
+
+// Page 按过滤和排序条件分页，未指定排序时按主键排序，保证分页稳定。
+// 未通过 WithDataScope 指定时，使用接口注解放入 ctx 的数据权限码
+func (r *Repository[T]) Page(ctx context.Context, q PageQuery) (*fast_base.PageResult[T], error) {
+	db := r.db(ctx)
+	if code, ok := fast_base.DataScopeFrom(ctx); ok && r.dataScope == "" {
+		db = db.Scopes(DataScope(ctx, code))
+	}
+	sch, err := r.schema(db)
+	if err != nil {
+		return nil, err
+	}
+	sorts := q.Sorts
+	if len(sorts) == 0 {
+		sorts = []Sort{{Field: sch.PrioritizedPrimaryField.Name}}
+	}
+	query, err := ApplyQuery(db.Model(new(T)), sch, q.Filters, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &fast_base.PageResult[T]{}
+	result.From(q)
+	var count int64
+	if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	query, err = ApplyQuery(query, sch, nil, sorts)
+	if err != nil {
+		return nil, err
+	}
+	list := []T{}
+	if count > 0 {
+		if err := query.Limit(result.PageSize).Offset((result.PageIndex - 1) * result.PageSize).Find(&list).Error; err != nil {
+			return nil, err
+		}
+	}
+	result.Set(count, &list)
+	return result, nil
+}
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+func (r *Repository[T]) inIds(sch *schema.Schema, ids []any) clause.Expression {
+	return clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName}, Values: ids}
+}
@@ -0,0 +1,132 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+func TestRepositoryCrud(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	repo := NewRepository[testUser]()
+
+	batch := []*testUser{}
+	for i, name := range []string{"alice", "bob", "carol", "dave", "100%_eve"} {
+		batch = append(batch, &testUser{Model: Model{ID: fast_base.StringInt64(i + 1)}, Name: name})
+	}
+	repo.BatchSize = 2
+	if err := repo.CreateBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.FindById(ctx, 99); !errors.Is(err, ErrNotFound) || !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := repo.UpdateFields(ctx, 1, map[string]any{"name": "alice2"}, "Name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateFields(ctx, 1, map[string]any{"id": 7}, "Name"); !errors.Is(err, ErrFieldNotAllowed) {
+		t.Fatalf("expected ErrFieldNotAllowed, got %v", err)
+	}
+	if err := repo.UpdateFields(ctx, 99, map[string]any{"name": "x"}, "name"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing row, got %v", err)
+	}
+	if user, _ := repo.FindById(ctx, 1); user == nil || user.Name != "alice2" {
+		t.Fatalf("update not applied: %v", user)
+	}
+
+	if err := repo.Delete(ctx, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if users, _ := repo.FindByIds(ctx, 1, 2, 3); len(users) != 1 {
+		t.Fatalf("soft deleted rows should be hidden, got %d", len(users))
+	}
+	if err := repo.Restore(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := repo.Exists(ctx, "name = ?", "bob"); err != nil || !exists {
+		t.Fatalf("restored row should exist: %v %v", exists, err)
+	}
+
+	if err := repo.Upsert(ctx, &testUser{Model: Model{ID: 4}, Name: "dave2"}, "name"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := repo.FindById(ctx, 4); user.Name != "dave2" {
+		t.Fatalf("upsert should update on conflict, got %s", user.Name)
+	}
+}
+
+func TestRepositoryUpdateFieldsUnchangedRow(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	repo := NewRepository[testUser]()
+	if err := repo.Create(ctx, &testUser{Model: Model{ID: 1}, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟 MySQL 未开启 clientFoundRows：写入相同的值时影响行数为 0
+	DB.Callback().Update().After("gorm:update").Register("test:no_found_rows", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	})
+	if err := repo.UpdateFields(ctx, 1, map[string]any{"name": "alice"}, "name"); err != nil {
+		t.Fatalf("existing row reported as %v", err)
+	}
+	if err := repo.UpdateFields(ctx, 99, map[string]any{"name": "x"}, "name"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing row, got %v", err)
+	}
+}
+
+func TestRepositoryWithDBJoinsTx(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	repo := NewRepositoryWithDB[testUser](DB)
+	err := WithTx(ctx, func(ctx context.Context) error {
+		if err := repo.Create(ctx, &testUser{Model: Model{ID: 1}, Name: "alice"}); err != nil {
+			return err
+		}
+		if exists, _ := repo.Exists(ctx, "name = ?", "alice"); !exists {
+			t.Error("repository should see the uncommitted row")
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || countUsers(t) != 0 {
+		t.Fatalf("repository with DB should roll back with the ctx transaction: err=%v count=%d", err, countUsers(t))
+	}
+}
+
+func TestRepositoryPageFiltersAndSorts(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	repo := NewRepository[testUser]()
+	for i, name := range []string{"a1", "a2", "b1", "a3", "100%_x"} {
+		repo.Create(ctx, &testUser{Model: Model{ID: fast_base.StringInt64(i + 1)}, Name: name})
+	}
+
+	page, err := repo.Page(ctx, PageQuery{
+		PageParams: fast_base.PageParams{PageIndex: 1, PageSize: 2},
+		Filters:    []Filter{{Field: "name", Op: OpLike, Value: "a"}, {Field: "ID", Op: OpNotIn, Value: []int{2}}},
+		Sorts:      []Sort{{Field: "id", Desc: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalRows != 2 || len(*page.List) != 2 || (*page.List)[0].Name != "a3" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	page, err = repo.Page(ctx, PageQuery{Filters: []Filter{{Field: "name", Op: OpLike, Value: "%_"}}})
+	if err != nil || page.TotalRows != 1 {
+		t.Fatalf("like wildcards should be escaped: %v %+v", err, page)
+	}
+
+	if _, err := repo.Page(ctx, PageQuery{Filters: []Filter{{Field: "name; drop table user", Op: OpEq, Value: 1}}}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("unknown field should be rejected, got %v", err)
+	}
+	if _, err := repo.Page(ctx, PageQuery{Filters: []Filter{{Field: "name", Op: "regexp", Value: "a"}}}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("unknown operator should be rejected, got %v", err)
+	}
+}
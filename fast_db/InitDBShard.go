@@ -0,0 +1,527 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分表：按分片键把逻辑表路由到物理表，ModShard 按取模(log_0 … log_{n-1})，TimeShard 按时间(log_202610)。
+//
+//	fast_db.RegisterShard(fast_db.ShardTable{Table: "access_log", Rule: &fast_db.TimeShard{Key: "created_at", Unit: fast_db.ShardMonth}, Template: "access_log"})
+//	db.Create(&log)                                                   // 按 created_at 写入 access_log_202610，表不存在时按模板创建
+//	fast_db.WithShardKey(db, t).Where("device_id = ?", 1).Find(&logs)   // 查询 t 所在的分片
+//	fast_db.QueryPageListByDB[Log](param, fast_db.WithShardRange(db.Model(&Log{}), fast_db.ShardRange{From: from, To: to}))
+//
+// 插入时从数据中取分片键；查询、更新、删除需通过 WithShardKey 指定分片键，或通过 WithShardRange 指定只落在一个分片内的范围，
+// 否则返回 ErrShardKeyMissing，不会访问逻辑表。只作用于基于模型的操作，Raw/Exec 执行的 SQL 不做处理。
+
+var (
+	// ErrShardKeyMissing 访问分片表时没有分片键
+	ErrShardKeyMissing = errors.New("分片表缺少分片键")
+	// ErrShardKeyInvalid 分片键的类型不支持
+	ErrShardKeyInvalid = errors.New("分片键类型不支持")
+	// ErrShardCross 一次写入或非分页查询跨越多个分片
+	ErrShardCross = errors.New("操作跨越多个分片")
+)
+
+// ShardRule 分片规则
+type ShardRule interface {
+	// KeyColumn 分片键的列名
+	KeyColumn() string
+	// Table 分片键的值对应的物理表
+	Table(base string, value any) (string, error)
+}
+
+// RangeShardRule 支持按范围跨分片查询的规则
+type RangeShardRule interface {
+	ShardRule
+	// Tables 范围 [from, to] 覆盖的物理表，按时间升序
+	Tables(base string, from, to time.Time) []string
+}
+
+// ShardTable 分片表
+type ShardTable struct {
+	Table    string // 逻辑表名
+	Rule     ShardRule
+	Template string // 插入时按该表的结构创建缺少的分片，为空时不自动创建
+}
+
+var shardLock sync.RWMutex
+var shardTables = map[string]ShardTable{}
+
+// RegisterShard 注册分片表，同名覆盖
+func RegisterShard(s ShardTable) {
+	shardLock.Lock()
+	shardTables[s.Table] = s
+	shardLock.Unlock()
+}
+
+// UnregisterShard 取消分片
+func UnregisterShard(table string) {
+	shardLock.Lock()
+	delete(shardTables, table)
+	shardLock.Unlock()
+}
+
+func shardOf(table string) (ShardTable, bool) {
+	shardLock.RLock()
+	defer shardLock.RUnlock()
+	s, ok := shardTables[table]
+	return s, ok
+}
+
+const (
+	shardKeySetting   = "fast:shard_key"
+	shardRangeSetting = "fast:shard_range"
+)
+
+// ShardRange 分片键的时间范围(闭区间)。Desc 为 true 时分页从最新的分片开始
+type ShardRange struct {
+	From, To time.Time
+	Desc     bool
+}
+
+// WithShardKey 指定查询、更新、删除使用的分片键
+func WithShardKey(db *gorm.DB, value any) *gorm.DB {
+	return db.Set(shardKeySetting, value)
+}
+
+// WithShardRange 按时间范围查询，同时追加分片键的范围条件。
+// QueryPageListByDB 依次查询范围内的分片并合并，其他查询要求范围只落在一个分片内
+func WithShardRange(db *gorm.DB, r ShardRange) *gorm.DB {
+	return db.Set(shardRangeSetting, r)
+}
+
+// ModShard 按分片键取模，整数直接取模，字符串取 FNV 哈希。物理表为 {base}_{n}
+type ModShard struct {
+	Key   string
+	Count int
+}
+
+func (s *ModShard) KeyColumn() string {
+	return s.Key
+}
+
+func (s *ModShard) Table(base string, value any) (string, error) {
+	if s.Count <= 0 {
+		return "", fmt.Errorf("%w: 分片数必须大于 0", ErrShardKeyInvalid)
+	}
+	v := reflect.Indirect(reflect.ValueOf(value))
+	var n uint64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < 0 {
+			i = -i
+		}
+		n = uint64(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = v.Uint()
+	case reflect.String:
+		h := fnv.New32a()
+		h.Write([]byte(v.String()))
+		n = uint64(h.Sum32())
+	default:
+		return "", fmt.Errorf("%w: %T", ErrShardKeyInvalid, value)
+	}
+	return fmt.Sprintf("%s_%d", base, n%uint64(s.Count)), nil
+}
+
+// ShardUnit 时间分片的粒度
+type ShardUnit string
+
+const (
+	ShardDay   ShardUnit = "day"
+	ShardMonth ShardUnit = "month"
+	ShardYear  ShardUnit = "year"
+)
+
+// TimeShard 按时间分片，物理表为 {base}_{时间}，如 log_20261019、log_202610、log_2026
+type TimeShard struct {
+	Key      string
+	Unit     ShardUnit
+	Location *time.Location // 划分分片使用的时区，默认 time.Local
+}
+
+func (s *TimeShard) KeyColumn() string {
+	return s.Key
+}
+
+func (s *TimeShard) layout() string {
+	switch s.Unit {
+	case ShardDay:
+		return "20060102"
+	case ShardYear:
+		return "2006"
+	}
+	return "200601"
+}
+
+func (s *TimeShard) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+func (s *TimeShard) Table(base string, value any) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return "", ErrShardKeyMissing
+		}
+		t = *v
+	default:
+		return "", fmt.Errorf("%w: %T", ErrShardKeyInvalid, value)
+	}
+	return base + "_" + t.In(s.location()).Format(s.layout()), nil
+}
+
+// start 时间所在分片的起始时间
+func (s *TimeShard) start(t time.Time) time.Time {
+	t = t.In(s.location())
+	switch s.Unit {
+	case ShardDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case ShardYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (s *TimeShard) next(t time.Time) time.Time {
+	switch s.Unit {
+	case ShardDay:
+		return t.AddDate(0, 0, 1)
+	case ShardYear:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+func (s *TimeShard) Tables(base string, from, to time.Time) []string {
+	var tables []string
+	for t := s.start(from); !t.After(to); t = s.next(t) {
+		name, _ := s.Table(base, t)
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// ShardPlugin GORM 插件，LoadDataSource 中为每个数据源注册，把逻辑表替换为物理表
+type ShardPlugin struct {
+	db      *gorm.DB
+	created sync.Map // 已确认存在的分片
+}
+
+func (p *ShardPlugin) Name() string {
+	return "fast:shard"
+}
+
+func (p *ShardPlugin) Initialize(db *gorm.DB) error {
+	p.db = db
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("fast:shard_create", p.route("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("fast:shard_query", p.route("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("fast:shard_update", p.route("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("fast:shard_delete", p.route("delete")); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("fast:shard_row", p.route("row"))
+}
+
+func (p *ShardPlugin) route(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		stmt := tx.Statement
+		if tx.Error != nil {
+			return
+		}
+		shard, ok := shardOf(stmt.Table)
+		if !ok {
+			return
+		}
+		table, err := p.resolve(tx, shard, op)
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		if op == "create" && shard.Template != "" {
+			if err := p.ensureTable(tx, shard.Template, table); err != nil {
+				tx.AddError(err)
+				return
+			}
+		}
+		stmt.Table = table
+	}
+}
+
+func (p *ShardPlugin) resolve(tx *gorm.DB, shard ShardTable, op string) (string, error) {
+	stmt := tx.Statement
+	if value, ok := tx.Get(shardKeySetting); ok {
+		return shard.Rule.Table(shard.Table, value)
+	}
+	if v, _ := tx.Get(shardRangeSetting); v != nil {
+		r := v.(ShardRange)
+		rule, isRange := shard.Rule.(RangeShardRule)
+		if !isRange {
+			return "", fmt.Errorf("%w: %s 不支持按范围查询", ErrShardKeyInvalid, shard.Table)
+		}
+		tables := rule.Tables(shard.Table, r.From, r.To)
+		if len(tables) != 1 {
+			return "", fmt.Errorf("%w: %s 范围内有 %d 个分片", ErrShardCross, shard.Table, len(tables))
+		}
+		addShardRange(stmt, rule, r)
+		return tables[0], nil
+	}
+	if op != "create" || stmt.Schema == nil {
+		return "", fmt.Errorf("%w: %s", ErrShardKeyMissing, shard.Table)
+	}
+	// 插入时从数据中取分片键，所有数据必须落在同一个分片
+	f := stmt.Schema.LookUpField(shard.Rule.KeyColumn())
+	if f == nil {
+		return "", fmt.Errorf("%w: %s 没有字段 %s", ErrShardKeyMissing, shard.Table, shard.Rule.KeyColumn())
+	}
+	table := ""
+	var err error
+	eachRecord(stmt, func(rv reflect.Value) {
+		if err != nil {
+			return
+		}
+		value, zero := f.ValueOf(stmt.Context, rv)
+		if zero && f.AutoCreateTime > 0 {
+			// 创建时间由 GORM 填写，提前填写以确定分片
+			now := stmt.DB.NowFunc()
+			if err = f.Set(stmt.Context, rv, now); err != nil {
+				return
+			}
+			value, _ = f.ValueOf(stmt.Context, rv)
+		}
+		var name string
+		if name, err = shard.Rule.Table(shard.Table, value); err != nil {
+			return
+		}
+		if table != "" && table != name {
+			err = fmt.Errorf("%w: %s 与 %s，请按分片分批写入", ErrShardCross, table, name)
+			return
+		}
+		table = name
+	})
+	if err == nil && table == "" {
+		err = fmt.Errorf("%w: %s", ErrShardKeyMissing, shard.Table)
+	}
+	return table, err
+}
+
+// addShardRange 追加分片键的范围条件
+func addShardRange(stmt *gorm.Statement, rule ShardRule, r ShardRange) {
+	column := clause.Column{Table: clause.CurrentTable, Name: rule.KeyColumn()}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Gte{Column: column, Value: r.From}, clause.Lte{Column: column, Value: r.To}}})
+}
+
+// ensureTable 按模板创建分片。MySQL 的 DDL 会隐式提交事务，使用数据源的连接执行；
+// SQLite 只允许一个写连接，在当前连接(可能是事务)中执行
+func (p *ShardPlugin) ensureTable(tx *gorm.DB, template, table string) error {
+	if _, ok := p.created.Load(table); ok {
+		return nil
+	}
+	exec := p.db.Session(&gorm.Session{NewDB: true, Context: tx.Statement.Context})
+	driver := p.db.Dialector.Name()
+	if driver == DriverSqlite {
+		exec = tx.Session(&gorm.Session{NewDB: true})
+	}
+	if !exec.Migrator().HasTable(table) {
+		if err := createShardTable(exec, driver, template, table); err != nil {
+			return fmt.Errorf("创建分片 %s 失败: %w", table, err)
+		}
+		fast_base.LoggerWithContext(tx.Statement.Context).Info("创建分片 " + table + "，模板 " + template)
+	}
+	p.created.Store(table, true)
+	return nil
+}
+
+func createShardTable(db *gorm.DB, driver, template, table string) error {
+	quote := func(name string) string {
+		var b strings.Builder
+		db.QuoteTo(&b, name)
+		return b.String()
+	}
+	switch driver {
+	case DriverMysql:
+		return db.Exec("CREATE TABLE IF NOT EXISTS " + quote(table) + " LIKE " + quote(template)).Error
+	case DriverPostgres:
+		return db.Exec("CREATE TABLE IF NOT EXISTS " + quote(table) + " (LIKE " + quote(template) + " INCLUDING ALL)").Error
+	case DriverSqlite:
+		var ddls []struct {
+			Type string
+			Name string
+			Sql  string
+		}
+		err := db.Raw("SELECT type, name, sql FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL ORDER BY type DESC", template).Scan(&ddls).Error
+		if err != nil {
+			return err
+		}
+		if len(ddls) == 0 || ddls[0].Type != "table" {
+			return fmt.Errorf("模板表 %s 不存在", template)
+		}
+		for _, d := range ddls {
+			if err := db.Exec(rewriteSqliteDDL(d.Sql, template, table, quote)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的驱动: %s", driver)
+}
+
+// rewriteSqliteDDL 把模板的建表、建索引语句改为分片的语句，索引名中的模板表名替换为分片名
+func rewriteSqliteDDL(ddl, template, table string, quote func(string) string) string {
+	sig := significant(tokenizeSQL(ddl, false))
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	unquote := func(s string) string { return strings.Trim(s, "`\"[]") }
+	for i := 0; i < len(sig); i++ {
+		t := sig[i]
+		if t.depth != 0 || t.kind != tokWord {
+			continue
+		}
+		switch {
+		case t.is("table") || t.is("index"):
+			j := i + 1
+			if j+2 < len(sig) && sig[j].is("if") && sig[j+1].is("not") && sig[j+2].is("exists") {
+				j += 3
+			} else {
+				edits = append(edits, edit{t.end, t.end, " IF NOT EXISTS"})
+			}
+			if j >= len(sig) {
+				continue
+			}
+			name := table
+			if t.is("index") {
+				name = unquote(sig[j].text)
+				if strings.Contains(name, template) {
+					name = strings.Replace(name, template, table, 1)
+				} else {
+					name = name + "_" + table
+				}
+			}
+			edits = append(edits, edit{sig[j].start, sig[j].end, quote(name)})
+			i = j
+		case t.is("on") && i+1 < len(sig):
+			edits = append(edits, edit{sig[i+1].start, sig[i+1].end, quote(table)})
+			i++
+		}
+	}
+	sort.Slice(edits, func(a, b int) bool { return edits[a].start > edits[b].start })
+	for _, e := range edits {
+		ddl = ddl[:e.start] + e.text + ddl[e.end:]
+	}
+	return ddl
+}
+
+// shardPageTables QueryPageListByDB 的跨分片查询：返回范围内已存在的分片，未指定范围或不是分片表时 ok 为 false
+func shardPageTables(query *gorm.DB) (tables []string, rule ShardRule, r ShardRange, ok bool, err error) {
+	v, _ := query.Get(shardRangeSetting)
+	if v == nil {
+		return nil, nil, ShardRange{}, false, nil
+	}
+	r = v.(ShardRange)
+	table := query.Statement.Table
+	if table == "" && query.Statement.Model != nil {
+		stmt := &gorm.Statement{DB: query}
+		if err := stmt.Parse(query.Statement.Model); err != nil {
+			return nil, nil, r, false, err
+		}
+		table = stmt.Table
+	}
+	shard, has := shardOf(table)
+	if !has {
+		return nil, nil, r, false, nil
+	}
+	rangeRule, has := shard.Rule.(RangeShardRule)
+	if !has {
+		return nil, nil, r, false, fmt.Errorf("%w: %s 不支持按范围查询", ErrShardKeyInvalid, shard.Table)
+	}
+	migrator := query.Session(&gorm.Session{NewDB: true}).Migrator()
+	for _, t := range rangeRule.Tables(shard.Table, r.From, r.To) {
+		if migrator.HasTable(t) {
+			tables = append(tables, t)
+		}
+	}
+	if r.Desc {
+		for i, j := 0, len(tables)-1; i < j; i, j = i+1, j-1 {
+			tables[i], tables[j] = tables[j], tables[i]
+		}
+	}
+	return tables, shard.Rule, r, true, nil
+}
+
+// queryShardPage 按分片顺序依次统计和读取：先累计各分片的总数，再从 offset 所在的分片开始读取，
+// 不足一页时继续读取下一个分片。分片内先按分片键排序(方向与 Desc 一致)，调用方的排序作为次级排序，结果整体有序
+func queryShardPage[T any](ctx context.Context, r *fast_base.PageResult[T], query *gorm.DB, tables []string, rule ShardRule, sr ShardRange) error {
+	column := clause.Column{Table: clause.CurrentTable, Name: rule.KeyColumn()}
+	query = query.Where(clause.Gte{Column: column, Value: sr.From}).Where(clause.Lte{Column: column, Value: sr.To})
+	// Reorder 替换已有的排序，分片键排在最前
+	orders := []clause.OrderByColumn{{Column: column, Desc: sr.Desc, Reorder: true}}
+	if c, ok := query.Statement.Clauses["ORDER BY"]; ok {
+		if orderBy, ok := c.Expression.(clause.OrderBy); ok {
+			orders = append(orders, orderBy.Columns...)
+		}
+	}
+	query = query.Clauses(clause.OrderBy{Columns: orders})
+	// 去掉范围设置，按物理表执行时不再路由
+	query = query.Set(shardRangeSetting, nil)
+
+	counts := make([]int64, len(tables))
+	var total int64
+	for i, t := range tables {
+		if err := query.Session(&gorm.Session{Context: ctx}).Table(t).Count(&counts[i]).Error; err != nil {
+			return err
+		}
+		total += counts[i]
+	}
+
+	results := []T{}
+	offset := int64((r.PageIndex - 1) * r.PageSize)
+	remaining := r.PageSize
+	for i, t := range tables {
+		if remaining <= 0 {
+			break
+		}
+		if offset >= counts[i] {
+			offset -= counts[i]
+			continue
+		}
+		var part []T
+		if err := query.Session(&gorm.Session{Context: ctx}).Table(t).Limit(remaining).Offset(int(offset)).Find(&part).Error; err != nil {
+			return err
+		}
+		results = append(results, part...)
+		remaining -= len(part)
+		offset = 0
+	}
+	r.Set(total, &results)
+	return nil
+}
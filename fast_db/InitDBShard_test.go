@@ -0,0 +1,183 @@
+package fast_db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+type accessLog struct {
+	ID        int64
+	DeviceId  int64
+	Path      string
+	CreatedAt time.Time
+}
+
+func openShardTest(t *testing.T) {
+	t.Helper()
+	openTestSqlite(t)
+	ddl := []string{
+		"CREATE TABLE access_log (id INTEGER PRIMARY KEY, device_id INTEGER, path TEXT, created_at DATETIME)",
+		"CREATE INDEX idx_access_log_device ON access_log (device_id)",
+	}
+	for _, s := range ddl {
+		if err := DB.Exec(s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	RegisterShard(ShardTable{Table: "access_log", Rule: &TimeShard{Key: "created_at", Unit: ShardMonth, Location: time.UTC}, Template: "access_log"})
+	t.Cleanup(func() { UnregisterShard("access_log") })
+}
+
+func month(m time.Month, day int) time.Time {
+	return time.Date(2026, m, day, 12, 0, 0, 0, time.UTC)
+}
+
+func TestShardCreateRoutesAndCreatesTable(t *testing.T) {
+	openShardTest(t)
+	if err := DB.Create(&accessLog{ID: 1, DeviceId: 7, Path: "/a", CreatedAt: month(10, 3)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !DB.Migrator().HasTable("access_log_202610") {
+		t.Fatal("shard table not created")
+	}
+	if !DB.Migrator().HasIndex("access_log_202610", "idx_access_log_202610_device") {
+		t.Fatal("template index not copied")
+	}
+	var n int64
+	DB.Table("access_log").Count(&n)
+	if n != 0 {
+		t.Fatalf("logical table should stay empty: %d", n)
+	}
+
+	var logs []accessLog
+	if err := WithShardKey(DB, month(10, 20)).Where("device_id = ?", 7).Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Path != "/a" {
+		t.Fatalf("logs: %+v", logs)
+	}
+	if err := DB.Find(&logs).Error; !errors.Is(err, ErrShardKeyMissing) {
+		t.Fatalf("query without shard key: %v", err)
+	}
+	rows := []accessLog{{ID: 2, CreatedAt: month(10, 1)}, {ID: 3, CreatedAt: month(11, 1)}}
+	if err := DB.Create(&rows).Error; !errors.Is(err, ErrShardCross) {
+		t.Fatalf("batch across shards: %v", err)
+	}
+	// 创建时间为空时按当前时间选择分片
+	if err := DB.Create(&accessLog{ID: 4}).Error; err != nil {
+		t.Fatal(err)
+	}
+	current := "access_log_" + time.Now().UTC().Format("200601")
+	if !DB.Migrator().HasTable(current) {
+		t.Fatalf("%s not created", current)
+	}
+}
+
+func TestShardPageAcrossRange(t *testing.T) {
+	openShardTest(t)
+	id := int64(0)
+	for _, m := range []time.Month{7, 8, 10} {
+		for day := 1; day <= 3; day++ {
+			id++
+			if err := DB.Create(&accessLog{ID: id, Path: "/p", CreatedAt: month(m, day)}).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	query := func(param fast_base.PageParams, desc bool, order string) []int64 {
+		t.Helper()
+		// 范围从 7 月 2 日开始，9 月没有分片
+		r := ShardRange{From: month(7, 2), To: month(10, 31), Desc: desc}
+		q := WithShardRange(DB.Model(&accessLog{}), r)
+		if order != "" {
+			q = q.Order(order)
+		}
+		page, err := QueryPageListByDB[accessLog](param, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.TotalRows != 8 {
+			t.Fatalf("total: %d", page.TotalRows)
+		}
+		var ids []int64
+		for _, l := range *page.List {
+			ids = append(ids, l.ID)
+		}
+		return ids
+	}
+	if got := query(fast_base.PageParams{PageIndex: 1, PageSize: 3}, false, "created_at"); !equalIds(got, 2, 3, 4) {
+		t.Fatalf("asc page 1: %v", got)
+	}
+	if got := query(fast_base.PageParams{PageIndex: 3, PageSize: 3}, false, "created_at"); !equalIds(got, 8, 9) {
+		t.Fatalf("asc page 3: %v", got)
+	}
+	if got := query(fast_base.PageParams{PageIndex: 2, PageSize: 4}, true, "created_at desc"); !equalIds(got, 5, 4, 3, 2) {
+		t.Fatalf("desc page 2: %v", got)
+	}
+	// 未指定排序或按其他列排序时，分片内仍按分片键与分片顺序一致
+	if got := query(fast_base.PageParams{PageIndex: 1, PageSize: 4}, true, ""); !equalIds(got, 9, 8, 7, 6) {
+		t.Fatalf("desc page across shards without order: %v", got)
+	}
+	if got := query(fast_base.PageParams{PageIndex: 1, PageSize: 4}, true, "id"); !equalIds(got, 9, 8, 7, 6) {
+		t.Fatalf("desc page across shards ordered by id: %v", got)
+	}
+
+	// 单个分片内的范围可以直接查询
+	var logs []accessLog
+	if err := WithShardRange(DB, ShardRange{From: month(8, 2), To: month(8, 31)}).Find(&logs).Error; err != nil || len(logs) != 2 {
+		t.Fatalf("single shard range: %v %+v", err, logs)
+	}
+	if err := WithShardRange(DB, ShardRange{From: month(7, 1), To: month(8, 31)}).Find(&logs).Error; !errors.Is(err, ErrShardCross) {
+		t.Fatalf("cross shard find: %v", err)
+	}
+}
+
+func TestModShard(t *testing.T) {
+	openTestSqlite(t)
+	for i := 0; i < 2; i++ {
+		if err := DB.Exec("CREATE TABLE device_event_" + string(rune('0'+i)) + " (id INTEGER PRIMARY KEY, device_id INTEGER)").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	RegisterShard(ShardTable{Table: "device_event", Rule: &ModShard{Key: "device_id", Count: 2}})
+	t.Cleanup(func() { UnregisterShard("device_event") })
+	type deviceEvent struct {
+		ID       int64
+		DeviceId int64
+	}
+	for i := int64(1); i <= 5; i++ {
+		if err := DB.Create(&deviceEvent{ID: i, DeviceId: i}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int64
+	DB.Table("device_event_1").Count(&n)
+	if n != 3 {
+		t.Fatalf("device_event_1: %d", n)
+	}
+	if err := WithShardKey(DB.Model(&deviceEvent{}), int64(4)).Where("device_id = ?", 4).Update("device_id", 6).Error; err != nil {
+		t.Fatal(err)
+	}
+	DB.Table("device_event_0").Where("device_id = ?", 6).Count(&n)
+	if n != 1 {
+		t.Fatalf("update routed to wrong shard: %d", n)
+	}
+	if name, _ := (&ModShard{Count: 4}).Table("t", "abc"); name != "t_3" {
+		t.Fatalf("string key: %s", name)
+	}
+}
+
+func equalIds(got []int64, want ...int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
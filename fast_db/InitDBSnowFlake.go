@@ -1,88 +1,225 @@
 package fast_db
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"sync"
 	"time"
 )
 
+// 雪花 ID：最高位为 0，其余依次为 时间戳(毫秒) | 数据中心 | 机器 | 序号，各段位数可配置。
+// 默认 数据中心 0 位、机器 10 位、序号 12 位、时间戳 41 位，与旧版本生成的 ID 一致。
+
 const (
-	workerBits  uint8 = 10
-	numberBits  uint8 = 12
-	workerMax   int64 = -1 ^ (-1 << workerBits)
-	numberMax   int64 = -1 ^ (-1 << numberBits)
-	timeShift   uint8 = workerBits + numberBits
-	workerShift uint8 = numberBits
-	startTime   int64 = 1525705533000 // 如果在程序跑了一段时间修改了epoch这个值 可能会导致生成相同的ID
+	defaultWorkerBits   uint8 = 10
+	defaultSequenceBits uint8 = 12
+	defaultEpoch        int64 = 1525705533000 // 如果在程序跑了一段时间修改了epoch这个值 可能会导致生成相同的ID
 )
 
+// ErrClockBackwards 时钟回拨超过允许等待的时间
+var ErrClockBackwards = errors.New("系统时钟回拨，拒绝生成 ID")
+
+// Clock 可替换的时钟，便于测试
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep sleeps for at least the given duration.
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SnowLayout ID 各段的位数
+type SnowLayout struct {
+	CenterBits   uint8
+	WorkerBits   uint8
+	SequenceBits uint8
+	Epoch        int64 // 起始时间，毫秒
+}
+
+// TimeBits 时间戳位数，63 减去其他各段
+func (l SnowLayout) TimeBits() uint8 {
+	return 63 - l.CenterBits - l.WorkerBits - l.SequenceBits
+}
+
+func (l SnowLayout) validate() error {
+	if int(l.CenterBits)+int(l.WorkerBits)+int(l.SequenceBits) > 63-31 {
+		return fmt.Errorf("雪花 ID 位数配置错误: 数据中心%d + 机器%d + 序号%d 位，时间戳至少需要 31 位", l.CenterBits, l.WorkerBits, l.SequenceBits)
+	}
+	if l.SequenceBits == 0 {
+		return errors.New("雪花 ID 序号位数不能为 0")
+	}
+	return nil
+}
+
+// SnowId 解析后的雪花 ID
+type SnowId struct {
+	Time     time.Time `json:"time"`
+	CenterId int64     `json:"centerId"`
+	WorkerId int64     `json:"workerId"`
+	Sequence int64     `json:"sequence"`
+}
+
+// Decode 按布局解析 ID
+func (l SnowLayout) Decode(id int64) SnowId {
+	sequence := id & mask(l.SequenceBits)
+	worker := (id >> l.SequenceBits) & mask(l.WorkerBits)
+	center := (id >> (l.SequenceBits + l.WorkerBits)) & mask(l.CenterBits)
+	ms := id>>(l.SequenceBits+l.WorkerBits+l.CenterBits) + l.Epoch
+	return SnowId{Time: time.UnixMilli(ms), CenterId: center, WorkerId: worker, Sequence: sequence}
+}
+
+// Decode 按当前配置的布局解析 ID，用于排查问题时查看 ID 的生成时间和机器
+func Decode(id int64) SnowId {
+	return ConfigSnowWorker.layout().Decode(id)
+}
+
+func mask(bits uint8) int64 {
+	return -1 ^ (-1 << bits)
+}
+
 type SnowWorker struct {
 	mu        sync.Mutex
+	layout    SnowLayout
+	clock     Clock
+	backward  time.Duration
 	timestamp int64
+	centerId  int64
 	workerId  int64
 	number    int64
+	lease     *WorkerLease // 启用租约时机器号取自租约
 }
 
+// NewSnowWorker 使用默认布局，workerId 超出范围时 panic
 func NewSnowWorker(workerId int64) *SnowWorker {
-	if workerId < 0 || workerId > workerMax {
-		panic("workerId excess of quantity")
-	}
-	wId = workerId
-	// 生成一个新节点
-	return &SnowWorker{
-		timestamp: 0,
-		workerId:  workerId,
-		number:    0,
+	conf := ConfigSnowWorker
+	conf.WorkId, conf.CenterId = workerId, 0
+	conf.CenterBits, conf.WorkerBits, conf.SequenceBits, conf.Epoch = 0, defaultWorkerBits, defaultSequenceBits, defaultEpoch
+	w, err := NewSnowWorkerWithConfig(conf, nil)
+	if err != nil {
+		panic(err.Error())
 	}
+	return w
 }
 
-var workers = map[string]*SnowWorker{}
-var lock sync.Mutex
-var wId int64
-
-func GetIdForTable(tableName string) int64 {
-	worker := workers[tableName]
-	if workers[tableName] == nil {
-		lock.Lock()
-		if workers[tableName] == nil {
-			worker = &SnowWorker{
-				timestamp: 0,
-				workerId:  wId,
-				number:    0,
-			}
-			workers[tableName] = worker
-		}
-		lock.Unlock()
+// NewSnowWorkerWithConfig 按配置创建生成器，clock 为 nil 时使用系统时钟
+func NewSnowWorkerWithConfig(conf SnowWorkerConfig, clock Clock) (*SnowWorker, error) {
+	layout := conf.layout()
+	if err := layout.validate(); err != nil {
+		return nil, err
 	}
-	return worker.GetId()
+	if conf.WorkId < 0 || conf.WorkId > mask(layout.WorkerBits) {
+		return nil, fmt.Errorf("workerId %d 超出范围 0~%d", conf.WorkId, mask(layout.WorkerBits))
+	}
+	if conf.CenterId < 0 || conf.CenterId > mask(layout.CenterBits) {
+		return nil, fmt.Errorf("centerId %d 超出范围 0~%d，请配置 snowWorker.centerBits", conf.CenterId, mask(layout.CenterBits))
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &SnowWorker{layout: layout, clock: clock, backward: conf.MaxBackward * time.Millisecond, centerId: conf.CenterId, workerId: conf.WorkId}, nil
 }
 
-func GetIdForStruct(t interface{}) int64 {
-	tp := reflect.TypeOf(t)
-	if tp.Kind() == reflect.Pointer {
-		tp = tp.Elem()
-	}
-	return GetIdForTable(tp.String())
+// Layout 生成器使用的布局
+func (w *SnowWorker) Layout() SnowLayout {
+	return w.layout
 }
 
-func (w *SnowWorker) GetId() int64 {
+// NextId 生成 ID。时钟回拨不超过 maxBackward 时等待追上，否则返回 ErrClockBackwards
+func (w *SnowWorker) NextId() (int64, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	now := time.Now().UnixNano() / 1e6
-	if w.timestamp == now {
+	now := w.now()
+	workerId := w.workerId
+	if w.lease != nil {
+		if !w.lease.validAt(now) {
+			return 0, ErrLeaseLost
+		}
+		workerId = w.lease.WorkerId()
+	}
+	if now < w.timestamp {
+		if time.Duration(w.timestamp-now)*time.Millisecond > w.backward {
+			return 0, fmt.Errorf("%w: 回拨 %dms", ErrClockBackwards, w.timestamp-now)
+		}
+		w.clock.Sleep(time.Duration(w.timestamp-now) * time.Millisecond)
+		for now = w.now(); now < w.timestamp; now = w.now() {
+			w.clock.Sleep(100 * time.Microsecond)
+		}
+	}
+	if now == w.timestamp {
 		w.number++
-		if w.number > numberMax {
+		if w.number > mask(w.layout.SequenceBits) {
+			// 本毫秒序号用完，等待下一毫秒
 			for now <= w.timestamp {
-				now = time.Now().UnixNano() / 1e6
+				w.clock.Sleep(100 * time.Microsecond)
+				now = w.now()
 			}
+			w.number = 0
 		}
 	} else {
 		w.number = 0
-		w.timestamp = now
 	}
-	ID := int64((now-startTime)<<timeShift | (w.workerId << workerShift) | (w.number))
-	return ID
+	w.timestamp = now
+
+	elapsed := now - w.layout.Epoch
+	if elapsed < 0 || elapsed > mask(w.layout.TimeBits()) {
+		return 0, fmt.Errorf("当前时间超出雪花 ID 时间戳范围，请检查 epoch 配置")
+	}
+	l := w.layout
+	return elapsed<<(l.CenterBits+l.WorkerBits+l.SequenceBits) | w.centerId<<(l.WorkerBits+l.SequenceBits) | workerId<<l.SequenceBits | w.number, nil
+}
+
+// GetId 生成 ID，时钟回拨超出允许范围时 panic。需要处理错误时使用 NextId
+func (w *SnowWorker) GetId() int64 {
+	id, err := w.NextId()
+	if err != nil {
+		panic(err.Error())
+	}
+	return id
+}
+
+func (w *SnowWorker) now() int64 {
+	return w.clock.Now().UnixMilli()
+}
+
+// newTableWorker 按表使用的生成器，与 SnowMaker 使用同一机器号和布局
+func newTableWorker() *SnowWorker {
+	if SnowMaker != nil {
+		return &SnowWorker{layout: SnowMaker.layout, clock: SnowMaker.clock, backward: SnowMaker.backward, centerId: SnowMaker.centerId, workerId: SnowMaker.workerId, lease: SnowMaker.lease}
+	}
+	return NewSnowWorker(0)
+}
+
+var workers sync.Map
+
+func tableWorker(tableName string) *SnowWorker {
+	if w, ok := workers.Load(tableName); ok {
+		return w.(*SnowWorker)
+	}
+	w, _ := workers.LoadOrStore(tableName, newTableWorker())
+	return w.(*SnowWorker)
+}
+
+func GetIdForTable(tableName string) int64 {
+	return tableWorker(tableName).GetId()
+}
+
+// NextIdForTable 同 GetIdForTable，出错时返回错误
+func NextIdForTable(tableName string) (int64, error) {
+	return tableWorker(tableName).NextId()
+}
+
+func GetIdForStruct(t interface{}) int64 {
+	tp := reflect.TypeOf(t)
+	if tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	return GetIdForTable(tp.String())
 }
 
 func (w *SnowWorker) GetIdStr() string {
@@ -0,0 +1,152 @@
+package fast_db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestWorker(t *testing.T, conf SnowWorkerConfig) (*SnowWorker, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.UnixMilli(defaultEpoch).Add(time.Hour)}
+	w, err := NewSnowWorkerWithConfig(conf, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, clock
+}
+
+func TestSnowWorkerDecode(t *testing.T) {
+	conf := ConfigSnowWorker
+	conf.CenterBits, conf.WorkerBits, conf.CenterId, conf.WorkId = 3, 7, 5, 99
+	w, clock := newTestWorker(t, conf)
+	first, _ := w.NextId()
+	second, _ := w.NextId()
+
+	got := w.Layout().Decode(second)
+	if got.CenterId != 5 || got.WorkerId != 99 || got.Sequence != 1 || !got.Time.Equal(clock.Now()) {
+		t.Fatalf("decode = %+v", got)
+	}
+	if d := w.Layout().Decode(first); d.Sequence != 0 {
+		t.Fatalf("first sequence = %d", d.Sequence)
+	}
+}
+
+func TestSnowWorkerDefaultLayoutCompatible(t *testing.T) {
+	w := NewSnowWorker(3)
+	id := w.GetId()
+	// 旧版本：时间戳左移 22 位，机器号左移 12 位
+	if (id>>12)&1023 != 3 {
+		t.Fatalf("worker bits moved, id=%d", id)
+	}
+	if d := Decode(id); d.WorkerId != 3 || time.Since(d.Time) > time.Minute {
+		t.Fatalf("decode = %+v", d)
+	}
+}
+
+func TestSnowWorkerSequenceOverflowWaitsNextMillisecond(t *testing.T) {
+	conf := ConfigSnowWorker
+	conf.SequenceBits = 2
+	w, clock := newTestWorker(t, conf)
+	start := clock.Now()
+	seen := map[int64]bool{}
+	for i := 0; i < 9; i++ {
+		id, err := w.NextId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+	if clock.Now().Sub(start) < 2*time.Millisecond {
+		t.Fatalf("clock advanced %v, want >= 2ms", clock.Now().Sub(start))
+	}
+}
+
+func TestSnowWorkerClockRollback(t *testing.T) {
+	conf := ConfigSnowWorker
+	conf.MaxBackward = 10
+	w, clock := newTestWorker(t, conf)
+	last, _ := w.NextId()
+
+	// 小幅回拨：等待追上后继续生成，ID 仍递增
+	clock.Advance(-5 * time.Millisecond)
+	id, err := w.NextId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= last {
+		t.Fatalf("id %d not after %d", id, last)
+	}
+
+	// 超过允许范围：拒绝生成
+	clock.Advance(-time.Second)
+	if _, err := w.NextId(); !errors.Is(err, ErrClockBackwards) {
+		t.Fatalf("err = %v, want ErrClockBackwards", err)
+	}
+}
+
+func TestSnowWorkerConfigValidation(t *testing.T) {
+	conf := ConfigSnowWorker
+	conf.CenterId = 1
+	if _, err := NewSnowWorkerWithConfig(conf, nil); err == nil {
+		t.Fatal("centerId without centerBits should fail")
+	}
+	conf = ConfigSnowWorker
+	conf.WorkId = 1024
+	if _, err := NewSnowWorkerWithConfig(conf, nil); err == nil {
+		t.Fatal("workerId out of range should fail")
+	}
+	conf = ConfigSnowWorker
+	conf.CenterBits, conf.WorkerBits, conf.SequenceBits = 10, 10, 20
+	if _, err := NewSnowWorkerWithConfig(conf, nil); err == nil {
+		t.Fatal("layout without enough timestamp bits should fail")
+	}
+}
+
+func TestGetIdForTableConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	ids := make(chan int64, 800)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ids <- GetIdForTable("snow_concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := map[int64]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
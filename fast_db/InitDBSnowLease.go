@@ -0,0 +1,214 @@
+package fast_db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// 雪花机器号租约：多个副本共用一份配置时，启动时从租约表中领取空闲的机器号，
+// 定期续约，关闭时释放。租约失效后生成器拒绝生成 ID，直到重新领取成功。
+
+const snowLeaseTable = "snowflake_worker_lease"
+
+// ErrLeaseLost 机器号租约已失效
+var ErrLeaseLost = errors.New("雪花机器号租约已失效，拒绝生成 ID")
+
+// WorkerLease 机器号租约
+type WorkerLease struct {
+	db       *gorm.DB
+	clock    Clock
+	owner    string
+	centerId int64
+	maxId    int64
+	ttl      time.Duration
+
+	mu       sync.Mutex // 串行化领取、续约和释放
+	workerId atomic.Int64
+	until    atomic.Int64 // 本地认为租约有效的截止时间(毫秒)，已提前一个续约周期
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewLeasedSnowWorker 领取机器号后创建生成器，并在后台续约。conf.WorkId 被忽略
+func NewLeasedSnowWorker(db *gorm.DB, conf SnowWorkerConfig, clock Clock) (*SnowWorker, error) {
+	if conf.LeaseTTL <= 0 {
+		return nil, errors.New("雪花机器号租约 leaseTTL 必须大于 0")
+	}
+	conf.WorkId = 0
+	w, err := NewSnowWorkerWithConfig(conf, clock)
+	if err != nil {
+		return nil, err
+	}
+	lease := &WorkerLease{
+		db:       db,
+		clock:    w.clock,
+		owner:    leaseOwner(),
+		centerId: conf.CenterId,
+		maxId:    mask(w.layout.WorkerBits),
+		ttl:      conf.LeaseTTL * time.Second,
+	}
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS " + snowLeaseTable +
+		" (center_id BIGINT NOT NULL, worker_id BIGINT NOT NULL, owner VARCHAR(128) NOT NULL, expires_at BIGINT NOT NULL, PRIMARY KEY (center_id, worker_id))").Error; err != nil {
+		return nil, err
+	}
+	if err := lease.acquire(); err != nil {
+		return nil, err
+	}
+	lease.stop, lease.done = make(chan struct{}), make(chan struct{})
+	go lease.heartbeat()
+	w.lease = lease
+	return w, nil
+}
+
+// leaseOwner 主机名-进程号-随机数，区分同一主机上的多个进程及重启前后的同一进程
+func leaseOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + strconv.Itoa(os.Getpid()) + "-" + hex.EncodeToString(b)
+}
+
+// Lease 生成器使用的租约，未启用时返回 nil
+func (w *SnowWorker) Lease() *WorkerLease {
+	return w.lease
+}
+
+// WorkerId 当前持有的机器号
+func (l *WorkerLease) WorkerId() int64 {
+	return l.workerId.Load()
+}
+
+// Owner 租约持有者标识
+func (l *WorkerLease) Owner() string {
+	return l.owner
+}
+
+// Valid 租约是否有效
+func (l *WorkerLease) Valid() bool {
+	return l.validAt(l.clock.Now().UnixMilli())
+}
+
+func (l *WorkerLease) validAt(ms int64) bool {
+	return ms < l.until.Load()
+}
+
+func (l *WorkerLease) interval() time.Duration {
+	return l.ttl / 3
+}
+
+// extend 续约成功后更新本地截止时间，提前一个续约周期失效，留出时钟误差的余量
+func (l *WorkerLease) extend(now time.Time) int64 {
+	expires := now.Add(l.ttl).UnixMilli()
+	l.until.Store(expires - l.interval().Milliseconds())
+	return expires
+}
+
+// acquire 依次尝试机器号：过期的租约通过条件更新抢占，不存在的直接插入，主键冲突说明已被占用
+func (l *WorkerLease) acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lastErr error
+	for id := int64(0); id <= l.maxId; id++ {
+		now := l.clock.Now()
+		expires := now.Add(l.ttl).UnixMilli()
+		res := l.db.Exec("UPDATE "+snowLeaseTable+" SET owner = ?, expires_at = ? WHERE center_id = ? AND worker_id = ? AND expires_at < ?",
+			l.owner, expires, l.centerId, id, now.UnixMilli())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			res = l.db.Exec("INSERT INTO "+snowLeaseTable+" (center_id, worker_id, owner, expires_at) VALUES (?, ?, ?, ?)",
+				l.centerId, id, l.owner, expires)
+			if res.Error != nil {
+				lastErr = res.Error
+				continue
+			}
+		}
+		l.workerId.Store(id)
+		l.extend(now)
+		fast_base.Logger.Info(fmt.Sprintf("领取雪花机器号 centerId=%d workerId=%d owner=%s", l.centerId, id, l.owner))
+		return nil
+	}
+	if lastErr != nil {
+		return fmt.Errorf("没有空闲的雪花机器号(0~%d): %w", l.maxId, lastErr)
+	}
+	return fmt.Errorf("没有空闲的雪花机器号(0~%d)", l.maxId)
+}
+
+// renew 续约。记录已被他人抢占时立即失效并重新领取；数据库不可用时保留本地截止时间，到期后自然失效
+func (l *WorkerLease) renew() error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	res := l.db.Exec("UPDATE "+snowLeaseTable+" SET expires_at = ? WHERE center_id = ? AND worker_id = ? AND owner = ?",
+		now.Add(l.ttl).UnixMilli(), l.centerId, l.workerId.Load(), l.owner)
+	if res.Error != nil {
+		l.mu.Unlock()
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		l.extend(now)
+		l.mu.Unlock()
+		return nil
+	}
+	l.until.Store(0)
+	l.mu.Unlock()
+	fast_base.Logger.Error(fmt.Sprintf("雪花机器号租约已丢失 workerId=%d，重新领取", l.workerId.Load()))
+	return l.acquire()
+}
+
+func (l *WorkerLease) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.renew(); err != nil {
+				fast_base.Logger.Error("雪花机器号续约失败, error=" + err.Error())
+			}
+		}
+	}
+}
+
+// Release 停止续约并删除租约记录，之后生成器不再生成 ID。可重复调用
+func (l *WorkerLease) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	l.until.Store(0)
+	return l.db.WithContext(ctx).Exec("DELETE FROM "+snowLeaseTable+" WHERE center_id = ? AND worker_id = ? AND owner = ?",
+		l.centerId, l.workerId.Load(), l.owner).Error
+}
+
+// newSnowMaker 按配置创建全局生成器。启用租约时注册就绪检查，并在优雅关闭时释放机器号
+func newSnowMaker() (*SnowWorker, error) {
+	if !ConfigSnowWorker.Lease {
+		return NewSnowWorkerWithConfig(ConfigSnowWorker, nil)
+	}
+	w, err := NewLeasedSnowWorker(DB, ConfigSnowWorker, nil)
+	if err != nil {
+		return nil, err
+	}
+	lease := w.Lease()
+	fast_base.RegisterHealthCheck("db.snowflakeLease", fast_base.HealthReadiness, func(ctx context.Context) error {
+		if !lease.Valid() {
+			return ErrLeaseLost
+		}
+		return nil
+	})
+	fast_base.RegisterShutdownHook("db.snowflakeLease", lease.Release)
+	return w, nil
+}
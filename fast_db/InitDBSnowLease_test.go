@@ -0,0 +1,94 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLeasedWorker(t *testing.T, clock *fakeClock) *SnowWorker {
+	t.Helper()
+	conf := ConfigSnowWorker
+	conf.WorkerBits, conf.LeaseTTL = 2, 30
+	w, err := NewLeasedSnowWorker(DB, conf, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Lease().Release(context.Background()) })
+	return w
+}
+
+func TestSnowLeaseDistinctWorkers(t *testing.T) {
+	openTestSqlite(t)
+	clock := &fakeClock{now: time.UnixMilli(defaultEpoch).Add(time.Hour)}
+	a := newTestLeasedWorker(t, clock)
+	b := newTestLeasedWorker(t, clock)
+	if a.Lease().WorkerId() == b.Lease().WorkerId() {
+		t.Fatalf("both workers leased %d", a.Lease().WorkerId())
+	}
+	id, err := b.NextId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Layout().Decode(id).WorkerId; got != b.Lease().WorkerId() {
+		t.Fatalf("decoded worker %d, leased %d", got, b.Lease().WorkerId())
+	}
+
+	// 释放后拒绝生成，机器号可被再次领取
+	released := a.Lease().WorkerId()
+	if err := a.Lease().Release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.NextId(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("err = %v, want ErrLeaseLost", err)
+	}
+	c := newTestLeasedWorker(t, clock)
+	if c.Lease().WorkerId() != released {
+		t.Fatalf("leased %d, want released id %d", c.Lease().WorkerId(), released)
+	}
+}
+
+func TestSnowLeaseExhausted(t *testing.T) {
+	openTestSqlite(t)
+	clock := &fakeClock{now: time.UnixMilli(defaultEpoch).Add(time.Hour)}
+	for i := 0; i < 4; i++ {
+		newTestLeasedWorker(t, clock)
+	}
+	conf := ConfigSnowWorker
+	conf.WorkerBits = 2
+	if _, err := NewLeasedSnowWorker(DB, conf, clock); err == nil {
+		t.Fatal("expected no free worker id")
+	}
+}
+
+func TestSnowLeaseExpiredIsStolenAndReacquired(t *testing.T) {
+	openTestSqlite(t)
+	clock := &fakeClock{now: time.UnixMilli(defaultEpoch).Add(time.Hour)}
+	a := newTestLeasedWorker(t, clock)
+	lost := a.Lease().WorkerId()
+
+	// 超过本地截止时间(TTL 减一个续约周期)即拒绝生成
+	clock.Advance(21 * time.Second)
+	if _, err := a.NextId(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("err = %v, want ErrLeaseLost", err)
+	}
+
+	// 数据库中过期后被其他实例抢占
+	clock.Advance(10 * time.Second)
+	b := newTestLeasedWorker(t, clock)
+	if b.Lease().WorkerId() != lost {
+		t.Fatalf("expired id %d not reused, got %d", lost, b.Lease().WorkerId())
+	}
+
+	// 原实例续约时发现租约丢失，重新领取其他机器号后恢复生成
+	if err := a.Lease().renew(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Lease().WorkerId() == lost {
+		t.Fatal("renew should not keep a stolen worker id")
+	}
+	if _, err := a.NextId(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package fast_db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// DefaultDataSource 默认数据源名称，fast_db.DB 即该数据源
+const DefaultDataSource = "default"
+
+var dataSourcesLock sync.RWMutex
+var dataSources = map[string]*gorm.DB{}
+
+// Use 获取指定名称的数据源，名称不区分大小写。数据源不存在说明配置有误，直接 panic
+func Use(name string) *gorm.DB {
+	db, ok := GetDataSource(name)
+	if !ok {
+		panic("数据源不存在或未启用: " + name)
+	}
+	return db
+}
+
+// GetDataSource 获取指定名称的数据源
+func GetDataSource(name string) (*gorm.DB, bool) {
+	dataSourcesLock.RLock()
+	defer dataSourcesLock.RUnlock()
+	db, ok := dataSources[strings.ToLower(name)]
+	return db, ok
+}
+
+// RegisterDataSource 注册数据源，同名覆盖。可用于运行时按需创建的数据源(如按租户分库)
+func RegisterDataSource(name string, db *gorm.DB) {
+	name = strings.ToLower(name)
+	dataSourcesLock.Lock()
+	dataSources[name] = db
+	dataSourcesLock.Unlock()
+	if name == DefaultDataSource {
+		DB = db
+	}
+}
+
+// DataSourceNames 已注册的数据源名称
+func DataSourceNames() []string {
+	dataSourcesLock.RLock()
+	defer dataSourcesLock.RUnlock()
+	names := make([]string, 0, len(dataSources))
+	for name := range dataSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadDataSourceConfigs 读取数据源配置：
+//
+//	dataSource:        # 兼容旧配置，作为 default 数据源
+//	dataSources:
+//	  default: {...}   # 与 dataSource 同时存在时以此为准
+//	  report: {...}
+//
+// 每个数据源都以默认值为基础覆盖；只有 default 数据源默认执行 ./conf/db/migration 下的迁移
+func loadDataSourceConfigs() map[string]DataSourceConfig {
+	fast_base.ConfigAll.UnmarshalKey("dataSource", &ConfigDataSource)
+
+	configs := map[string]DataSourceConfig{}
+	if fast_base.ConfigAll.IsSet("dataSource") || !fast_base.ConfigAll.IsSet("dataSources") {
+		configs[DefaultDataSource] = ConfigDataSource
+	}
+	for name := range fast_base.ConfigAll.GetStringMap("dataSources") {
+		name = strings.ToLower(name)
+		conf := newDataSourceConfig("")
+		if name == DefaultDataSource {
+			conf = ConfigDataSource
+		}
+		if err := fast_base.ConfigAll.UnmarshalKey("dataSources."+name, &conf); err != nil {
+			panic(fmt.Sprintf("数据源 %s 配置错误: %s", name, err.Error()))
+		}
+		configs[name] = conf
+	}
+	for name, conf := range configs {
+		configs[name] = conf.applyDriverDefaults()
+	}
+	if conf, ok := configs[DefaultDataSource]; ok {
+		ConfigDataSource = conf
+	}
+	return configs
+}
+
+// sortedDataSourceNames default 排在最前，其余按名称排序，保证启动顺序稳定
+func sortedDataSourceNames(configs map[string]DataSourceConfig) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == DefaultDataSource) != (names[j] == DefaultDataSource) {
+			return names[i] == DefaultDataSource
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,112 @@
+package fast_db
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// withDataSourceState 保存并恢复数据源相关的全局状态
+func withDataSourceState(t *testing.T) {
+	t.Helper()
+	fast_base.Logger = zap.NewNop()
+	dataSourcesLock.Lock()
+	saved := dataSources
+	dataSources = map[string]*gorm.DB{}
+	dataSourcesLock.Unlock()
+	db, conf, confs, all, snow := DB, ConfigDataSource, ConfigDataSources, fast_base.ConfigAll, SnowMaker
+	t.Cleanup(func() {
+		dataSourcesLock.Lock()
+		for _, d := range dataSources {
+			if d.Config == nil {
+				continue
+			}
+			if sqlDB, err := d.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}
+		dataSources = saved
+		dataSourcesLock.Unlock()
+		DB, ConfigDataSource, ConfigDataSources, fast_base.ConfigAll, SnowMaker = db, conf, confs, all, snow
+	})
+}
+
+func TestRegisterDataSourceLowercasesNames(t *testing.T) {
+	withDataSourceState(t)
+	report, primary := &gorm.DB{}, &gorm.DB{}
+	RegisterDataSource("Report", report)
+	RegisterDataSource("DEFAULT", primary)
+
+	if db, ok := GetDataSource("REPORT"); !ok || db != report {
+		t.Fatalf("lookup should ignore case: %v %v", db, ok)
+	}
+	if Use("report") != report {
+		t.Fatal("Use returned the wrong data source")
+	}
+	if DB != primary {
+		t.Fatal("registering default should set fast_db.DB")
+	}
+	if names := DataSourceNames(); !reflect.DeepEqual(names, []string{"default", "report"}) {
+		t.Fatalf("names: %v", names)
+	}
+	if _, ok := GetDataSource("missing"); ok {
+		t.Fatal("unknown data source should not be found")
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "missing") {
+			t.Fatalf("Use should panic for unknown data source: %v", r)
+		}
+	}()
+	Use("missing")
+}
+
+func TestLoadDataSourceConfigs(t *testing.T) {
+	withDataSourceState(t)
+	fast_base.ConfigAll = viper.New()
+	fast_base.ConfigAll.Set("dataSource", map[string]any{"host": "legacy", "database": "app"})
+	fast_base.ConfigAll.Set("dataSources", map[string]any{
+		"Report":  map[string]any{"driverName": "postgres", "host": "report-db"},
+		"archive": map[string]any{"enable": false},
+	})
+	ConfigDataSource = newDataSourceConfig("./conf/db/migration")
+
+	configs := loadDataSourceConfigs()
+	if names := sortedDataSourceNames(configs); !reflect.DeepEqual(names, []string{"default", "archive", "report"}) {
+		t.Fatalf("names: %v", names)
+	}
+	if c := configs["default"]; c.Host != "legacy" || c.Database != "app" || c.MigrationDir != "./conf/db/migration" {
+		t.Fatalf("legacy dataSource should become default: %+v", c)
+	}
+	// 其他数据源以默认值为基础，不继承 default 的迁移目录，并套用各自驱动的默认值
+	if c := configs["report"]; c.Host != "report-db" || c.MigrationDir != "" || c.Port != "5432" || !c.Enable {
+		t.Fatalf("report: %+v", c)
+	}
+	if configs["archive"].Enable {
+		t.Fatal("archive should be disabled")
+	}
+}
+
+func TestLoadDataSourceSkipsDisabled(t *testing.T) {
+	withDataSourceState(t)
+	dir := t.TempDir()
+	fast_base.ConfigAll = viper.New()
+	fast_base.ConfigAll.Set("dataSources", map[string]any{
+		"default": map[string]any{"driverName": "sqlite", "database": filepath.Join(dir, "app.db"), "migrationDir": "", "logLevel": "error"},
+		"archive": map[string]any{"enable": false, "host": "unreachable.invalid"},
+	})
+	ConfigDataSource = newDataSourceConfig("")
+
+	LoadDataSource()
+	if names := DataSourceNames(); !reflect.DeepEqual(names, []string{"default"}) {
+		t.Fatalf("disabled data source should not be registered: %v", names)
+	}
+	if DB == nil || Use("default") != DB {
+		t.Fatal("default data source should be fast_db.DB")
+	}
+}
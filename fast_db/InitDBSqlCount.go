@@ -0,0 +1,276 @@
+package fast_db
+
+import (
+	"strings"
+)
+
+// 分页总数查询：用一个简单的 SQL 分词器识别顶层(不在括号内)的子句，只去掉顶层的 ORDER BY、LIMIT、OFFSET，
+// 字符串、注释、子查询和窗口函数中的同名关键字不受影响。简单查询直接改写为 SELECT COUNT(*)，
+// 含 DISTINCT、GROUP BY、UNION、聚合或窗口函数等情况仍包一层子查询。
+
+type sqlTokenKind int
+
+const (
+	tokWord    sqlTokenKind = iota // 关键字、标识符、数字
+	tokString                      // 字符串、带引号的标识符
+	tokParam                       // 占位符 ? @name $1
+	tokPunct                       // 括号、逗号、运算符
+	tokSpace                       // 空白
+	tokComment                     // 注释
+)
+
+type sqlToken struct {
+	kind       sqlTokenKind
+	text       string
+	start, end int // 在原 SQL 中的字节位置
+	depth      int // 括号层级，顶层为 0；括号本身记为外层
+}
+
+func (t sqlToken) is(word string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+// tokenizeSQL 拆分 SQL。backslash 为 true 时字符串内反斜杠转义下一个字符(MySQL 默认行为)
+func tokenizeSQL(sql string, backslash bool) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+	for i := 0; i < len(sql); {
+		start, kind := i, tokPunct
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			for i < len(sql) && strings.IndexByte(" \t\n\r\f", sql[i]) >= 0 {
+				i++
+			}
+			kind = tokSpace
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			i = indexFrom(sql, i, "\n", 0)
+			kind = tokComment
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			i = indexFrom(sql, i+2, "*/", 2)
+			kind = tokComment
+		case c == '\'' || c == '"' || c == '`':
+			i = quotedEnd(sql, i, backslash && c != '`')
+			kind = tokString
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+			kind = tokParam
+		case c == '$':
+			// PostgreSQL $tag$ ... $tag$
+			tagEnd := i + 1
+			for tagEnd < len(sql) && isWordByte(sql[tagEnd]) && sql[tagEnd] != '$' {
+				tagEnd++
+			}
+			if tagEnd < len(sql) && sql[tagEnd] == '$' {
+				tag := sql[i : tagEnd+1]
+				i = indexFrom(sql, tagEnd+1, tag, len(tag))
+				kind = tokString
+			} else {
+				i++
+			}
+		case c == '?':
+			i++
+			kind = tokParam
+		case c == '@' && i+1 < len(sql) && sql[i+1] == '@':
+			// MySQL 系统变量 @@name
+			for i += 2; i < len(sql) && isWordByte(sql[i]); i++ {
+			}
+			kind = tokWord
+		case c == '@' && i+1 < len(sql) && isWordByte(sql[i+1]):
+			for i++; i < len(sql) && isWordByte(sql[i]); i++ {
+			}
+			kind = tokParam
+		case isWordByte(c):
+			for i < len(sql) && isWordByte(sql[i]) {
+				i++
+			}
+			kind = tokWord
+		default:
+			i++
+		}
+		t := sqlToken{kind: kind, text: sql[start:i], start: start, end: i, depth: depth}
+		if kind == tokPunct && c == '(' {
+			depth++
+		} else if kind == tokPunct && c == ')' && depth > 0 {
+			depth--
+			t.depth = depth
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// indexFrom 从 from 开始查找 sub，返回其结尾位置，找不到时返回 SQL 末尾
+func indexFrom(sql string, from int, sub string, n int) int {
+	if idx := strings.Index(sql[from:], sub); idx >= 0 {
+		return from + idx + n
+	}
+	return len(sql)
+}
+
+// quotedEnd 引号内容的结尾。两个连续引号表示引号本身
+func quotedEnd(sql string, i int, backslash bool) int {
+	q := sql[i]
+	for i++; i < len(sql); i++ {
+		switch {
+		case backslash && sql[i] == '\\':
+			i++
+		case sql[i] == q:
+			if i+1 < len(sql) && sql[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// significant 去掉空白和注释
+func significant(tokens []sqlToken) []sqlToken {
+	out := make([]sqlToken, 0, len(tokens))
+	for _, t := range tokens {
+		if t.kind != tokSpace && t.kind != tokComment {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// 改写为 SELECT COUNT(*) 时不允许出现在顶层的关键字
+var countWrapKeywords = map[string]bool{
+	"distinct": true, "group": true, "having": true, "union": true, "intersect": true, "except": true,
+	"minus": true, "window": true, "over": true, "into": true, "with": true, "qualify": true,
+}
+
+// 出现在 select 列表顶层时需要包子查询的聚合函数
+var countAggregates = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true, "group_concat": true, "string_agg": true,
+	"array_agg": true, "json_agg": true, "jsonb_agg": true, "json_arrayagg": true, "json_objectagg": true,
+	"bit_and": true, "bit_or": true, "bit_xor": true, "bool_and": true, "bool_or": true, "every": true,
+	"std": true, "stddev": true, "stddev_pop": true, "stddev_samp": true, "variance": true, "var_pop": true, "var_samp": true,
+}
+
+// countQuery 根据分页查询生成总数查询。去掉的部分中含 ? 占位符时同时去掉对应参数；
+// 含命名参数、$n，或 ? 的个数与参数个数不一致时保留该部分，避免参数错位
+func countQuery(driver, sql string, params []interface{}) (string, []interface{}) {
+	tokens := tokenizeSQL(sql, driver == DriverMysql)
+	sig := significant(tokens)
+
+	positional := 0
+	for _, t := range tokens {
+		if t.kind == tokParam && t.text == "?" {
+			positional++
+		}
+	}
+	dropParams := positional == len(params)
+	// removable 区间 [from, to) 中的参数是否可以一并去掉
+	removable := func(from, to int) bool {
+		for _, t := range tokens {
+			if t.kind == tokParam && t.start >= from && t.end <= to && (t.text != "?" || !dropParams) {
+				return false
+			}
+		}
+		return true
+	}
+
+	body := len(sql)
+	for len(sig) > 0 && sig[len(sig)-1].kind == tokPunct && sig[len(sig)-1].text == ";" {
+		body = sig[len(sig)-1].start
+		sig = sig[:len(sig)-1]
+	}
+	if tail := trailingClauseStart(sig); tail >= 0 && removable(sig[tail].start, body) {
+		body = sig[tail].start
+		sig = sig[:tail]
+	}
+
+	// 简单查询：SELECT 列表 FROM ...，列表替换为 COUNT(*)
+	head := -1
+	if from := simpleSelectFrom(sig); from > 0 && removable(sig[0].start, sig[from].start) {
+		head = sig[from].start
+	}
+
+	var out []interface{}
+	keep := func(pos int) bool { return pos < body && (head < 0 || pos >= head) }
+	if dropParams {
+		i := 0
+		for _, t := range tokens {
+			if t.kind == tokParam && t.text == "?" {
+				if keep(t.start) {
+					out = append(out, params[i])
+				}
+				i++
+			}
+		}
+	} else {
+		out = params
+	}
+
+	if head >= 0 {
+		return "SELECT COUNT(*) " + strings.TrimRight(sql[head:body], " \t\r\n"), out
+	}
+	return "SELECT COUNT(*) FROM (" + strings.TrimRight(sql[:body], " \t\r\n") + ") AS total_count", out
+}
+
+// trailingClauseStart 顶层 ORDER BY / LIMIT / OFFSET / FETCH 的起始位置，之后的内容(含 FOR UPDATE)一并去掉
+func trailingClauseStart(sig []sqlToken) int {
+	for i, t := range sig {
+		if t.depth != 0 || t.kind != tokWord || i == 0 {
+			continue
+		}
+		next := func(k int) (sqlToken, bool) {
+			if i+k < len(sig) {
+				return sig[i+k], true
+			}
+			return sqlToken{}, false
+		}
+		switch {
+		case t.is("order"):
+			if n, ok := next(1); ok && n.is("by") {
+				return i
+			}
+		case t.is("limit"):
+			return i
+		case t.is("offset"), t.is("fetch"):
+			// OFFSET 在 MySQL 中可作列名，仅在后跟数字、占位符或 FIRST/NEXT 时视为子句
+			if n, ok := next(1); ok && (n.kind == tokParam || n.kind == tokWord && (isDigit(n.text[0]) || n.is("first") || n.is("next"))) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// simpleSelectFrom 可直接改写为 COUNT(*) 时返回顶层 FROM 的位置，否则返回 -1
+func simpleSelectFrom(sig []sqlToken) int {
+	if len(sig) == 0 || !sig[0].is("select") {
+		return -1
+	}
+	from := -1
+	for i, t := range sig {
+		if t.depth != 0 || t.kind != tokWord {
+			continue
+		}
+		word := strings.ToLower(t.text)
+		if countWrapKeywords[word] || i > 0 && word == "select" {
+			return -1
+		}
+		if from < 0 && word == "from" {
+			from = i
+		}
+		// select 列表中的聚合函数
+		if from < 0 && countAggregates[word] && i+1 < len(sig) && sig[i+1].text == "(" {
+			return -1
+		}
+	}
+	return from
+}
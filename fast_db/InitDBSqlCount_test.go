@@ -0,0 +1,110 @@
+package fast_db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+func TestCountQuery(t *testing.T) {
+	cases := []struct {
+		name   string
+		driver string
+		sql    string
+		params []interface{}
+		want   string
+		wantP  []interface{}
+	}{
+		{"simple", DriverSqlite, "SELECT id, name FROM user WHERE name = ? ORDER BY id DESC", []interface{}{"a"},
+			"SELECT COUNT(*) FROM user WHERE name = ?", []interface{}{"a"}},
+		{"string literal", DriverSqlite, "SELECT * FROM user WHERE name = 'x order by y' ORDER BY id",
+			nil, "SELECT COUNT(*) FROM user WHERE name = 'x order by y'", nil},
+		{"subquery order", DriverSqlite, "SELECT * FROM (SELECT id FROM user ORDER BY id LIMIT 5) t",
+			nil, "SELECT COUNT(*) FROM (SELECT id FROM user ORDER BY id LIMIT 5) t", nil},
+		{"window function", DriverPostgres, "SELECT id, ROW_NUMBER() OVER (ORDER BY id) FROM user ORDER BY id",
+			nil, "SELECT COUNT(*) FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY id) FROM user) AS total_count", nil},
+		{"group by", DriverMysql, "SELECT name, COUNT(*) FROM user GROUP BY name ORDER BY 2 DESC",
+			nil, "SELECT COUNT(*) FROM (SELECT name, COUNT(*) FROM user GROUP BY name) AS total_count", nil},
+		{"distinct", DriverMysql, "select distinct name from user",
+			nil, "SELECT COUNT(*) FROM (select distinct name from user) AS total_count", nil},
+		{"union", DriverSqlite, "SELECT id FROM a UNION SELECT id FROM b ORDER BY id",
+			nil, "SELECT COUNT(*) FROM (SELECT id FROM a UNION SELECT id FROM b) AS total_count", nil},
+		{"limit params dropped", DriverMysql, "SELECT id, (SELECT ? ) x FROM user WHERE id > ? ORDER BY FIELD(id, ?) LIMIT ? OFFSET ?;",
+			[]interface{}{"p", 1, 2, 10, 20}, "SELECT COUNT(*) FROM user WHERE id > ?", []interface{}{1}},
+		{"named params kept", DriverSqlite, "SELECT id FROM user WHERE id > @min ORDER BY id LIMIT @n",
+			[]interface{}{map[string]interface{}{"min": 1, "n": 5}}, "SELECT COUNT(*) FROM user WHERE id > @min ORDER BY id LIMIT @n",
+			[]interface{}{map[string]interface{}{"min": 1, "n": 5}}},
+		{"comments", DriverSqlite, "SELECT id FROM user -- order by name\nWHERE id > 0 /* limit 3 */ ORDER BY id",
+			nil, "SELECT COUNT(*) FROM user -- order by name\nWHERE id > 0 /* limit 3 */", nil},
+		{"offset column", DriverMysql, "SELECT id FROM user WHERE offset > 3",
+			nil, "SELECT COUNT(*) FROM user WHERE offset > 3", nil},
+		{"mysql backslash", DriverMysql, `SELECT id FROM user WHERE name = 'a\' order by' ORDER BY id`,
+			nil, `SELECT COUNT(*) FROM user WHERE name = 'a\' order by'`, nil},
+		{"dollar quote", DriverPostgres, "SELECT id FROM user WHERE name = $$ order by $$ ORDER BY id",
+			nil, "SELECT COUNT(*) FROM user WHERE name = $$ order by $$", nil},
+		{"cte", DriverPostgres, "WITH t AS (SELECT id FROM user) SELECT id FROM t ORDER BY id",
+			nil, "SELECT COUNT(*) FROM (WITH t AS (SELECT id FROM user) SELECT id FROM t) AS total_count", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, gotP := countQuery(c.driver, c.sql, c.params)
+			if got != c.want {
+				t.Errorf("sql\n got: %s\nwant: %s", got, c.want)
+			}
+			if !reflect.DeepEqual(gotP, c.wantP) {
+				t.Errorf("params got %v, want %v", gotP, c.wantP)
+			}
+		})
+	}
+}
+
+func TestQueryPageListByPageSql(t *testing.T) {
+	openTestSqlite(t)
+	for i, name := range []string{"order by", "b", "c", "d", "e"} {
+		if err := DB.Create(&testUser{Model: Model{ID: fast_base.StringInt64(i + 1)}, Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	param := fast_base.PageParams{PageIndex: 1, PageSize: 2}
+
+	r, err := QueryPageListBySql[testUser](param, "SELECT * FROM user WHERE name <> ? ORDER BY id DESC", "order by")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalRows != 4 || len(*r.List) != 2 || (*r.List)[0].ID != 5 {
+		t.Fatalf("page = %+v", r)
+	}
+
+	r, err = QueryPageListByPageSql[testUser](context.Background(), param, PageSql{
+		Sql:         "SELECT * FROM user WHERE id > ? ORDER BY id",
+		Params:      []interface{}{0},
+		CountSql:    "SELECT 42",
+		CountParams: []interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalRows != 42 || r.TotalPages != 21 {
+		t.Fatalf("custom count ignored: %+v", r)
+	}
+
+	// 事务中顺序执行，能看到未提交的数据
+	err = WithTx(context.Background(), func(ctx context.Context) error {
+		if err := insertUser(ctx, 6, "f"); err != nil {
+			return err
+		}
+		r, err := QueryPageListBySqlContext[testUser](ctx, param, "SELECT * FROM user ORDER BY id")
+		if err != nil {
+			return err
+		}
+		if r.TotalRows != 6 {
+			t.Errorf("total in tx = %d", r.TotalRows)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
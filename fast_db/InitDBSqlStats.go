@@ -0,0 +1,378 @@
+package fast_db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+// SQL 统计(配置 sqlStats)：GORM 回调取 Statement.SQL(占位符形式，不拼接参数)归一化为指纹(字面量替换为 ?，IN 列表合并)，
+// 按数据源和指纹累计次数、耗时、影响行数。超过数据源的 slowThreshold 视为慢查询，
+// explainSlow 开启时对每个指纹第一次慢查询用原语句和绑定参数异步执行 EXPLAIN。
+// 统计只保存指纹和执行计划，不保存参数值。管理端口 /admin/db/sqlStats 查看排名。
+
+// sqlStatsSamples 计算 P95 时保留的最近耗时个数
+const sqlStatsSamples = 128
+
+// sqlStatsOther 指纹数超过 maxFingerprints 后，新指纹合并到该项
+const sqlStatsOther = "<other>"
+
+// SqlStat 一个指纹的统计，耗时单位毫秒。P95 按最近 128 次计算
+type SqlStat struct {
+	Source      string    `json:"source"`
+	Fingerprint string    `json:"fingerprint"`
+	Count       int64     `json:"count"`
+	Errors      int64     `json:"errors"`
+	Slow        int64     `json:"slow"`
+	Rows        int64     `json:"rows"`
+	TotalMs     float64   `json:"totalMs"`
+	AvgMs       float64   `json:"avgMs"`
+	P95Ms       float64   `json:"p95Ms"`
+	MaxMs       float64   `json:"maxMs"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Explain     string    `json:"explain,omitempty"` // 第一次慢查询的执行计划
+}
+
+type sqlStatKey struct {
+	source, fingerprint string
+}
+
+type sqlStatEntry struct {
+	stat      SqlStat
+	total     time.Duration
+	max       time.Duration
+	samples   []time.Duration
+	next      int
+	explained bool
+}
+
+var sqlStatsLock sync.Mutex
+var sqlStatsEntries = map[sqlStatKey]*sqlStatEntry{}
+
+const sqlStatsStartKey = "fast:sql_stats_start"
+
+// sqlStatsCollector 一个数据源的统计，由 GORM 回调调用
+type sqlStatsCollector struct {
+	source    string
+	driver    string
+	threshold time.Duration
+	explain   bool
+	db        *sql.DB
+}
+
+// registerSqlStats 为数据源挂上统计
+func registerSqlStats(name string, conf DataSourceConfig, db *gorm.DB) error {
+	if !ConfigSqlStats.Enable {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	c := &sqlStatsCollector{source: name, driver: conf.DriverName, threshold: conf.SlowThreshold * time.Millisecond, explain: conf.ExplainSlow, db: sqlDB}
+	c.register(db)
+	return nil
+}
+
+// register 通过 GORM 回调计时，语句执行后按 Statement.SQL 统计，不调用日志的 SQL 拼接
+func (c *sqlStatsCollector) register(db *gorm.DB) {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(sqlStatsStartKey, time.Now())
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(sqlStatsStartKey)
+		if !ok || tx.DryRun || tx.Statement.SQL.Len() == 0 {
+			return
+		}
+		c.record(time.Since(v.(time.Time)), tx.Statement.SQL.String(), tx.Statement.Vars, tx.RowsAffected, tx.Error)
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("*").Register("fast:sql_stats_before_create", before)
+	cb.Create().After("*").Register("fast:sql_stats_after_create", after)
+	cb.Query().Before("*").Register("fast:sql_stats_before_query", before)
+	cb.Query().After("*").Register("fast:sql_stats_after_query", after)
+	cb.Update().Before("*").Register("fast:sql_stats_before_update", before)
+	cb.Update().After("*").Register("fast:sql_stats_after_update", after)
+	cb.Delete().Before("*").Register("fast:sql_stats_before_delete", before)
+	cb.Delete().After("*").Register("fast:sql_stats_after_delete", after)
+	cb.Row().Before("*").Register("fast:sql_stats_before_row", before)
+	cb.Row().After("*").Register("fast:sql_stats_after_row", after)
+	cb.Raw().Before("*").Register("fast:sql_stats_before_raw", before)
+	cb.Raw().After("*").Register("fast:sql_stats_after_raw", after)
+}
+
+// record 累计一条语句，sql 为占位符形式，vars 只用于 EXPLAIN，不会保存
+func (c *sqlStatsCollector) record(elapsed time.Duration, sql string, vars []any, rows int64, err error) {
+	fingerprint := cachedFingerprint(sql, c.driver)
+	if fingerprint == "" {
+		return
+	}
+	slow := c.threshold > 0 && elapsed > c.threshold
+
+	sqlStatsLock.Lock()
+	key := sqlStatKey{source: c.source, fingerprint: fingerprint}
+	e, ok := sqlStatsEntries[key]
+	if !ok {
+		if ConfigSqlStats.MaxFingerprints > 0 && len(sqlStatsEntries) >= ConfigSqlStats.MaxFingerprints {
+			key.fingerprint = sqlStatsOther
+			e = sqlStatsEntries[key]
+		}
+		if e == nil {
+			e = &sqlStatEntry{stat: SqlStat{Source: key.source, Fingerprint: key.fingerprint}, samples: make([]time.Duration, 0, sqlStatsSamples)}
+			sqlStatsEntries[key] = e
+		}
+	}
+	e.stat.Count++
+	e.stat.LastSeen = time.Now()
+	e.total += elapsed
+	if elapsed > e.max {
+		e.max = elapsed
+	}
+	if len(e.samples) < sqlStatsSamples {
+		e.samples = append(e.samples, elapsed)
+	} else {
+		e.samples[e.next] = elapsed
+		e.next = (e.next + 1) % sqlStatsSamples
+	}
+	if rows > 0 {
+		e.stat.Rows += rows
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		e.stat.Errors++
+	}
+	runExplain := false
+	if slow {
+		e.stat.Slow++
+		if !e.explained && key.fingerprint != sqlStatsOther {
+			e.explained = true
+			runExplain = c.explain && explainable(sql)
+		}
+	}
+	sqlStatsLock.Unlock()
+
+	if runExplain {
+		// Statement 会被复用，参数需复制后再交给异步的 EXPLAIN
+		go c.runExplain(key, sql, append([]any(nil), vars...))
+	}
+}
+
+// explainable 只对查询执行 EXPLAIN，写语句即使不会真正执行也不处理
+func explainable(sql string) bool {
+	sig := significant(tokenizeSQL(sql, false))
+	return len(sig) > 0 && (sig[0].is("select") || sig[0].is("with") && !sqlWrites(sql))
+}
+
+func (c *sqlStatsCollector) runExplain(key sqlStatKey, query string, vars []any) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	plan, err := explainSQL(ctx, c.db, c.driver, query, vars...)
+	if err != nil {
+		plan = "EXPLAIN 失败: " + err.Error()
+	}
+	sqlStatsLock.Lock()
+	if e, ok := sqlStatsEntries[key]; ok {
+		e.stat.Explain = plan
+	}
+	sqlStatsLock.Unlock()
+	fast_base.Logger.Warn("慢查询执行计划 [" + c.source + "] " + key.fingerprint + "\n" + plan)
+}
+
+// explainSQL 通过连接池直接执行，不经过 GORM 回调，不会再次计入统计。参数按占位符绑定，不拼接到 SQL 中
+func explainSQL(ctx context.Context, db *sql.DB, driver, query string, vars ...any) (string, error) {
+	prefix := "EXPLAIN "
+	if driver == DriverSqlite {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	rows, err := db.QueryContext(ctx, prefix+query, vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(strings.Join(cols, "\t"))
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		b.WriteByte('\n')
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(v.String)
+		}
+	}
+	return b.String(), rows.Err()
+}
+
+// sqlFingerprintCacheSize 缓存的 SQL 条数，超过后清空重建。GORM 生成的 SQL 使用占位符，同类语句文本相同
+const sqlFingerprintCacheSize = 4096
+
+var fingerprintCacheLock sync.RWMutex
+var fingerprintCache = map[string]string{}
+
+// cachedFingerprint 相同 SQL 只计算一次指纹
+func cachedFingerprint(sql, driver string) string {
+	fingerprintCacheLock.RLock()
+	fingerprint, ok := fingerprintCache[sql]
+	fingerprintCacheLock.RUnlock()
+	if ok {
+		return fingerprint
+	}
+	fingerprint = sqlFingerprint(sql, driver)
+	fingerprintCacheLock.Lock()
+	if len(fingerprintCache) >= sqlFingerprintCacheSize {
+		fingerprintCache = map[string]string{}
+	}
+	fingerprintCache[sql] = fingerprint
+	fingerprintCacheLock.Unlock()
+	return fingerprint
+}
+
+var (
+	fingerprintList = regexp.MustCompile(`\(\?(, \?)*\)`)
+	fingerprintRows = regexp.MustCompile(`\(\?\+\)(, \(\?\+\))+`)
+)
+
+// sqlFingerprint 去掉注释、合并空白、关键字和标识符小写，字符串、数字和占位符替换为 ?，
+// IN 列表和多行 VALUES 合并为 (?+)，使只有参数不同的语句得到相同的指纹。
+// GORM 为 MySQL、SQLite 生成的日志 SQL 用双引号表示字符串，PostgreSQL 中双引号为标识符
+func sqlFingerprint(sql string, driver string) string {
+	var b strings.Builder
+	var prev string
+	for _, t := range significant(tokenizeSQL(sql, driver == DriverMysql)) {
+		text := t.text
+		switch {
+		case t.kind == tokParam,
+			t.kind == tokString && (text[0] == '\'' || text[0] == '$' || text[0] == '"' && driver != DriverPostgres),
+			t.kind == tokWord && isDigit(text[0]):
+			text = "?"
+		case t.kind == tokWord:
+			text = strings.ToLower(text)
+		}
+		if b.Len() > 0 && text != "," && text != ")" && text != "." && prev != "(" && prev != "." {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+		prev = text
+	}
+	s := fingerprintList.ReplaceAllString(b.String(), "(?+)")
+	return fingerprintRows.ReplaceAllString(s, "(?+)")
+}
+
+// SqlStats 按 sortBy(total、count、avg、p95、max、rows、errors、slow，默认 total)降序返回前 top 个指纹，
+// source 为空时包含所有数据源，top 不大于 0 时返回全部
+func SqlStats(source, sortBy string, top int) []SqlStat {
+	sqlStatsLock.Lock()
+	list := make([]SqlStat, 0, len(sqlStatsEntries))
+	for _, e := range sqlStatsEntries {
+		if source != "" && e.stat.Source != source {
+			continue
+		}
+		s := e.stat
+		s.TotalMs = durationMs(e.total)
+		s.AvgMs = durationMs(e.total / time.Duration(s.Count))
+		s.MaxMs = durationMs(e.max)
+		s.P95Ms = durationMs(percentile(e.samples, 0.95))
+		list = append(list, s)
+	}
+	sqlStatsLock.Unlock()
+
+	value := func(s SqlStat) float64 {
+		switch sortBy {
+		case "count":
+			return float64(s.Count)
+		case "avg":
+			return s.AvgMs
+		case "p95":
+			return s.P95Ms
+		case "max":
+			return s.MaxMs
+		case "rows":
+			return float64(s.Rows)
+		case "errors":
+			return float64(s.Errors)
+		case "slow":
+			return float64(s.Slow)
+		}
+		return s.TotalMs
+	}
+	sort.Slice(list, func(i, j int) bool {
+		vi, vj := value(list[i]), value(list[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return list[i].Fingerprint < list[j].Fingerprint
+	})
+	if top > 0 && len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
+
+// ResetSqlStats 清空统计
+func ResetSqlStats() {
+	sqlStatsLock.Lock()
+	sqlStatsEntries = map[sqlStatKey]*sqlStatEntry{}
+	sqlStatsLock.Unlock()
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// registerSqlStatsAdmin 管理端接口：GET /admin/db/sqlStats?top=20&sort=total&source=default，POST /admin/db/sqlStats/reset
+func registerSqlStatsAdmin() {
+	fast_base.RegisterAdminEndpoint("GET", "/db/sqlStats", func(_ context.Context, params url.Values) (any, error) {
+		top := 20
+		if s := params.Get("top"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("top 参数错误: %s", s)
+			}
+			top = n
+		}
+		return SqlStats(params.Get("source"), params.Get("sort"), top), nil
+	})
+	fast_base.RegisterAdminEndpoint("POST", "/db/sqlStats/reset", func(context.Context, url.Values) (any, error) {
+		ResetSqlStats()
+		return "ok", nil
+	})
+}
@@ -0,0 +1,175 @@
+package fast_db
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+func TestSqlFingerprint(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM user WHERE id = 1":                            "select * from user where id = ?",
+		"select *\n from `User` where name = 'it''s' -- c":           "select * from `User` where name = ?",
+		"SELECT a FROM t WHERE id IN (1, 2, 3) AND b = ?":            "select a from t where id in (?+) and b = ?",
+		"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, 'z')":   "insert into t (a, b) values (?+)",
+		"SELECT u.name FROM user u WHERE u.id = $1 AND u.age > 18.5": "select u.name from user u where u.id = ? and u.age > ?.?",
+	}
+	for sql, want := range cases {
+		if got := sqlFingerprint(sql, DriverPostgres); got != want {
+			t.Errorf("%s:\n got %q\nwant %q", sql, got, want)
+		}
+	}
+	// MySQL、SQLite 日志 SQL 中的双引号为字符串
+	if got := sqlFingerprint(`UPDATE t SET name = "a\"b" WHERE id = 1`, DriverMysql); got != "update t set name = ? where id = ?" {
+		t.Errorf("mysql literal: %q", got)
+	}
+	if sqlFingerprint("SELECT 1 FROM t WHERE id IN (1)", DriverPostgres) != sqlFingerprint("select 1 from t where id in (4, 5)", DriverPostgres) {
+		t.Error("IN lists of different length should share a fingerprint")
+	}
+}
+
+func TestSqlStatsAggregateAndExplain(t *testing.T) {
+	fast_base.Logger = zap.NewNop()
+	ResetSqlStats()
+	t.Cleanup(ResetSqlStats)
+	conf := newDataSourceConfig("./testdata/migration")
+	conf.DriverName = DriverSqlite
+	conf.Database = filepath.Join(t.TempDir(), "stats.db")
+	conf.SlowThreshold = 0
+	conf.ExplainSlow = true
+	conf = conf.applyDriverDefaults()
+	// 由测试挂上统计，阈值设为 1ns，所有语句都是慢查询
+	enable := ConfigSqlStats.Enable
+	ConfigSqlStats.Enable = false
+	t.Cleanup(func() { ConfigSqlStats.Enable = enable })
+	db, err := OpenDataSource("stats", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	c := &sqlStatsCollector{source: "stats", driver: DriverSqlite, threshold: time.Nanosecond, explain: true, db: sqlDB}
+	c.register(db)
+
+	for i := 1; i <= 3; i++ {
+		if err := db.Create(&testUser{Model: Model{ID: fast_base.StringInt64(i)}, Name: "u"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		var u testUser
+		db.Raw("SELECT * FROM user WHERE id = ?", i).Scan(&u)
+	}
+
+	var select_ *SqlStat
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, s := range SqlStats("stats", "count", 0) {
+			if strings.HasPrefix(s.Fingerprint, "select * from user where id = ?") {
+				s := s
+				select_ = &s
+			}
+		}
+		if select_ != nil && select_.Explain != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if select_ == nil {
+		t.Fatalf("select fingerprint missing: %+v", SqlStats("stats", "", 0))
+	}
+	if select_.Count != 5 || select_.Slow != 5 || select_.MaxMs < select_.P95Ms || select_.TotalMs < select_.MaxMs {
+		t.Fatalf("unexpected stat: %+v", select_)
+	}
+	if !strings.Contains(select_.Explain, "user") {
+		t.Fatalf("explain not captured: %+v", select_)
+	}
+
+	top := SqlStats("stats", "count", 1)
+	if len(top) != 1 || top[0].Fingerprint != select_.Fingerprint {
+		t.Fatalf("top by count: %+v", top)
+	}
+	var insert *SqlStat
+	for _, s := range SqlStats("stats", "rows", 0) {
+		if strings.HasPrefix(s.Fingerprint, "insert into") {
+			s := s
+			insert = &s
+		}
+	}
+	if insert == nil || insert.Count != 3 || insert.Rows != 3 || insert.Explain != "" {
+		t.Fatalf("insert stat: %+v", insert)
+	}
+}
+
+func TestSqlStatsExplainBindsVarsWithoutKeepingThem(t *testing.T) {
+	fast_base.Logger = zap.NewNop()
+	ResetSqlStats()
+	t.Cleanup(ResetSqlStats)
+	openTestSqlite(t)
+	db := DB
+	sqlDB, _ := db.DB()
+	c := &sqlStatsCollector{source: "bind", driver: DriverSqlite, threshold: time.Nanosecond, explain: true, db: sqlDB}
+	c.register(db)
+
+	var users []testUser
+	if err := db.Where("name = ? AND id > ?", "secret-name", 987654).Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	var stat SqlStat
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && stat.Explain == "" {
+		if list := SqlStats("bind", "", 0); len(list) == 1 {
+			stat = list[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// EXPLAIN 使用绑定参数执行成功，统计中只有指纹和执行计划
+	if stat.Explain == "" || strings.HasPrefix(stat.Explain, "EXPLAIN 失败") {
+		t.Fatalf("explain with bind args: %+v", stat)
+	}
+	if !strings.Contains(stat.Fingerprint, "name = ? and") {
+		t.Fatalf("fingerprint: %q", stat.Fingerprint)
+	}
+	content, _ := json.Marshal(SqlStats("", "", 0))
+	if strings.Contains(string(content), "secret-name") || strings.Contains(string(content), "987654") {
+		t.Fatalf("stats should not keep literals: %s", content)
+	}
+}
+
+func TestSqlStatsAdminEndpoint(t *testing.T) {
+	ResetSqlStats()
+	t.Cleanup(ResetSqlStats)
+	registerSqlStatsAdmin()
+	c := &sqlStatsCollector{source: "admin", driver: DriverSqlite}
+	c.record(3*time.Millisecond, "SELECT 1 FROM a", nil, 1, nil)
+	c.record(time.Millisecond, "SELECT 2 FROM b", nil, 1, nil)
+	c.record(time.Millisecond, "SELECT 3 FROM b", nil, 1, nil)
+
+	h, ok := fast_base.FindAdminEndpoint("GET", "/db/sqlStats")
+	if !ok {
+		t.Fatal("endpoint not registered")
+	}
+	result, err := h(context.Background(), url.Values{"top": {"1"}, "sort": {"count"}, "source": {"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := result.([]SqlStat)
+	if len(list) != 1 || list[0].Fingerprint != "select ? from b" || list[0].Count != 2 {
+		t.Fatalf("unexpected: %+v", list)
+	}
+	if _, err := h(context.Background(), url.Values{"top": {"x"}}); err == nil {
+		t.Fatal("invalid top should fail")
+	}
+	reset, _ := fast_base.FindAdminEndpoint("POST", "/db/sqlStats/reset")
+	reset(context.Background(), nil)
+	if len(SqlStats("", "", 0)) != 0 {
+		t.Fatal("reset should clear stats")
+	}
+}
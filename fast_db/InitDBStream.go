@@ -0,0 +1,93 @@
+package fast_db
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+// DefaultFetchSize Stream 默认每批扫描的行数
+const DefaultFetchSize = 500
+
+// Stream 逐行读取查询结果，用于导出等大结果集场景，不会一次性加载到内存。
+// 基于 Rows() 实现，每次扫描 fetchSize 行；用完必须 Close，ctx 取消时查询随之中断。
+//
+//	s, err := fast_db.StreamBySql[User](ctx, 0, "SELECT * FROM user WHERE status = ?", 1)
+//	defer s.Close()
+//	for s.Next() {
+//		u := s.Value()
+//	}
+//	err = s.Err()
+type Stream[T any] struct {
+	db        *gorm.DB
+	rows      *sql.Rows
+	fetchSize int
+	batch     []T
+	pos       int
+	err       error
+}
+
+// StreamBySql 执行查询并返回逐行读取的 Stream。fetchSize <= 0 时使用 DefaultFetchSize
+func StreamBySql[T any](ctx context.Context, fetchSize int, sql string, params ...interface{}) (*Stream[T], error) {
+	if fetchSize <= 0 {
+		fetchSize = DefaultFetchSize
+	}
+	db := DBFrom(ctx)
+	rows, err := db.Raw(sql, params...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	return &Stream[T]{db: db, rows: rows, fetchSize: fetchSize}, nil
+}
+
+// Next 移动到下一行，没有更多数据或出错时返回 false，并关闭底层 Rows
+func (s *Stream[T]) Next() bool {
+	if s.pos+1 < len(s.batch) {
+		s.pos++
+		return true
+	}
+	if s.rows == nil {
+		return false
+	}
+	// 每批使用新的切片，之前通过 Value 取得的指针仍然有效
+	s.batch, s.pos = make([]T, 0, s.fetchSize), 0
+	for len(s.batch) < s.fetchSize && s.rows.Next() {
+		var v T
+		if err := s.db.ScanRows(s.rows, &v); err != nil {
+			s.err = err
+			break
+		}
+		s.batch = append(s.batch, v)
+	}
+	if s.err == nil {
+		s.err = s.rows.Err()
+	}
+	if s.err != nil || len(s.batch) < s.fetchSize {
+		s.Close()
+	}
+	if s.err != nil {
+		s.batch = nil
+	}
+	return len(s.batch) > 0
+}
+
+// Value 当前行
+func (s *Stream[T]) Value() *T {
+	return &s.batch[s.pos]
+}
+
+// Err 读取过程中的错误
+func (s *Stream[T]) Err() error {
+	return s.err
+}
+
+// Close 关闭底层 Rows，可重复调用
+func (s *Stream[T]) Close() error {
+	if s.rows == nil {
+		return nil
+	}
+	err := s.rows.Close()
+	s.rows = nil
+	return err
+}
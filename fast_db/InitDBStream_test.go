@@ -0,0 +1,62 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+func TestStreamBySql(t *testing.T) {
+	openTestSqlite(t)
+	for i := 1; i <= 7; i++ {
+		if err := DB.Create(&testUser{Model: Model{ID: fast_base.StringInt64(i)}, Name: "u"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := StreamBySql[testUser](context.Background(), 3, "SELECT * FROM user WHERE id > ? ORDER BY id", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var ids []int64
+	var first *testUser
+	for s.Next() {
+		if first == nil {
+			first = s.Value()
+		}
+		ids = append(ids, int64(s.Value().ID))
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 6 || ids[0] != 2 || ids[5] != 7 {
+		t.Fatalf("ids = %v", ids)
+	}
+	if first.ID != 2 {
+		t.Fatalf("earlier value overwritten: %d", first.ID)
+	}
+}
+
+func TestStreamBySqlCancelled(t *testing.T) {
+	openTestSqlite(t)
+	for i := 1; i <= 5; i++ {
+		DB.Create(&testUser{Model: Model{ID: fast_base.StringInt64(i)}, Name: "u"})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := StreamBySql[testUser](ctx, 1, "SELECT * FROM user ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if !s.Next() {
+		t.Fatal(s.Err())
+	}
+	cancel()
+	for s.Next() {
+	}
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", s.Err())
+	}
+}
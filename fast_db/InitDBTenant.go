@@ -0,0 +1,296 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 多租户：租户ID由 fast_web 放入请求 context(fast_base.WithTenant)，按策略隔离数据：
+//   - column   同一张表，按租户字段过滤：查询、更新、删除自动加 tenant_id = ?，插入时填写租户字段
+//   - schema   每个租户一个 schema，表名替换为 {schema}.{table}
+//   - database 每个租户一个数据源，DBFrom 返回该租户的数据源
+//
+// 只作用于基于模型的操作，Raw/Exec 执行的 SQL 不做处理。管理任务可用 WithoutTenant 跳过隔离。
+
+const (
+	TenantColumn   = "column"
+	TenantSchema   = "schema"
+	TenantDatabase = "database"
+)
+
+var (
+	// ErrTenantMissing context 中没有租户，拒绝访问租户数据
+	ErrTenantMissing = errors.New("缺少租户信息，拒绝访问租户数据")
+	// ErrTenantMismatch 写入的数据属于其他租户
+	ErrTenantMismatch = errors.New("数据的租户与当前租户不一致")
+	// ErrTenantInvalid 租户ID不能用于 schema 或数据源名称
+	ErrTenantInvalid = errors.New("租户ID不合法")
+)
+
+// TenantStrategy 租户隔离策略，可通过 SetTenantStrategy 替换为自定义实现
+type TenantStrategy interface {
+	// DB 选择该租户使用的连接，DBFrom 和 WithTx 在没有事务时调用
+	DB(ctx context.Context, tenantId string, db *gorm.DB) (*gorm.DB, error)
+	// Scoped 语句是否属于租户数据，属于时 context 中必须有租户
+	Scoped(stmt *gorm.Statement) bool
+	// Apply 在 GORM 回调中改写语句，op 为 create、query、update、delete、row
+	Apply(tx *gorm.DB, op string, tenantId string)
+}
+
+type tenantSkipKey struct{}
+
+// WithoutTenant 跳过租户隔离，用于跨租户的管理任务
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantSkipKey{}, true)
+}
+
+func tenantSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(tenantSkipKey{}).(bool)
+	return skip
+}
+
+var tenantLock sync.RWMutex
+var tenantStrategy TenantStrategy
+
+// SetTenantStrategy 设置租户策略，nil 表示关闭多租户
+func SetTenantStrategy(s TenantStrategy) {
+	tenantLock.Lock()
+	tenantStrategy = s
+	tenantLock.Unlock()
+}
+
+// GetTenantStrategy 当前租户策略，未启用时返回 nil
+func GetTenantStrategy() TenantStrategy {
+	tenantLock.RLock()
+	defer tenantLock.RUnlock()
+	return tenantStrategy
+}
+
+// newTenantStrategy 按配置创建内置策略
+func newTenantStrategy(conf TenantConfig) (TenantStrategy, error) {
+	if !conf.Enable {
+		return nil, nil
+	}
+	switch conf.Strategy {
+	case TenantColumn, "":
+		return &ColumnTenantStrategy{Column: conf.Column}, nil
+	case TenantSchema:
+		return &SchemaTenantStrategy{Pattern: conf.Pattern, Shared: conf.Shared}, nil
+	case TenantDatabase:
+		return &DatabaseTenantStrategy{Pattern: conf.Pattern}, nil
+	}
+	return nil, fmt.Errorf("不支持的租户策略: %s", conf.Strategy)
+}
+
+// tenantDB 没有事务时使用的连接，按租户策略选择
+func tenantDB(ctx context.Context) *gorm.DB {
+	db := DB.WithContext(ctx)
+	s := GetTenantStrategy()
+	if s == nil || tenantSkipped(ctx) {
+		return db
+	}
+	tenantId, ok := fast_base.TenantFrom(ctx)
+	if !ok {
+		return db
+	}
+	tdb, err := s.DB(ctx, tenantId, DB)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+	return tdb.WithContext(ctx)
+}
+
+// TenantPlugin GORM 插件，LoadDataSource 中为每个数据源注册，按当前租户策略改写语句
+type TenantPlugin struct{}
+
+func (p *TenantPlugin) Name() string {
+	return "fast:tenant"
+}
+
+func (p *TenantPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("fast:tenant_create", p.apply("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("fast:tenant_query", p.apply("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("fast:tenant_update", p.apply("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("fast:tenant_delete", p.apply("delete")); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("fast:tenant_row", p.apply("row"))
+}
+
+func (p *TenantPlugin) apply(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		s := GetTenantStrategy()
+		stmt := tx.Statement
+		// Raw 已写好 SQL，不做处理
+		if s == nil || tx.Error != nil || stmt.SQL.Len() > 0 || tenantSkipped(stmt.Context) || !s.Scoped(stmt) {
+			return
+		}
+		tenantId, ok := fast_base.TenantFrom(stmt.Context)
+		if !ok {
+			if ConfigTenant.Required {
+				tx.AddError(ErrTenantMissing)
+			}
+			return
+		}
+		s.Apply(tx, op, tenantId)
+	}
+}
+
+// ColumnTenantStrategy 按租户字段隔离，模型中有该列(默认 tenant_id)时生效
+type ColumnTenantStrategy struct {
+	Column string
+}
+
+func (s *ColumnTenantStrategy) DB(_ context.Context, _ string, db *gorm.DB) (*gorm.DB, error) {
+	return db, nil
+}
+
+func (s *ColumnTenantStrategy) field(stmt *gorm.Statement) *schema.Field {
+	if stmt.Schema == nil {
+		return nil
+	}
+	return stmt.Schema.LookUpField(s.Column)
+}
+
+func (s *ColumnTenantStrategy) Scoped(stmt *gorm.Statement) bool {
+	return s.field(stmt) != nil
+}
+
+func (s *ColumnTenantStrategy) Apply(tx *gorm.DB, op string, tenantId string) {
+	stmt := tx.Statement
+	f := s.field(stmt)
+	value, err := tenantValue(f, tenantId)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	switch op {
+	case "create":
+		eachRecord(stmt, func(rv reflect.Value) {
+			current, zero := f.ValueOf(stmt.Context, rv)
+			if zero {
+				if err := f.Set(stmt.Context, rv, value); err != nil {
+					tx.AddError(err)
+				}
+			} else if fmt.Sprint(reflect.Indirect(reflect.ValueOf(current)).Interface()) != tenantId {
+				tx.AddError(ErrTenantMismatch)
+			}
+		})
+	case "update":
+		// 不允许把数据改到其他租户
+		stmt.SetColumn(f.DBName, value, true)
+		fallthrough
+	default:
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: value}}})
+	}
+}
+
+// tenantValue 按字段类型转换租户ID
+func tenantValue(f *schema.Field, tenantId string) (any, error) {
+	t := f.FieldType
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(tenantId, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrTenantInvalid, tenantId)
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(tenantId, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrTenantInvalid, tenantId)
+		}
+		return n, nil
+	}
+	return tenantId, nil
+}
+
+// 用于 schema、数据源名称的租户ID只允许字母、数字、下划线和中划线，防止注入
+var tenantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tenantName(pattern, tenantId string) (string, error) {
+	if !tenantNamePattern.MatchString(tenantId) {
+		return "", fmt.Errorf("%w: %s", ErrTenantInvalid, tenantId)
+	}
+	if pattern == "" {
+		pattern = "tenant_%s"
+	}
+	return fmt.Sprintf(pattern, tenantId), nil
+}
+
+// SchemaTenantStrategy 每个租户一个 schema，Pattern 默认 tenant_%s。Shared 中的表所有租户共用
+type SchemaTenantStrategy struct {
+	Pattern string
+	Shared  []string
+}
+
+func (s *SchemaTenantStrategy) DB(_ context.Context, _ string, db *gorm.DB) (*gorm.DB, error) {
+	return db, nil
+}
+
+func (s *SchemaTenantStrategy) Scoped(stmt *gorm.Statement) bool {
+	// 已显式指定 schema 的表不处理
+	if stmt.Table == "" || strings.Contains(stmt.Table, ".") {
+		return false
+	}
+	for _, t := range s.Shared {
+		if strings.EqualFold(t, stmt.Table) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *SchemaTenantStrategy) Apply(tx *gorm.DB, _ string, tenantId string) {
+	name, err := tenantName(s.Pattern, tenantId)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	tx.Statement.Table = name + "." + tx.Statement.Table
+}
+
+// DatabaseTenantStrategy 每个租户一个数据源，名称由 Pattern(默认 tenant_%s)生成，需在 dataSources 中配置或通过 RegisterDataSource 注册
+type DatabaseTenantStrategy struct {
+	Pattern string
+}
+
+func (s *DatabaseTenantStrategy) DB(_ context.Context, tenantId string, _ *gorm.DB) (*gorm.DB, error) {
+	name, err := tenantName(s.Pattern, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	db, ok := GetDataSource(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: 租户 %s 没有数据源 %s", ErrTenantInvalid, tenantId, name)
+	}
+	return db, nil
+}
+
+func (s *DatabaseTenantStrategy) Scoped(*gorm.Statement) bool {
+	return false
+}
+
+func (s *DatabaseTenantStrategy) Apply(*gorm.DB, string, string) {}
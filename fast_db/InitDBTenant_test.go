@@ -0,0 +1,147 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+type tenantOrder struct {
+	Model
+	TenantId int64
+	Name     string
+}
+
+func openTenantTest(t *testing.T, s TenantStrategy) {
+	t.Helper()
+	openTestSqlite(t)
+	if err := DB.Exec("CREATE TABLE tenant_order (id INTEGER PRIMARY KEY, tenant_id INTEGER NOT NULL, name TEXT, created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)").Error; err != nil {
+		t.Fatal(err)
+	}
+	SetTenantStrategy(s)
+	t.Cleanup(func() { SetTenantStrategy(nil) })
+}
+
+func TestColumnTenantIsolation(t *testing.T) {
+	openTenantTest(t, &ColumnTenantStrategy{Column: "tenant_id"})
+	repo := NewRepository[tenantOrder]()
+	ctxA := fast_base.WithTenant(context.Background(), "1")
+	ctxB := fast_base.WithTenant(context.Background(), "2")
+
+	a := &tenantOrder{Name: "a"}
+	if err := repo.Create(ctxA, a); err != nil {
+		t.Fatal(err)
+	}
+	if a.TenantId != 1 {
+		t.Fatalf("tenant not filled: %d", a.TenantId)
+	}
+	if err := repo.Create(ctxB, &tenantOrder{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.FindById(ctxB, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("tenant 2 read tenant 1 data: %v", err)
+	}
+	page, err := repo.Page(ctxA, PageQuery{})
+	if err != nil || page.TotalRows != 1 || (*page.List)[0].Name != "a" {
+		t.Fatalf("page = %+v, err = %v", page, err)
+	}
+	if err := repo.UpdateFields(ctxB, a.ID, map[string]any{"name": "x"}, "name"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("tenant 2 updated tenant 1 data: %v", err)
+	}
+	if err := repo.Delete(ctxB, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 不能把数据改到其他租户
+	if err := DBFrom(ctxA).Model(&tenantOrder{}).Where("id = ?", a.ID).Updates(map[string]any{"tenant_id": 2, "name": "moved"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.FindById(ctxA, a.ID)
+	if err != nil || got.TenantId != 1 || got.Name != "moved" {
+		t.Fatalf("got = %+v, err = %v", got, err)
+	}
+
+	if err := repo.Create(ctxA, &tenantOrder{TenantId: 2, Name: "c"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("err = %v, want ErrTenantMismatch", err)
+	}
+	if _, err := repo.FindByIds(context.Background(), a.ID); !errors.Is(err, ErrTenantMissing) {
+		t.Fatalf("err = %v, want ErrTenantMissing", err)
+	}
+	// 原生 SQL 不受影响
+	if n := CountNum("SELECT id FROM tenant_order"); n != 2 {
+		t.Fatalf("raw count = %d", n)
+	}
+	previous := ConfigTenant.Required
+	ConfigTenant.Required = true
+	t.Cleanup(func() { ConfigTenant.Required = previous })
+	for _, ctx := range []context.Context{context.Background(), ctxA} {
+		var rows []tenantOrder
+		if err := DBFrom(ctx).Raw("SELECT * FROM tenant_order").Find(&rows).Error; err != nil || len(rows) != 2 {
+			t.Fatalf("raw find = %d rows, err = %v", len(rows), err)
+		}
+	}
+	var all []tenantOrder
+	if err := DBFrom(WithoutTenant(context.Background())).Find(&all).Error; err != nil || len(all) != 2 {
+		t.Fatalf("admin sees %d rows, err = %v", len(all), err)
+	}
+}
+
+func TestSchemaTenantRewritesTable(t *testing.T) {
+	openTenantTest(t, &SchemaTenantStrategy{Pattern: "tenant_%s", Shared: []string{"user"}})
+	dry := func(ctx context.Context) *gorm.DB {
+		return DBFrom(ctx).Session(&gorm.Session{DryRun: true})
+	}
+	ctx := fast_base.WithTenant(context.Background(), "7")
+
+	sql := dry(ctx).Find(&[]tenantOrder{}).Statement.SQL.String()
+	if !strings.Contains(sql, "`tenant_7`.`tenant_order`") {
+		t.Fatalf("sql = %s", sql)
+	}
+	sql = dry(ctx).Find(&[]testUser{}).Statement.SQL.String()
+	if strings.Contains(sql, "tenant_7") {
+		t.Fatalf("shared table rewritten: %s", sql)
+	}
+	bad := fast_base.WithTenant(context.Background(), "7`; DROP TABLE x; --")
+	if err := dry(bad).Find(&[]tenantOrder{}).Error; !errors.Is(err, ErrTenantInvalid) {
+		t.Fatalf("err = %v, want ErrTenantInvalid", err)
+	}
+}
+
+func TestDatabaseTenantSelectsDataSource(t *testing.T) {
+	openTenantTest(t, &DatabaseTenantStrategy{Pattern: "tenant_%s"})
+	conf := newDataSourceConfig("./testdata/migration")
+	conf.DriverName = DriverSqlite
+	conf.Database = filepath.Join(t.TempDir(), "tenant9.db")
+	tenantDb, err := OpenDataSource("tenant_9", conf.applyDriverDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterDataSource("tenant_9", tenantDb)
+	t.Cleanup(func() {
+		sqlDB, _ := tenantDb.DB()
+		sqlDB.Close()
+	})
+
+	ctx := fast_base.WithTenant(context.Background(), "9")
+	err = WithTx(ctx, func(ctx context.Context) error {
+		return insertUser(ctx, 1, "tenant9")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := CountNumContext(ctx, "SELECT id FROM user"); n != 1 {
+		t.Fatalf("tenant db count = %d", n)
+	}
+	if n := CountNum("SELECT id FROM user"); n != 0 {
+		t.Fatalf("default db count = %d", n)
+	}
+	unknown := fast_base.WithTenant(context.Background(), "10")
+	if err := DBFrom(unknown).Exec("SELECT 1").Error; !errors.Is(err, ErrTenantInvalid) {
+		t.Fatalf("err = %v, want ErrTenantInvalid", err)
+	}
+}
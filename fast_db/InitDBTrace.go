@@ -0,0 +1,58 @@
+package fast_db
+
+import (
+	"errors"
+	"github.com/tdwu/fast_go/fast_base"
+	"gorm.io/gorm"
+)
+
+const traceSpanKey = "fast:trace_span"
+
+// registerTrace 通过 GORM 回调为每条 SQL 创建 client span。
+// 只在 context 中已有链路(如 Web 请求内)时记录，后台任务的零散查询不单独成链。
+func registerTrace(name string, db *gorm.DB) {
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if !fast_base.ConfigTrace.Enable {
+				return
+			}
+			ctx := tx.Statement.Context
+			if _, ok := fast_base.SpanContextFrom(ctx); !ok {
+				return
+			}
+			_, span := fast_base.StartSpan(ctx, operation+" "+tx.Statement.Table, fast_base.SpanKindClient)
+			span.SetAttribute("db.system", tx.Dialector.Name())
+			span.SetAttribute("db.name", name)
+			span.SetAttribute("db.operation", operation)
+			tx.InstanceSet(traceSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(traceSpanKey)
+		if !ok {
+			return
+		}
+		span := v.(*fast_base.Span)
+		span.SetAttribute("db.sql.table", tx.Statement.Table)
+		span.SetAttribute("db.statement", tx.Statement.SQL.String())
+		span.SetAttribute("db.rows_affected", tx.RowsAffected)
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.SetError(tx.Error)
+		}
+		span.End()
+	}
+
+	cb := db.Callback()
+	cb.Create().Before("*").Register("fast:trace_before_create", before("INSERT"))
+	cb.Create().After("*").Register("fast:trace_after_create", after)
+	cb.Query().Before("*").Register("fast:trace_before_query", before("SELECT"))
+	cb.Query().After("*").Register("fast:trace_after_query", after)
+	cb.Update().Before("*").Register("fast:trace_before_update", before("UPDATE"))
+	cb.Update().After("*").Register("fast:trace_after_update", after)
+	cb.Delete().Before("*").Register("fast:trace_before_delete", before("DELETE"))
+	cb.Delete().After("*").Register("fast:trace_after_delete", after)
+	cb.Row().Before("*").Register("fast:trace_before_row", before("ROW"))
+	cb.Row().After("*").Register("fast:trace_after_row", after)
+	cb.Raw().Before("*").Register("fast:trace_before_raw", before("RAW"))
+	cb.Raw().After("*").Register("fast:trace_after_raw", after)
+}
@@ -0,0 +1,72 @@
+package fast_db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Propagation 事务传播方式，语义与 Spring 相同
+type Propagation int
+
+const (
+	// PropagationRequired 已有事务时加入，否则新建。内层返回错误会导致整个事务回滚
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew 总是新建独立事务(使用新的连接)，与外层事务互不影响
+	PropagationRequiresNew
+	// PropagationNested 已有事务时创建保存点，内层失败只回滚到保存点；否则新建
+	PropagationNested
+)
+
+type txContextKey struct{}
+
+// WithTx 在事务中执行 fn，已有事务时加入(PropagationRequired)。
+// fn 中应使用传入的 ctx 调用 DBFrom 或 ...Context 系列方法，才能在同一事务中执行：
+//
+//	err := fast_db.WithTx(ctx, func(ctx context.Context) error {
+//		if err := fast_db.DBFrom(ctx).Create(&order).Error; err != nil {
+//			return err
+//		}
+//		return fast_db.DBFrom(ctx).Model(&stock).Update("num", gorm.Expr("num - 1")).Error
+//	})
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithTxPropagation(ctx, PropagationRequired, fn)
+}
+
+// WithTxPropagation 按指定传播方式在默认数据源的事务中执行 fn。fn 返回错误或 panic 时回滚
+func WithTxPropagation(ctx context.Context, propagation Propagation, fn func(ctx context.Context) error) error {
+	current, inTx := TxFrom(ctx)
+	switch {
+	case inTx && propagation == PropagationRequired:
+		return fn(ctx)
+	case inTx && propagation == PropagationNested:
+		// gorm 在已开启的事务上调用 Transaction 时使用 SAVEPOINT
+		return current.Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txContextKey{}, tx))
+		})
+	default:
+		// 提交后使事务中写过的表的查询缓存失效
+		return withTxCache(ctx, func(ctx context.Context) error {
+			return tenantDB(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(context.WithValue(ctx, txContextKey{}, tx))
+			})
+		})
+	}
+}
+
+// TxFrom 获取 context 中的事务
+func TxFrom(ctx context.Context) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tx, ok := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx, ok
+}
+
+// DBFrom context 中有事务时返回该事务，否则返回默认数据源(按租户分库时为租户的数据源)，均已绑定 ctx
+func DBFrom(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return tenantDB(ctx)
+}
@@ -0,0 +1,86 @@
+package fast_db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+func countUsers(t *testing.T) int {
+	t.Helper()
+	return CountNum("SELECT id FROM user")
+}
+
+func insertUser(ctx context.Context, id int64, name string) error {
+	return DBFrom(ctx).Create(&testUser{Model: Model{ID: fast_base.StringInt64(id)}, Name: name}).Error
+}
+
+func TestWithTxRequiredRollsBackAll(t *testing.T) {
+	openTestSqlite(t)
+	ctx := context.Background()
+	err := WithTx(ctx, func(ctx context.Context) error {
+		if err := insertUser(ctx, 1, "outer"); err != nil {
+			return err
+		}
+		if CountNumContext(ctx, "SELECT id FROM user") != 1 {
+			t.Error("helpers with ctx should see the uncommitted row")
+		}
+		return WithTx(ctx, func(ctx context.Context) error {
+			if err := insertUser(ctx, 2, "inner"); err != nil {
+				return err
+			}
+			return errors.New("inner failed")
+		})
+	})
+	if err == nil || countUsers(t) != 0 {
+		t.Fatalf("required propagation should roll back everything: err=%v count=%d", err, countUsers(t))
+	}
+}
+
+func TestWithTxRequiresNewCommitsIndependently(t *testing.T) {
+	openTestSqlite(t)
+	err := WithTx(context.Background(), func(ctx context.Context) error {
+		if err := WithTxPropagation(ctx, PropagationRequiresNew, func(ctx context.Context) error {
+			return insertUser(ctx, 1, "audit")
+		}); err != nil {
+			return err
+		}
+		if err := insertUser(ctx, 2, "outer"); err != nil {
+			return err
+		}
+		return errors.New("outer failed")
+	})
+	if err == nil {
+		t.Fatal("expected outer error")
+	}
+	if GetById[testUser](1) == nil || GetById[testUser](2) != nil {
+		t.Fatal("requires new should survive the outer rollback")
+	}
+}
+
+func TestWithTxNestedRollsBackToSavepoint(t *testing.T) {
+	openTestSqlite(t)
+	err := WithTx(context.Background(), func(ctx context.Context) error {
+		if err := insertUser(ctx, 1, "outer"); err != nil {
+			return err
+		}
+		nestedErr := WithTxPropagation(ctx, PropagationNested, func(ctx context.Context) error {
+			if err := insertUser(ctx, 2, "nested"); err != nil {
+				return err
+			}
+			return errors.New("nested failed")
+		})
+		if nestedErr == nil {
+			t.Error("nested error should be returned")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetById[testUser](1) == nil || GetById[testUser](2) != nil {
+		t.Fatal("nested propagation should only roll back the savepoint")
+	}
+}
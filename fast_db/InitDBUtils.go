@@ -1,27 +1,49 @@
 package fast_db
 
 import (
+	"context"
 	"fmt"
 	"github.com/tdwu/fast_go/fast_base"
 	"gorm.io/gorm"
-	"strings"
 )
 
-// QueryPageListByDB 执行query
+// QueryPageListByDB 执行query。query 的 context 中有接口注解放入的数据权限码时追加数据权限条件
 func QueryPageListByDB[T any](param fast_base.PageParam, query *gorm.DB) (*fast_base.PageResult[T], error) {
 
 	// 创建 PageResult
 	r := fast_base.PageResult[T]{}
 	r.From(param)
+	if ctx := query.Statement.Context; ctx != nil {
+		if code, ok := fast_base.DataScopeFrom(ctx); ok {
+			query = query.Scopes(DataScope(ctx, code))
+		}
+	}
+	// 分片表按范围跨分片查询
+	if tables, rule, sr, ok, err := shardPageTables(query); err != nil {
+		return nil, err
+	} else if ok {
+		ctx := query.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := queryShardPage(ctx, &r, query, tables, rule, sr); err != nil {
+			return nil, err
+		}
+		return &r, nil
+	}
 	// 查询总数
 	var count int64
-	query.Count(&count)
+	if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return nil, err
+	}
 
 	// 设置分页参数
 	query = query.Limit(r.PageSize).Offset((r.PageIndex - 1) * r.PageSize)
 	// 查询分页数据
 	var results []T
-	query.Find(&results)
+	if err := query.Find(&results).Error; err != nil {
+		return nil, err
+	}
 
 	// 设置分页结果
 	r.Set(count, &results)
@@ -31,27 +53,65 @@ func QueryPageListByDB[T any](param fast_base.PageParam, query *gorm.DB) (*fast_
 
 // QueryPageListBySql 用于直接执行 SQL 查询，并将结果封装到指定的结构体中
 func QueryPageListBySql[T any](param fast_base.PageParam, sql string, params ...interface{}) (*fast_base.PageResult[T], error) {
+	return QueryPageListBySqlContext[T](context.Background(), param, sql, params...)
+}
+
+// QueryPageListBySqlContext 同 QueryPageListBySql，在 ctx 中的事务内执行
+func QueryPageListBySqlContext[T any](ctx context.Context, param fast_base.PageParam, sql string, params ...interface{}) (*fast_base.PageResult[T], error) {
+	return QueryPageListByPageSql[T](ctx, param, PageSql{Sql: sql, Params: params})
+}
+
+// PageSql 分页查询语句。CountSql 为空时由 Sql 自动生成总数查询，CountParams 为 nil 时沿用 Params
+type PageSql struct {
+	Sql         string
+	Params      []interface{}
+	CountSql    string
+	CountParams []interface{}
+}
+
+// QueryPageListByPageSql 分页查询，可自定义总数查询。不在事务中时数据与总数并发查询
+func QueryPageListByPageSql[T any](ctx context.Context, param fast_base.PageParam, q PageSql) (*fast_base.PageResult[T], error) {
 	// 创建 PageResult
 	r := fast_base.PageResult[T]{}
-	// 设置分页信息，假设从 params 中提取分页参数
 	r.From(param)
 
+	db := DBFrom(ctx)
+	countSql, countParams := q.CountSql, q.CountParams
+	if countSql == "" {
+		countSql, countParams = countQuery(db.Dialector.Name(), q.Sql, q.Params)
+	} else if countParams == nil {
+		countParams = q.Params
+	}
+
 	var results []T
-	// 拼接 SQL 查询
+	var count int64
 	offset := (r.PageIndex - 1) * r.PageSize
-	limitSQL := fmt.Sprintf("LIMIT %d, %d", offset, r.PageSize)
-
-	// 执行查询
-	db := DB
-	err := db.Raw(sql+" "+limitSQL, params...).Scan(&results).Error
-	if err != nil {
-		return nil, err
+	page := func() error {
+		return db.Raw(pageSQL(db, q.Sql, offset, r.PageSize), q.Params...).Scan(&results).Error
+	}
+	total := func() error {
+		return db.Raw(countSql, countParams...).Scan(&count).Error
 	}
 
-	// 查询总数
-	var count int64
-	countSQL := "SELECT COUNT(*) FROM (" + removeOrderBy(sql) + ") AS total_count"
-	err = db.Raw(countSQL, params...).Scan(&count).Error
+	var err error
+	if _, inTx := TxFrom(ctx); inTx {
+		// 事务只有一个连接，顺序执行
+		if err = page(); err == nil {
+			err = total()
+		}
+	} else {
+		var countErr error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			countErr = total()
+		}()
+		err = page()
+		<-done
+		if err == nil {
+			err = countErr
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -64,38 +124,71 @@ func QueryPageListBySql[T any](param fast_base.PageParam, sql string, params ...
 
 // GetListBySql 用于直接执行 SQL 查询，并将结果封装到指定的结构体中
 func GetListBySql[T any](sql string, params ...interface{}) (*[]T, error) {
+	return GetListBySqlContext[T](context.Background(), sql, params...)
+}
+
+// GetListBySqlContext 同 GetListBySql，在 ctx 中的事务内执行
+func GetListBySqlContext[T any](ctx context.Context, sql string, params ...interface{}) (*[]T, error) {
 	var results []T
 	// 执行查询
-	db := DB
-	err := db.Raw(sql, params...).Scan(&results).Error
+	err := DBFrom(ctx).Raw(sql, params...).Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
 
-	// 查询总数
-
 	return &results, nil
 }
 
 func GetById[T any](id interface{}) *T {
-	var result T
-	if err := DB.First(&result, id).Error; err != nil {
+	return GetByIdContext[T](context.Background(), id)
+}
+
+// GetByIdContext 启用查询缓存时按模型和主键缓存
+func GetByIdContext[T any](ctx context.Context, id interface{}) *T {
+	db := DBFrom(ctx)
+	result, err := cachedLoad(ctx, "id", func() ([]string, []any) {
+		table := modelTable[T](db)
+		if table == "" {
+			return nil, nil
+		}
+		return []string{cacheTag(table)}, []any{table, id}
+	}, func() (T, error) {
+		var result T
+		err := db.First(&result, id).Error
+		return result, err
+	})
+	if err != nil {
 		return nil // 发生错误
 	}
 	return &result
 }
 
 func GetOne[T any](sql string, params ...interface{}) *T {
-	var result T
-	if err := DB.Raw(sql, params...).First(&result).Error; err != nil {
+	return GetOneContext[T](context.Background(), sql, params...)
+}
+
+// GetOneContext 启用查询缓存时按规范化后的 SQL 和参数缓存
+func GetOneContext[T any](ctx context.Context, sql string, params ...interface{}) *T {
+	result, err := cachedLoad(ctx, "sql", func() ([]string, []any) {
+		return sqlTables(sql), []any{normalizeSQL(sql), params}
+	}, func() (T, error) {
+		var result T
+		err := DBFrom(ctx).Raw(sql, params...).First(&result).Error
+		return result, err
+	})
+	if err != nil {
 		return nil // 发生错误
 	}
 	return &result
 }
 
 func CheckExists(sql string, params ...interface{}) bool {
+	return CheckExistsContext(context.Background(), sql, params...)
+}
+
+func CheckExistsContext(ctx context.Context, sql string, params ...interface{}) bool {
 	var count int64
-	result := DB.Raw(sql, params...).Count(&count)
+	result := DBFrom(ctx).Raw(sql, params...).Count(&count)
 	if count > 0 {
 		return true
 	}
@@ -107,16 +200,19 @@ func CheckExists(sql string, params ...interface{}) bool {
 }
 
 func CountNum(sql string, params ...interface{}) int {
+	return CountNumContext(context.Background(), sql, params...)
+}
+
+func CountNumContext(ctx context.Context, sql string, params ...interface{}) int {
 	var count int64
-	DB.Raw(sql, params...).Count(&count)
+	DBFrom(ctx).Raw(sql, params...).Count(&count)
 	return int(count)
 }
 
-func removeOrderBy(sql string) string {
-	// 找到 ORDER BY 子句并去除
-	orderByIndex := strings.Index(strings.ToUpper(sql), "ORDER BY")
-	if orderByIndex != -1 {
-		return sql[:orderByIndex]
+// pageSQL 按方言拼接分页。MySQL 沿用 LIMIT offset, size，PostgreSQL、SQLite 使用 LIMIT size OFFSET offset
+func pageSQL(db *gorm.DB, sql string, offset, size int) string {
+	if db.Dialector.Name() == DriverMysql {
+		return fmt.Sprintf("%s LIMIT %d, %d", sql, offset, size)
 	}
-	return sql
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", sql, size, offset)
 }
@@ -0,0 +1,7 @@
+CREATE TABLE user (
+    id         INTEGER PRIMARY KEY,
+    name       VARCHAR(64) NOT NULL,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME
+);
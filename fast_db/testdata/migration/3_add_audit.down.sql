@@ -0,0 +1,3 @@
+ALTER TABLE user DROP COLUMN version;
+ALTER TABLE user DROP COLUMN updated_by;
+ALTER TABLE user DROP COLUMN created_by;
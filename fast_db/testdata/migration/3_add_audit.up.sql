@@ -0,0 +1,3 @@
+ALTER TABLE user ADD COLUMN created_by BIGINT;
+ALTER TABLE user ADD COLUMN updated_by BIGINT;
+ALTER TABLE user ADD COLUMN version BIGINT;
@@ -0,0 +1,106 @@
+package fast_utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// XlsxMaxRows 单个工作表的最大行数(含表头)
+const XlsxMaxRows = 1048576
+
+// ErrXlsxRowLimit 超出工作表最大行数
+var ErrXlsxRowLimit = errors.New("xlsx 超出工作表最大行数")
+
+// XlsxCell 单元格。Number 为 true 时按数值写入，否则按文本写入
+type XlsxCell struct {
+	Value  string
+	Number bool
+}
+
+// XlsxWriter 流式写入只有一个工作表的 xlsx。逐行写入 zip，不在内存中保留数据；
+// 字符串使用 inlineStr，无需事先收集共享字符串表。
+type XlsxWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+	buf   bytes.Buffer
+}
+
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+// NewXlsxWriter 写入固定部分并开始工作表
+func NewXlsxWriter(w io.Writer) (*XlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, p := range xlsxParts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &XlsxWriter{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow 写入一行
+func (x *XlsxWriter) WriteRow(cells ...XlsxCell) error {
+	if x.rows >= XlsxMaxRows {
+		return ErrXlsxRowLimit
+	}
+	x.rows++
+	x.buf.Reset()
+	x.buf.WriteString("<row>")
+	for _, c := range cells {
+		if c.Number {
+			x.buf.WriteString("<c><v>")
+			xml.EscapeText(&x.buf, []byte(c.Value))
+			x.buf.WriteString("</v></c>")
+		} else {
+			x.buf.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(&x.buf, []byte(c.Value))
+			x.buf.WriteString("</t></is></c>")
+		}
+	}
+	x.buf.WriteString("</row>")
+	_, err := x.sheet.Write(x.buf.Bytes())
+	return err
+}
+
+// Rows 已写入的行数
+func (x *XlsxWriter) Rows() int {
+	return x.rows
+}
+
+// Flush 把已压缩的数据写到底层 Writer
+func (x *XlsxWriter) Flush() error {
+	return x.zw.Flush()
+}
+
+// Close 结束工作表并写入 zip 目录，不关闭底层 Writer
+func (x *XlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
@@ -2,6 +2,7 @@ package fast_web
 
 import (
 	"fmt"
+	"time"
 )
 
 var ConfigServer = ServerConfig{LogLevel: "debug", Host: "0.0.0.0", Port: "8080", Upload: "./uploadStore/",
@@ -11,6 +12,18 @@ var ConfigServer = ServerConfig{LogLevel: "debug", Host: "0.0.0.0", Port: "8080"
 	Session: &Session{
 		Duration: "60",
 	},
+	Admin: &ServerAdminConfig{
+		Enable:       true,
+		Host:         "127.0.0.1",
+		Port:         "8081",
+		AllowIps:     []string{"127.0.0.1", "::1"},
+		DrainTimeout: 30,
+		PreStopDelay: 5,
+	},
+	Export: &ServerExportConfig{
+		MaxRows:   1000000,
+		FlushRows: 1000,
+	},
 }
 
 type ServerConfig struct {
@@ -22,6 +35,24 @@ type ServerConfig struct {
 	Session  *Session
 	Upload   string
 	LogLevel string // 日志打印级别 debug  info  warning  error
+	Admin    *ServerAdminConfig
+	Export   *ServerExportConfig
+	ApiKeys  []string // @auth apikey 接受的密钥，请求头 ApiKey 携带
+}
+
+// ServerAdminConfig 管理端口配置。管理接口与业务接口使用不同的监听地址，默认只监听本机回环地址
+type ServerAdminConfig struct {
+	Enable       bool
+	Host         string
+	Port         string
+	Token        string        // 管理令牌，请求头 AdminToken 携带
+	AllowIps     []string      // 允许访问的IP或网段(CIDR)，与 Token 满足其一即可
+	DrainTimeout time.Duration // 优雅关闭时等待处理中请求完成的最长时间，单位秒
+	PreStopDelay time.Duration // 标记关闭中之后、关闭业务端口之前的等待时间，单位秒，让探针看到 /readyz 失败并摘除流量
+}
+
+func (t ServerAdminConfig) Address() string {
+	return fmt.Sprintf("%s:%s", t.Host, t.Port)
 }
 
 type ServerStaticConfig struct {
@@ -12,7 +12,10 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -49,6 +52,8 @@ func LoadWebAll() *Server {
 func LoadWeb() *Server {
 
 	fast_base.ConfigAll.UnmarshalKey("server", &ConfigServer)
+	fast_base.LoadMetrics()
+	fast_base.LoadTrace()
 	//gin.SetMode("release")
 	gin.DefaultWriter = LogWriter{level: fast_base.LoggerLevel}
 	gin.DefaultErrorWriter = LogWriter{level: zapcore.ErrorLevel}
@@ -58,11 +63,18 @@ func LoadWeb() *Server {
 	execPath := strings.ReplaceAll(fast_base.ExecPath(), "\\", "/")
 	fast_base.Logger.Info("程序目录：" + d)
 
-	Container = new(Server)
+	Container = newServer()
 	Container.Gin = gin.New()
-
-	// 日志中间件
-	Container.Gin.Use(ginLogger(), ginRecovery())
+	// gin.Context 作为 context 传给数据库等下游时，取值与取消信号回落到 c.Request.Context()
+	Container.Gin.ContextWithFallback = true
+
+	// 请求计数与日志中间件，优雅关闭时根据计数等待处理中的请求
+	Container.Gin.Use(Container.inFlightCounter())
+	if fast_base.ConfigTrace.Enable {
+		// 链路追踪放在日志之前，日志才能带上 trace_id
+		Container.Gin.Use(ginTrace())
+	}
+	Container.Gin.Use(ginMetrics(), ginLogger(), ginRecovery())
 
 	// 跨域配置
 	allowCross := fast_base.ConfigAll.GetBool("server.cross.allow")
@@ -96,16 +108,14 @@ func LoadWeb() *Server {
 		Container.Gin.LoadHTMLGlob(fl)
 	}
 
-	Container.Gin.GET("shutdown", func(context *gin.Context) {
-		fast_base.Logger.Warn("收到指令关闭")
-		JSONIter(context, http.StatusForbidden, fast_base.Success("预计2S后完成,关闭中....."))
-		context.Abort()
-		go func() {
-			time.Sleep(time.Second * 2)
-			fast_base.Logger.Warn("执行关闭")
-			os.Exit(0)
-		}()
-	})
+	// 管理接口(关闭、状态等)挂在独立的管理端口上，不再暴露在业务端口
+	Container.loadAdmin()
+
+	// 健康检查 /healthz /readyz
+	Container.loadHealth()
+
+	// 指标 /metrics
+	Container.loadMetrics()
 
 	return Container
 }
@@ -115,8 +125,20 @@ type HandlerFunc func(*gin.Engine)
 var Container *Server
 
 type Server struct {
-	Gin        *gin.Engine
-	HttpServer *http.Server
+	Gin         *gin.Engine
+	HttpServer  *http.Server
+	Admin       *gin.Engine
+	AdminServer *http.Server
+
+	startTime time.Time
+	inFlight  atomic.Int64 // 处理中的业务请求数
+	draining  atomic.Bool  // 是否处于优雅关闭中
+	stopOnce  sync.Once
+	stopped   chan struct{}
+}
+
+func newServer() *Server {
+	return &Server{startTime: time.Now(), stopped: make(chan struct{})}
 }
 
 func (c *Server) LoadRouters(handlerFunc HandlerFunc) *Server {
@@ -351,9 +373,10 @@ func HandlerFuncWrapper(vm reflect.Value) gin.HandlerFunc {
 	}
 }
 
+// Run 启动服务并阻塞，直到通过管理接口或 Shutdown 完成优雅关闭
 func (c *Server) Run() *Server {
-	proxy.StartProxy()
-	c.Gin.Run(ConfigServer.Address())
+	c.RunAsService()
+	c.Wait()
 	return c
 }
 
@@ -370,11 +393,72 @@ func (c *Server) RunAsService() *Server {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
+	c.startAdmin()
 	return c
 }
 
+// Shutdown 优雅关闭：先标记为关闭中，/readyz 返回 503，等待 PreStopDelay 让探针摘除流量后停止接收新连接，
+// 在 DrainTimeout 内等待处理中的请求完成，再关闭代理和管理端口，最后执行各模块注册的关闭钩子。可重复调用，只执行一次。
 func (c *Server) Shutdown() *Server {
-	c.HttpServer.Shutdown(context.Background())
-	proxy.StopProxy()
+	c.stopOnce.Do(func() {
+		c.draining.Store(true)
+		fast_base.Logger.Warn("开始优雅关闭，处理中请求数：" + strconv.FormatInt(c.inFlight.Load(), 10))
+		if c.HttpServer != nil && ConfigServer.Admin.PreStopDelay > 0 {
+			// 业务端口仍在服务，探针在这段时间内看到 /readyz 失败
+			time.Sleep(ConfigServer.Admin.PreStopDelay * time.Second)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ConfigServer.Admin.DrainTimeout*time.Second)
+		defer cancel()
+		if c.HttpServer != nil {
+			if err := c.HttpServer.Shutdown(ctx); err != nil {
+				fast_base.Logger.Error("关闭业务端口超时：" + err.Error())
+			}
+		}
+		c.waitInFlight(ctx)
+		proxy.StopProxy()
+		if c.AdminServer != nil {
+			c.AdminServer.Shutdown(ctx)
+		}
+		fast_base.RunShutdownHooks(ctx)
+		fast_base.ShutdownTrace(ctx)
+		fast_base.Logger.Warn("优雅关闭完成，剩余请求数：" + strconv.FormatInt(c.inFlight.Load(), 10))
+		close(c.stopped)
+	})
 	return c
 }
+
+// Wait 阻塞直到 Shutdown 完成
+func (c *Server) Wait() {
+	<-c.stopped
+}
+
+// Draining 是否处于优雅关闭中
+func (c *Server) Draining() bool {
+	return c.draining.Load()
+}
+
+// InFlight 当前处理中的业务请求数
+func (c *Server) InFlight() int64 {
+	return c.inFlight.Load()
+}
+
+func (c *Server) inFlightCounter() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		c.inFlight.Add(1)
+		defer c.inFlight.Add(-1)
+		context.Next()
+	}
+}
+
+func (c *Server) waitInFlight(ctx context.Context) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for c.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package fast_web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/netip"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"github.com/tdwu/fast_go/fast_utils"
+)
+
+// AdminStatus 管理端状态信息
+type AdminStatus struct {
+	ApplicationId string `json:"applicationId"`
+	StartTime     string `json:"startTime"`
+	Uptime        string `json:"uptime"`
+	InFlight      int64  `json:"inFlight"`
+	Draining      bool   `json:"draining"`
+	Goroutines    int    `json:"goroutines"`
+	GoVersion     string `json:"goVersion"`
+	LogLevel      string `json:"logLevel"`
+}
+
+type AdminLogLevelRequest struct {
+	Level string `json:"level" form:"level" validate:"required,oneof=debug info warn error"`
+}
+
+// 配置导出时需要脱敏的关键字
+var adminSecretKeys = []string{"password", "secret", "token", "credential", "apikey"}
+
+// loadAdmin 构建管理端路由。所有接口都要求管理令牌或来源IP在白名单内
+func (c *Server) loadAdmin() {
+	c.Admin = gin.New()
+	c.Admin.Use(ginLogger(), ginRecovery())
+
+	conf := ConfigServer.Admin
+	if conf.Enable && conf.Token == "" && len(conf.AllowIps) == 0 {
+		fast_base.Logger.Warn("管理端口未配置 token 和 allowIps，所有管理请求都将被拒绝")
+	}
+
+	group := c.Admin.Group("/admin", adminAuth(conf))
+	group.GET("/status", JSONHandler(c.adminStatus))
+	group.GET("/config", JSONHandler(adminConfig))
+	group.GET("/logLevel", JSONHandler(adminGetLogLevel))
+	group.POST("/logLevel", JSONHandler(adminSetLogLevel))
+	group.POST("/shutdown", c.adminShutdown)
+	group.GET("/endpoints", JSONHandler(adminEndpoints))
+	// 其他模块通过 fast_base.RegisterAdminEndpoint 注册的接口，请求时查找，与模块加载顺序无关
+	c.Admin.NoRoute(adminAuth(conf), adminExtension)
+}
+
+func (c *Server) startAdmin() {
+	if !ConfigServer.Admin.Enable {
+		return
+	}
+	c.AdminServer = &http.Server{
+		Addr:    ConfigServer.Admin.Address(),
+		Handler: c.Admin.Handler(),
+	}
+	go func() {
+		fast_base.Logger.Info("管理端口监听：" + ConfigServer.Admin.Address())
+		if err := c.AdminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			// 管理端口失败不影响业务端口
+			fast_base.Logger.Error("管理端口启动失败：" + err.Error())
+		}
+	}()
+}
+
+// adminAuth 管理令牌(请求头 AdminToken)或来源IP白名单，满足其一即可。
+// 来源IP取 TCP 对端地址，不信任 X-Forwarded-For 等可伪造的请求头。
+func adminAuth(conf *ServerAdminConfig) gin.HandlerFunc {
+	prefixes := parseAllowIps(conf.AllowIps)
+	return func(c *gin.Context) {
+		if conf.Token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("AdminToken")), []byte(conf.Token)) == 1 {
+			c.Next()
+			return
+		}
+		if ip, err := netip.ParseAddr(c.RemoteIP()); err == nil {
+			for _, p := range prefixes {
+				if p.Contains(ip.Unmap()) {
+					c.Next()
+					return
+				}
+			}
+		}
+		fast_base.Logger.Warn("拒绝管理请求：" + c.RemoteIP() + " " + c.Request.URL.Path)
+		c.Abort()
+		JSONIter(c, http.StatusForbidden, fast_base.Error(http.StatusForbidden, "无权访问管理接口"))
+	}
+}
+
+func parseAllowIps(ips []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(ips))
+	for _, s := range ips {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			if p, err := netip.ParsePrefix(s); err == nil {
+				prefixes = append(prefixes, p.Masked())
+				continue
+			}
+		} else if a, err := netip.ParseAddr(s); err == nil {
+			a = a.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+			continue
+		}
+		fast_base.Logger.Error("管理端口 allowIps 配置无效：" + s)
+	}
+	return prefixes
+}
+
+func (c *Server) adminStatus(_ *gin.Context, _ *struct{}) (AdminStatus, error) {
+	return AdminStatus{
+		ApplicationId: fast_base.ConfigEnv.GetApplicationId(),
+		StartTime:     fast_utils.GetTimeStr(&c.startTime),
+		Uptime:        time.Since(c.startTime).Truncate(time.Second).String(),
+		InFlight:      c.InFlight(),
+		Draining:      c.Draining(),
+		Goroutines:    runtime.NumGoroutine(),
+		GoVersion:     runtime.Version(),
+		LogLevel:      fast_base.LoggerAtomicLevel.String(),
+	}, nil
+}
+
+// adminConfig 导出当前生效的配置，密码、令牌等敏感项脱敏
+func adminConfig(_ *gin.Context, _ *struct{}) (map[string]any, error) {
+	if fast_base.ConfigAll == nil {
+		return map[string]any{}, nil
+	}
+	return maskSecrets(fast_base.ConfigAll.AllSettings()), nil
+}
+
+// maskSecrets 键名包含敏感关键字的项整体脱敏，其余递归处理嵌套的对象和列表(如 replicas[*].password)
+func maskSecrets(settings map[string]any) map[string]any {
+	for k, v := range settings {
+		if isSecretKey(k) {
+			settings[k] = "******"
+			continue
+		}
+		settings[k] = maskSecretValue(v)
+	}
+	return settings
+}
+
+func maskSecretValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		return maskSecrets(value)
+	case []any:
+		for i, item := range value {
+			value[i] = maskSecretValue(item)
+		}
+	}
+	return v
+}
+
+func isSecretKey(k string) bool {
+	lower := strings.ToLower(k)
+	for _, key := range adminSecretKeys {
+		if strings.Contains(lower, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func adminGetLogLevel(_ *gin.Context, _ *struct{}) (string, error) {
+	return fast_base.LoggerAtomicLevel.String(), nil
+}
+
+func adminSetLogLevel(_ *gin.Context, req *AdminLogLevelRequest) (string, error) {
+	if err := fast_base.SetLogLevel(req.Level); err != nil {
+		return "", err
+	}
+	fast_base.Logger.Warn("日志级别调整为：" + req.Level)
+	return req.Level, nil
+}
+
+// adminEndpoints 其他模块注册的管理接口
+func adminEndpoints(_ *gin.Context, _ *struct{}) ([]string, error) {
+	return fast_base.AdminEndpoints(), nil
+}
+
+// adminExtension 调用 fast_base 中注册的管理接口
+func adminExtension(c *gin.Context) {
+	path, ok := strings.CutPrefix(c.Request.URL.Path, "/admin/")
+	handler, found := fast_base.FindAdminEndpoint(c.Request.Method, path)
+	if !ok || !found {
+		JSONIter(c, http.StatusNotFound, fast_base.Error(http.StatusNotFound, "管理接口不存在"))
+		return
+	}
+	result, err := handler(c.Request.Context(), c.Request.URL.Query())
+	if err != nil {
+		writeApplicationError(c, err)
+		return
+	}
+	writeResponse(c, result)
+}
+
+// adminShutdown 触发优雅关闭。先返回响应，再异步等待处理中的请求完成，不再直接 os.Exit
+func (c *Server) adminShutdown(context *gin.Context) {
+	fast_base.Logger.Warn("收到关闭指令：" + context.RemoteIP())
+	JSONIter(context, http.StatusAccepted, fast_base.Success("关闭中，处理中请求数："+fast_utils.IntToStr(c.InFlight())))
+	go c.Shutdown()
+}
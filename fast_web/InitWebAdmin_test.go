@@ -0,0 +1,179 @@
+package fast_web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+func newAdminTestServer(t *testing.T, conf ServerAdminConfig) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	fast_base.Logger = zap.NewNop()
+	previous := ConfigServer.Admin
+	ConfigServer.Admin = &conf
+	t.Cleanup(func() { ConfigServer.Admin = previous })
+
+	server := newServer()
+	server.loadAdmin()
+	return server
+}
+
+func serveAdmin(server *Server, method, path, remoteAddr, token string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(method, path, strings.NewReader(""))
+	request.RemoteAddr = remoteAddr
+	if token != "" {
+		request.Header.Set("AdminToken", token)
+	}
+	server.Admin.ServeHTTP(response, request)
+	return response
+}
+
+func TestAdminRejectsUnknownCallers(t *testing.T) {
+	server := newAdminTestServer(t, ServerAdminConfig{Token: "secret", AllowIps: []string{"10.0.0.0/8"}})
+
+	if response := serveAdmin(server, http.MethodGet, "/admin/status", "192.0.2.1:4000", ""); response.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without token, got %d", response.Code)
+	}
+	if response := serveAdmin(server, http.MethodGet, "/admin/status", "192.0.2.1:4000", "wrong"); response.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 with wrong token, got %d", response.Code)
+	}
+	if response := serveAdmin(server, http.MethodGet, "/admin/status", "192.0.2.1:4000", "secret"); response.Code != http.StatusOK {
+		t.Fatalf("expected 200 with token, got %d: %s", response.Code, response.Body.String())
+	}
+	if response := serveAdmin(server, http.MethodGet, "/admin/status", "10.1.2.3:4000", ""); response.Code != http.StatusOK {
+		t.Fatalf("expected 200 from allowed network, got %d", response.Code)
+	}
+}
+
+func TestAdminShutdownRequiresPostAndDrains(t *testing.T) {
+	server := newAdminTestServer(t, ServerAdminConfig{AllowIps: []string{"127.0.0.1"}, DrainTimeout: 1})
+
+	if response := serveAdmin(server, http.MethodGet, "/admin/shutdown", "127.0.0.1:4000", ""); response.Code == http.StatusAccepted {
+		t.Fatal("shutdown must not be reachable with GET")
+	}
+	if response := serveAdmin(server, http.MethodPost, "/admin/shutdown", "127.0.0.1:4000", ""); response.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", response.Code, response.Body.String())
+	}
+
+	select {
+	case <-server.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown did not complete")
+	}
+	if !server.Draining() {
+		t.Fatal("server should report draining after shutdown")
+	}
+}
+
+func TestReadinessFailsBeforeListenerCloses(t *testing.T) {
+	server := newAdminTestServer(t, ServerAdminConfig{DrainTimeout: 1, PreStopDelay: 1})
+	server.Gin = gin.New()
+	server.loadHealth()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HttpServer = &http.Server{Handler: server.Gin}
+	go server.HttpServer.Serve(listener)
+	readyz := "http://" + listener.Addr().String() + readyzPath
+
+	go server.Shutdown()
+	deadline := time.Now().Add(900 * time.Millisecond)
+	for {
+		response, err := http.Get(readyz)
+		if err != nil {
+			t.Fatalf("listener closed before the pre-stop delay: %v", err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode == http.StatusServiceUnavailable && strings.Contains(string(body), "服务关闭中") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("readyz never reported draining: %d %s", response.StatusCode, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	<-server.stopped
+	if _, err := http.Get(readyz); err == nil {
+		t.Fatal("listener should be closed after shutdown")
+	}
+}
+
+func TestMaskSecretsHidesNestedCredentials(t *testing.T) {
+	settings := maskSecrets(map[string]any{
+		"datasource": map[string]any{"host": "db", "password": "p"},
+		"server":     map[string]any{"admin": map[string]any{"token": "t"}},
+	})
+	if settings["datasource"].(map[string]any)["password"] != "******" || settings["datasource"].(map[string]any)["host"] != "db" {
+		t.Fatalf("unexpected masking: %#v", settings)
+	}
+	if settings["server"].(map[string]any)["admin"].(map[string]any)["token"] != "******" {
+		t.Fatalf("nested token not masked: %#v", settings)
+	}
+}
+
+func TestMaskSecretsHidesReplicaPasswords(t *testing.T) {
+	settings := maskSecrets(map[string]any{
+		"datasource": map[string]any{
+			"replicas": []any{
+				map[string]any{"host": "r1", "password": "p1"},
+				map[string]any{"host": "r2", "password": "p2"},
+			},
+		},
+	})
+	for i, r := range settings["datasource"].(map[string]any)["replicas"].([]any) {
+		replica := r.(map[string]any)
+		if replica["password"] != "******" || replica["host"] == "******" {
+			t.Fatalf("replica %d not masked: %#v", i, replica)
+		}
+	}
+}
+
+func TestAdminConfigHidesApiKeys(t *testing.T) {
+	previous := fast_base.ConfigAll
+	t.Cleanup(func() { fast_base.ConfigAll = previous })
+	fast_base.ConfigAll = viper.New()
+	fast_base.ConfigAll.Set("server.apiKeys", []string{"k1", "k2"})
+	fast_base.ConfigAll.Set("server.port", 8080)
+
+	settings, err := adminConfig(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := settings["server"].(map[string]any)
+	if server["apikeys"] != "******" || server["port"] != 8080 {
+		t.Fatalf("api keys not masked: %#v", server)
+	}
+}
+
+func TestAdminExtensionEndpoint(t *testing.T) {
+	server := newAdminTestServer(t, ServerAdminConfig{Token: "secret"})
+	fast_base.RegisterAdminEndpoint(http.MethodGet, "/test/echo", func(_ context.Context, params url.Values) (any, error) {
+		return params.Get("q"), nil
+	})
+
+	if response := serveAdmin(server, http.MethodGet, "/admin/test/echo?q=hi", "192.0.2.1:4000", ""); response.Code != http.StatusForbidden {
+		t.Fatalf("extension endpoints require admin auth, got %d", response.Code)
+	}
+	response := serveAdmin(server, http.MethodGet, "/admin/test/echo?q=hi", "192.0.2.1:4000", "secret")
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"hi"`) {
+		t.Fatalf("unexpected response %d: %s", response.Code, response.Body.String())
+	}
+	if response := serveAdmin(server, http.MethodPost, "/admin/test/echo", "192.0.2.1:4000", "secret"); response.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unregistered method, got %d", response.Code)
+	}
+}
@@ -51,7 +51,8 @@ func findGinCaller(s int) *zapcore.EntryCaller {
 
 // //////////////////////////////////为gin创建日志中间件，集成zap日志框架//////////////////////////////////////////////////////////
 func ginLogger() gin.HandlerFunc {
-	var skip map[string]struct{}
+	// 探针请求频繁，不记录
+	skip := map[string]struct{}{healthzPath: {}, readyzPath: {}, fast_base.ConfigMetrics.Path: {}}
 
 	level, ok := fast_base.LogLevelMap[ConfigServer.LogLevel] // 日志打印级别
 	if !ok {
@@ -104,7 +105,7 @@ func ginLogger() gin.HandlerFunc {
 
 			message := formatMessage(param)
 
-			fast_base.PrintfWithCaller(level, findGinCaller(0), "%s", message)
+			fast_base.PrintfWithContext(c.Request.Context(), level, findGinCaller(0), "%s", message)
 		}
 	}
 }
@@ -202,13 +203,14 @@ func customRecover(handle gin.RecoveryFunc) gin.HandlerFunc {
 					}
 				}
 				headersToStr := strings.Join(headers, "\r\n")
+				logger := fast_base.LoggerWithContext(c.Request.Context())
 				if brokenPipe {
-					fast_base.Logger.Error(fmt.Sprintf(fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]: %s"+reset+"\n%s%s", err, headersToStr, reset))
+					logger.Error(fmt.Sprintf(fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]: %s"+reset+"\n%s%s", err, headersToStr, reset))
 				} else if gin.IsDebugging() {
-					fast_base.Logger.Error(fmt.Sprintf("[Recovery] %s panic recovered:\n%s\n"+fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]: %s"+reset+"\n%s%s",
+					logger.Error(fmt.Sprintf("[Recovery] %s panic recovered:\n%s\n"+fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]: %s"+reset+"\n%s%s",
 						timeFormat(time.Now()), headersToStr, err, stack, reset))
 				} else {
-					fast_base.Logger.Error(fmt.Sprintf("[Recovery] %s panic recovered:\n"+fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]:%s"+reset+"\n%s%s",
+					logger.Error(fmt.Sprintf("[Recovery] %s panic recovered:\n"+fast_base.IfStr(fast_base.ConfigLog.Color, red, "")+"[Panic]:%s"+reset+"\n%s%s",
 						timeFormat(time.Now()), err, stack, reset))
 				}
 				if brokenPipe {
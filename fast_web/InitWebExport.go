@@ -0,0 +1,330 @@
+package fast_web
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"github.com/tdwu/fast_go/fast_utils"
+)
+
+// 流式导出：逐行读取数据并以 chunked 方式写出 CSV 或 XLSX，不在内存中保留整个结果集。
+// 表头取字段的 export 标签，没有时依次使用 json 名称和字段名；export:"-" 或 json:"-" 的字段不导出。
+// 带 jsonDict 标签的字段输出数据字典中的名称。
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// ExportTruncatedTrailer 超出 MaxRows 被截断时设置的 HTTP trailer
+const ExportTruncatedTrailer = "X-Export-Truncated"
+
+// ServerExportConfig 导出配置
+type ServerExportConfig struct {
+	MaxRows   int // 单次导出的最大行数(不含表头)，超出部分丢弃
+	FlushRows int // 每写出多少行刷新一次
+}
+
+// ErrExportFormat 不支持的导出格式
+var ErrExportFormat = errors.New("不支持的导出格式")
+
+// RowIterator 逐行读取的数据源，fast_db.Stream 实现了该接口
+type RowIterator[T any] interface {
+	Next() bool
+	Value() *T
+	Err() error
+	Close() error
+}
+
+// SliceRows 把切片包装为 RowIterator，用于导出已在内存中的数据
+func SliceRows[T any](list []T) RowIterator[T] {
+	return &sliceRows[T]{list: list, pos: -1}
+}
+
+type sliceRows[T any] struct {
+	list []T
+	pos  int
+}
+
+func (s *sliceRows[T]) Next() bool   { s.pos++; return s.pos < len(s.list) }
+func (s *sliceRows[T]) Value() *T    { return &s.list[s.pos] }
+func (s *sliceRows[T]) Err() error   { return nil }
+func (s *sliceRows[T]) Close() error { return nil }
+
+// Export 按格式导出，format 为 csv 或 xlsx。filename 不含扩展名。
+// 开始写出后出错时响应已发送，只能中断连接，调用方不应再写入响应
+func Export[T any](c *gin.Context, format, filename string, rows RowIterator[T]) error {
+	defer rows.Close()
+	cols := exportColumnsOf(reflect.TypeOf((*T)(nil)).Elem())
+
+	var w exportWriter
+	switch format {
+	case ExportFormatCSV:
+		exportHeaders(c, "text/csv; charset=utf-8", filename+".csv")
+		// BOM，Excel 打开时按 UTF-8 识别中文
+		c.Writer.WriteString("\ufeff")
+		w = &csvExportWriter{w: csv.NewWriter(c.Writer)}
+	case ExportFormatXLSX:
+		exportHeaders(c, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", filename+".xlsx")
+		xw, err := fast_utils.NewXlsxWriter(c.Writer)
+		if err != nil {
+			return err
+		}
+		w = &xlsxExportWriter{w: xw}
+	default:
+		return fmt.Errorf("%w: %s", ErrExportFormat, format)
+	}
+
+	header := make([]exportCell, len(cols))
+	for i, col := range cols {
+		header[i] = exportCell{value: col.title}
+	}
+	if err := w.write(header); err != nil {
+		return err
+	}
+
+	conf := ConfigServer.Export
+	maxRows := conf.MaxRows
+	if format == ExportFormatXLSX && (maxRows <= 0 || maxRows > fast_utils.XlsxMaxRows-1) {
+		maxRows = fast_utils.XlsxMaxRows - 1
+	}
+	flushRows := conf.FlushRows
+	if flushRows <= 0 {
+		flushRows = 1000
+	}
+
+	ctx := c.Request.Context()
+	record := make([]exportCell, len(cols))
+	count, truncated := 0, false
+	for rows.Next() {
+		// 客户端断开后停止读取，rows.Close 同时中断查询
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if maxRows > 0 && count >= maxRows {
+			truncated = true
+			break
+		}
+		v := reflect.ValueOf(rows.Value()).Elem()
+		for i, col := range cols {
+			record[i] = col.cell(v)
+		}
+		if err := w.write(record); err != nil {
+			return err
+		}
+		count++
+		if count%flushRows == 0 {
+			if err := w.flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	if truncated {
+		c.Writer.Header().Set(ExportTruncatedTrailer, "true")
+		fast_base.LoggerWithContext(ctx).Warn("导出超出最大行数被截断, rows=" + strconv.Itoa(count))
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// ExportCSV 导出 CSV
+func ExportCSV[T any](c *gin.Context, filename string, rows RowIterator[T]) error {
+	return Export(c, ExportFormatCSV, filename, rows)
+}
+
+// ExportXLSX 导出 XLSX
+func ExportXLSX[T any](c *gin.Context, filename string, rows RowIterator[T]) error {
+	return Export(c, ExportFormatXLSX, filename, rows)
+}
+
+func exportHeaders(c *gin.Context, contentType, filename string) {
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	h.Set("Cache-Control", "no-store")
+	h.Set("Trailer", ExportTruncatedTrailer)
+	c.Status(http.StatusOK)
+}
+
+type exportCell struct {
+	value  string
+	number bool
+}
+
+type exportWriter interface {
+	write(cells []exportCell) error
+	flush() error
+	close() error
+}
+
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (e *csvExportWriter) write(cells []exportCell) error {
+	e.record = e.record[:0]
+	for _, c := range cells {
+		e.record = append(e.record, c.value)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvExportWriter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExportWriter) close() error {
+	return e.flush()
+}
+
+type xlsxExportWriter struct {
+	w     *fast_utils.XlsxWriter
+	cells []fast_utils.XlsxCell
+}
+
+func (e *xlsxExportWriter) write(cells []exportCell) error {
+	e.cells = e.cells[:0]
+	for _, c := range cells {
+		e.cells = append(e.cells, fast_utils.XlsxCell{Value: c.value, Number: c.number})
+	}
+	return e.w.WriteRow(e.cells...)
+}
+
+func (e *xlsxExportWriter) flush() error {
+	return e.w.Flush()
+}
+
+func (e *xlsxExportWriter) close() error {
+	return e.w.Close()
+}
+
+type exportColumn struct {
+	title string
+	index []int
+	dict  string
+}
+
+var exportColumnCache sync.Map
+
+// exportColumnsOf 解析导出列，展开匿名嵌入的结构体(如 fast_db.Model)
+func exportColumnsOf(t reflect.Type) []exportColumn {
+	if cols, ok := exportColumnCache.Load(t); ok {
+		return cols.([]exportColumn)
+	}
+	var cols []exportColumn
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int{}, index...), i)
+			title := f.Tag.Get("export")
+			jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+			if title == "-" || jsonName == "-" {
+				continue
+			}
+			// 嵌入的结构体即使类型未导出，其字段仍会被提升
+			if f.Anonymous && title == "" && f.Type.Kind() == reflect.Struct && !isExportScalar(f.Type) {
+				walk(f.Type, idx)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if title == "" {
+				title = jsonName
+			}
+			if title == "" {
+				title = f.Name
+			}
+			cols = append(cols, exportColumn{title: title, index: idx, dict: f.Tag.Get("jsonDict")})
+		}
+	}
+	walk(t, nil)
+	exportColumnCache.Store(t, cols)
+	return cols
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// isExportScalar 作为单个值输出的结构体，如 time.Time、gorm.DeletedAt
+func isExportScalar(t reflect.Type) bool {
+	return t == timeType || t.Implements(valuerType)
+}
+
+func (col exportColumn) cell(v reflect.Value) exportCell {
+	f := v.FieldByIndex(col.index)
+	c := exportValue(f)
+	if col.dict != "" {
+		return exportCell{value: fast_base.DictCenter[col.dict][c.value]}
+	}
+	return c
+}
+
+// exportValue 转换单元格。超过 15 位的整数按文本输出，避免 Excel 丢失精度
+func exportValue(f reflect.Value) exportCell {
+	for f.Kind() == reflect.Pointer || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return exportCell{}
+		}
+		f = f.Elem()
+	}
+	if f.Type() == timeType {
+		t := f.Interface().(time.Time)
+		if t.IsZero() {
+			return exportCell{}
+		}
+		return exportCell{value: t.Format(time.DateTime)}
+	}
+	if f.Type().Implements(valuerType) {
+		dv, err := f.Interface().(driver.Valuer).Value()
+		if err != nil || dv == nil {
+			return exportCell{}
+		}
+		return exportValue(reflect.ValueOf(dv))
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := f.Int()
+		return exportCell{value: strconv.FormatInt(n, 10), number: n > -1e15 && n < 1e15}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := f.Uint()
+		return exportCell{value: strconv.FormatUint(n, 10), number: n < 1e15}
+	case reflect.Float32, reflect.Float64:
+		return exportCell{value: strconv.FormatFloat(f.Float(), 'f', -1, 64), number: true}
+	case reflect.Bool:
+		return exportCell{value: strconv.FormatBool(f.Bool())}
+	case reflect.String:
+		return exportCell{value: escapeFormula(f.String())}
+	default:
+		return exportCell{value: escapeFormula(fmt.Sprint(f.Interface()))}
+	}
+}
+
+// escapeFormula 以 = + - @ 开头的文本加单引号前缀，防止被表格软件当作公式执行
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
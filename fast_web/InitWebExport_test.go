@@ -0,0 +1,137 @@
+package fast_web
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+type exportBase struct {
+	ID        fast_base.StringInt64
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type exportUser struct {
+	exportBase
+	Name    string `export:"姓名"`
+	Sex     int    `json:"sex" jsonDict:"sex"`
+	Secret  string `json:"-"`
+	Comment string `json:"comment"`
+}
+
+func exportRequest(t *testing.T, ctx context.Context, format string, list []exportUser) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var exportErr error
+	router := gin.New()
+	router.GET("/export", func(c *gin.Context) {
+		exportErr = Export(c, format, "用户", SliceRows(list))
+	})
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/export", nil).WithContext(ctx)
+	router.ServeHTTP(response, request)
+	return response, exportErr
+}
+
+func exportTestUsers() []exportUser {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	return []exportUser{
+		{exportBase{1, created}, "张三", 1, "x", "=HYPERLINK(\"http://evil\")"},
+		{exportBase{9007199254740993, time.Time{}}, "Li, Si", 2, "y", "ok"},
+		{exportBase{3, created}, "王五", 3, "z", ""},
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	fast_base.DictCenter["sex"] = map[string]string{"1": "男", "2": "女"}
+	defer delete(fast_base.DictCenter, "sex")
+
+	response, err := exportRequest(t, context.Background(), ExportFormatCSV, exportTestUsers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := response.Header().Get("Content-Disposition"); !strings.Contains(got, "%E7%94%A8%E6%88%B7.csv") {
+		t.Fatalf("Content-Disposition = %s", got)
+	}
+	want := "\ufeffID,createdAt,姓名,sex,comment\n" +
+		"1,2026-01-02 03:04:05,张三,男,\"'=HYPERLINK(\"\"http://evil\"\")\"\n" +
+		"9007199254740993,,\"Li, Si\",女,ok\n" +
+		"3,2026-01-02 03:04:05,王五,,\n"
+	if got := response.Body.String(); got != want {
+		t.Fatalf("csv\n got: %q\nwant: %q", got, want)
+	}
+	if response.Result().Trailer.Get(ExportTruncatedTrailer) != "" {
+		t.Fatal("unexpected truncated trailer")
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	response, err := exportRequest(t, context.Background(), ExportFormatXLSX, exportTestUsers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := response.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			b, _ := io.ReadAll(r)
+			sheet = string(b)
+		}
+	}
+	for _, want := range []string{
+		`<c t="inlineStr"><is><t xml:space="preserve">姓名</t></is></c>`,
+		`<c><v>1</v></c>`,
+		`<t xml:space="preserve">9007199254740993</t>`,
+		`<t xml:space="preserve">&#39;=HYPERLINK(&#34;http://evil&#34;)</t>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet missing %s:\n%s", want, sheet)
+		}
+	}
+	if n := strings.Count(sheet, "<row>"); n != 4 {
+		t.Fatalf("rows = %d", n)
+	}
+}
+
+func TestExportMaxRows(t *testing.T) {
+	fast_base.Logger = zap.NewNop()
+	previous := *ConfigServer.Export
+	ConfigServer.Export.MaxRows = 2
+	defer func() { *ConfigServer.Export = previous }()
+
+	response, err := exportRequest(t, context.Background(), ExportFormatCSV, exportTestUsers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(response.Body.String(), "\n"); n != 3 {
+		t.Fatalf("lines = %d, body: %s", n, response.Body.String())
+	}
+	if response.Result().Trailer.Get(ExportTruncatedTrailer) != "true" {
+		t.Fatal("missing truncated trailer")
+	}
+}
+
+func TestExportStopsWhenClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := exportRequest(t, ctx, ExportFormatCSV, exportTestUsers())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,40 @@
+package fast_web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// loadHealth 注册 Kubernetes 探针接口。检查项由各模块注册到 fast_base，这里只负责汇总输出：
+// 全部通过返回 200，否则返回 503。优雅关闭开始后 /readyz 立即失败，业务端口在 PreStopDelay 后才关闭，便于负载均衡摘除流量。
+// 内置检查项(数据库、令牌、代理、磁盘)都是就绪检查，依赖故障不应导致重启；没有注册存活检查时 /healthz 只表示进程在运行。
+func (c *Server) loadHealth() {
+	fast_base.LoadHealth()
+	if !fast_base.ConfigHealth.Enable {
+		return
+	}
+	c.Gin.GET(healthzPath, c.healthHandler(fast_base.HealthLiveness))
+	c.Gin.GET(readyzPath, c.healthHandler(fast_base.HealthReadiness))
+}
+
+func (c *Server) healthHandler(kind fast_base.HealthKind) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		report := fast_base.CheckHealth(context.Request.Context(), kind)
+		if kind == fast_base.HealthReadiness && c.Draining() {
+			report.Status = fast_base.HealthDown
+			report.Checks = append(report.Checks, fast_base.HealthResult{Name: "server", Status: fast_base.HealthDown, Error: "服务关闭中"})
+		}
+		code := http.StatusOK
+		if !report.IsUp() {
+			code = http.StatusServiceUnavailable
+		}
+		JSONIter(context, code, report)
+	}
+}
@@ -0,0 +1,47 @@
+package fast_web
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+var (
+	httpRequestsTotal   = fast_base.NewCounterVec("http_requests_total", "HTTP 请求总数", "method", "route", "status")
+	httpRequestDuration = fast_base.NewHistogramVec("http_request_duration_seconds", "HTTP 请求耗时(秒)", nil, "method", "route", "status")
+	rateLimitRejected   = fast_base.NewCounterVec("rate_limit_rejected_total", "被限流拒绝的请求数", "limiter")
+)
+
+// loadMetrics 注册 /metrics 接口，输出 Prometheus 文本格式
+func (c *Server) loadMetrics() {
+	if !fast_base.ConfigMetrics.Enable {
+		return
+	}
+	fast_base.RegisterMetricsCollector("http", func(w *fast_base.MetricsWriter) {
+		w.Gauge("http_requests_in_flight", "处理中的 HTTP 请求数", float64(c.InFlight()))
+	})
+	c.Gin.GET(fast_base.ConfigMetrics.Path, func(context *gin.Context) {
+		context.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := fast_base.WriteMetrics(context.Writer); err != nil {
+			fast_base.Logger.Error("输出指标失败：" + err.Error())
+		}
+	})
+}
+
+// ginMetrics 按路由模板(而不是实际路径，避免路径参数导致指标膨胀)统计请求数和耗时
+func ginMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.Inc(c.Request.Method, route, status)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
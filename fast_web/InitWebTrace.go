@@ -0,0 +1,37 @@
+package fast_web
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+// ginTrace 为每个请求创建 server span：继承上游 traceparent，span 名称使用路由模板。
+// 新的 context 写回 c.Request，后续日志、数据库操作和出站请求都能取到当前链路。
+func ginTrace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := fast_base.ExtractTraceparent(c.Request.Context(), c.Request.Header)
+		ctx, span := fast_base.StartSpan(ctx, c.Request.Method+" "+route, fast_base.SpanKindServer)
+		c.Request = c.Request.WithContext(ctx)
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", c.Request.URL.Path)
+		span.SetAttribute("http.client_ip", c.ClientIP())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", int64(status))
+		if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		} else if status >= 500 {
+			span.SetError(fmt.Errorf("HTTP %d", status))
+		}
+		span.End()
+	}
+}
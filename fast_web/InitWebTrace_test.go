@@ -0,0 +1,50 @@
+package fast_web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+func TestGinTraceContinuesIncomingTraceparent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fast_base.Logger = zap.NewNop()
+	previous := fast_base.ConfigTrace
+	fast_base.ConfigTrace.Enable, fast_base.ConfigTrace.SampleRatio = true, 1
+	exporter := fast_base.NewInMemoryExporter()
+	fast_base.SetTraceExporter(exporter)
+	t.Cleanup(func() {
+		fast_base.ConfigTrace = previous
+		fast_base.SetTraceExporter(nil)
+	})
+
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(ginTrace())
+	var inner fast_base.SpanContext
+	engine.GET("/users/:id", func(c *gin.Context) {
+		inner, _ = fast_base.SpanContextFrom(c)
+		c.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), request)
+	fast_base.FlushTrace(context.Background())
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /users/:id" || spans[0].SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected span %s %s", spans[0].Name, spans[0].SpanContext.TraceID)
+	}
+	if inner.SpanID != spans[0].SpanContext.SpanID {
+		t.Fatal("handler context should carry the server span")
+	}
+}
@@ -15,18 +15,13 @@ func (c *Server) LoadLimit() *Server {
 	return c
 }
 
-// LoadLimitByPassword 简单密码模式
+// LoadLimitByPassword 简单密码模式。带 @auth 注解的接口按注解认证，不再按前缀处理
 func (c *Server) LoadLimitByPassword(prefix ...string) *Server {
 	// 对API进行密码验证，适用于简单场景
 	Container.Gin.Use(func(context *gin.Context) {
-		if matchPrefix(context.Request.URL.Path, prefix) {
-			ptt := context.Query("tt")
-			ctt := fast_base.ConfigAll.GetString("server.password")
-			if ctt == ptt {
+		if matchPrefix(context.Request.URL.Path, prefix) && !routeAuthDeclared(context) {
+			if passwordAuth(context) {
 				context.Next()
-			} else {
-				JSONIter(context, http.StatusOK, fast_base.Error(403, "请登录"))
-				context.Abort()
 			}
 		} else {
 			context.Next()
@@ -36,9 +31,9 @@ func (c *Server) LoadLimitByPassword(prefix ...string) *Server {
 	return c
 }
 
-// LoadLimitByToken token模式
+// LoadLimitByToken token模式。带 @auth 注解的接口按注解认证，不再按前缀处理
 func (c *Server) LoadLimitByToken(prefix ...string) *Server {
-	SecTokenController.Init()
+	initTokenController()
 	// 对API进行密码验证，适用于简单场景
 	Container.Gin.Use(func(context *gin.Context) {
 		/*if context.Request.URL.Path == "/api/sec/user/refreshToken" {
@@ -54,22 +49,8 @@ func (c *Server) LoadLimitByToken(prefix ...string) *Server {
 			newToken := SecTokenController.RefreshNewToken(*refreshToken, refreshToken.Data)
 			JSONIter(context,http.StatusOK, fast_base.Success("更新成功", newToken))
 		}*/
-		if matchPrefix(context.Request.URL.Path, prefix) {
-			accessTokenCode := context.GetHeader("AccessToken")
-			AppKey := context.GetHeader("AppKey")
-			if accessTokenCode == "" {
-				// 没有提供token
-				JSONIter(context, http.StatusOK, fast_base.Error(401, "请登录"))
-				context.Abort()
-				return
-			}
-			accessToken := SecTokenController.GetAccessToken(AppKey, accessTokenCode)
-			if accessToken == nil {
-				// 根据code没获取到token
-				JSONIter(context, http.StatusOK, fast_base.Error(402, "请重新登录"))
-				context.Abort()
-			} else {
-				context.Set("AccessToken", *accessToken)
+		if matchPrefix(context.Request.URL.Path, prefix) && !routeAuthDeclared(context) {
+			if tokenAuth(context) {
 				context.Next()
 			}
 		} else {
@@ -80,6 +61,31 @@ func (c *Server) LoadLimitByToken(prefix ...string) *Server {
 	return c
 }
 
+// LoadTenantByHeader 从请求头读取租户ID，令牌中已带租户时以令牌为准。
+// 请求头可被客户端伪造，只应在网关等可信调用方之后使用，且需注册在 LoadLimitByToken 之后
+func (c *Server) LoadTenantByHeader(header string, prefix ...string) *Server {
+	Container.Gin.Use(func(context *gin.Context) {
+		if matchPrefix(context.Request.URL.Path, prefix) {
+			if _, ok := fast_base.TenantFrom(context.Request.Context()); !ok {
+				if tenantId := context.GetHeader(header); tenantId != "" {
+					context.Request = context.Request.WithContext(fast_base.WithTenant(context.Request.Context(), tenantId))
+				}
+			}
+		}
+		context.Next()
+	})
+	return c
+}
+
+// DataScopeMiddleware 把数据权限码写入请求 context，fast_db 的分页查询据此追加数据权限条件。
+// 由接口注解 @dataScope 生成，需注册在 LoadLimitByToken 之后
+func DataScopeMiddleware(code string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(fast_base.WithDataScope(c.Request.Context(), code))
+		c.Next()
+	}
+}
+
 // RateLimitMiddleware
 // num 每秒钟Token Bucket中会产生多少token
 // cap 最多存在多少个可用的token。
@@ -91,6 +97,7 @@ func RateLimitMiddleware(num int, cap int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		fast_base.Logger.Info("[Limit]：" + c.Request.URL.String())
 		if !limit.Allow() {
+			rateLimitRejected.Inc(c.FullPath())
 			c.JSON(http.StatusOK, fast_base.Error(403, "无服务器繁忙，轻稍后再试"))
 			c.Abort()
 			return
@@ -99,6 +106,47 @@ func RateLimitMiddleware(num int, cap int) gin.HandlerFunc {
 	}
 }
 
+// passwordAuth 校验查询参数 tt 与配置 server.password，失败时写出响应并中断
+func passwordAuth(context *gin.Context) bool {
+	ptt := context.Query("tt")
+	ctt := fast_base.ConfigAll.GetString("server.password")
+	if ctt == ptt {
+		return true
+	}
+	JSONIter(context, http.StatusOK, fast_base.Error(403, "请登录"))
+	context.Abort()
+	return false
+}
+
+// tokenAuth 校验请求头 AppKey、AccessToken，成功时把令牌、当前用户和租户写入上下文，失败时写出响应并中断
+func tokenAuth(context *gin.Context) bool {
+	accessTokenCode := context.GetHeader("AccessToken")
+	AppKey := context.GetHeader("AppKey")
+	if accessTokenCode == "" {
+		// 没有提供token
+		JSONIter(context, http.StatusOK, fast_base.Error(401, "请登录"))
+		context.Abort()
+		return false
+	}
+	accessToken := SecTokenController.GetAccessToken(AppKey, accessTokenCode)
+	if accessToken == nil {
+		// 根据code没获取到token
+		JSONIter(context, http.StatusOK, fast_base.Error(402, "请重新登录"))
+		context.Abort()
+		return false
+	}
+	context.Set("AccessToken", *accessToken)
+	// 当前用户写入请求 context，供数据库审计字段等使用
+	user := fast_base.CurrentUser{UserId: accessToken.UserId, AppKey: accessToken.AppKey}
+	ctx := fast_base.WithCurrentUser(context.Request.Context(), user)
+	if accessToken.TenantId != "" {
+		// 租户写入请求 context，供数据库多租户隔离使用
+		ctx = fast_base.WithTenant(ctx, accessToken.TenantId)
+	}
+	context.Request = context.Request.WithContext(ctx)
+	return true
+}
+
 func matchPrefix(url string, prefix []string) bool {
 	for _, s := range prefix {
 		if strings.HasPrefix(url, s) {
@@ -0,0 +1,165 @@
+package fast_web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+)
+
+// 接口级认证、权限和中间件，由 gr 根据接口注解生成：
+//   - @auth none|token|password|apikey   AuthMiddleware
+//   - @perm code1,code2                  PermMiddleware，需要 token 认证
+//   - @middleware name                   Middleware，引用 RegisterMiddleware 注册的中间件
+//   - @group name                        GroupHandlers，在认证、权限之后执行 RegisterGroupMiddleware 注册的中间件
+
+// 接口认证方式
+const (
+	AuthNone     = "none"     // 公开接口，不认证
+	AuthToken    = "token"    // 请求头 AppKey、AccessToken
+	AuthPassword = "password" // 查询参数 tt，与配置 server.password 比较
+	AuthApiKey   = "apikey"   // 请求头 ApiKey，与配置 server.apiKeys 比较
+)
+
+var tokenControllerOnce sync.Once
+
+// initTokenController 令牌管理器只初始化一次，前缀过滤和接口注解都可能用到
+func initTokenController() {
+	tokenControllerOnce.Do(func() { SecTokenController.Init() })
+}
+
+// routeAuth 声明了 @auth 的接口，key 为 "METHOD 路由路径"
+var routeAuth sync.Map
+
+// routeAuthDeclared 接口已通过 AuthMiddleware 声明认证方式时，LoadLimitByToken 等前缀过滤跳过该接口
+func routeAuthDeclared(c *gin.Context) bool {
+	_, ok := routeAuth.Load(c.Request.Method + " " + c.FullPath())
+	return ok
+}
+
+// AuthMiddleware 按 mode 认证，method、path 为注册的路由，用于让前缀过滤跳过该接口。
+// mode 不支持时 panic，在注册路由时暴露注解错误
+func AuthMiddleware(mode, method, path string) gin.HandlerFunc {
+	var auth func(*gin.Context) bool
+	switch mode {
+	case AuthNone:
+		auth = func(*gin.Context) bool { return true }
+	case AuthToken:
+		initTokenController()
+		auth = tokenAuth
+	case AuthPassword:
+		auth = passwordAuth
+	case AuthApiKey:
+		auth = apiKeyAuth
+	default:
+		panic("不支持的认证方式: " + mode)
+	}
+	routeAuth.Store(method+" "+path, mode)
+	return func(c *gin.Context) {
+		if auth(c) {
+			c.Next()
+		}
+	}
+}
+
+// apiKeyAuth 校验请求头 ApiKey，失败时写出响应并中断
+func apiKeyAuth(c *gin.Context) bool {
+	key := c.GetHeader("ApiKey")
+	if key != "" {
+		for _, k := range ConfigServer.ApiKeys {
+			if k != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				return true
+			}
+		}
+	}
+	JSONIter(c, http.StatusOK, fast_base.Error(401, "ApiKey无效"))
+	c.Abort()
+	return false
+}
+
+// PermissionChecker 判断令牌对应的用户是否拥有权限码
+type PermissionChecker func(c *gin.Context, token SecToken, code string) bool
+
+var permissionChecker PermissionChecker
+
+// SetPermissionChecker 设置 @perm 使用的权限判断，通常查询 RBAC 表并缓存
+func SetPermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// PermMiddleware 要求拥有全部权限码，需注册在 token 认证之后
+func PermMiddleware(codes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := AccessToken(c)
+		if !ok {
+			JSONIter(c, http.StatusOK, fast_base.Error(401, "请登录"))
+			c.Abort()
+			return
+		}
+		if permissionChecker == nil {
+			fast_base.Logger.Error("未设置权限判断 SetPermissionChecker，拒绝访问：" + c.FullPath())
+			JSONIter(c, http.StatusOK, fast_base.Error(403, "无权访问"))
+			c.Abort()
+			return
+		}
+		for _, code := range codes {
+			if !permissionChecker(c, token, code) {
+				JSONIter(c, http.StatusOK, fast_base.Error(403, "无权访问"))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+var middlewareLock sync.RWMutex
+var middlewares = map[string]gin.HandlerFunc{}
+var groupMiddlewares = map[string][]gin.HandlerFunc{}
+
+// RegisterMiddleware 注册 @middleware 可引用的中间件，需在 LoadRouters 之前调用
+func RegisterMiddleware(name string, handler gin.HandlerFunc) {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+	middlewares[name] = handler
+}
+
+// Middleware 返回已注册的中间件，未注册时 panic，在注册路由时暴露遗漏
+func Middleware(name string) gin.HandlerFunc {
+	middlewareLock.RLock()
+	defer middlewareLock.RUnlock()
+	handler, ok := middlewares[name]
+	if !ok {
+		panic("中间件未注册: " + name)
+	}
+	return handler
+}
+
+// RegisterGroupMiddleware 为 @group 分组追加中间件，需在 LoadRouters 之前调用
+func RegisterGroupMiddleware(group string, handlers ...gin.HandlerFunc) {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+	groupMiddlewares[group] = append(groupMiddlewares[group], handlers...)
+}
+
+// GroupMiddlewares 返回分组的中间件，未注册时为空
+func GroupMiddlewares(group string) []gin.HandlerFunc {
+	middlewareLock.RLock()
+	defer middlewareLock.RUnlock()
+	return append([]gin.HandlerFunc(nil), groupMiddlewares[group]...)
+}
+
+// GroupHandlers @group 接口的处理链：认证、权限(security)、分组中间件，然后是接口的其他中间件和处理函数。
+// 分组中间件在认证之后执行，可以读取令牌，未通过认证的请求不会进入分组中间件
+func GroupHandlers(group string, security []gin.HandlerFunc, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	chain := append([]gin.HandlerFunc(nil), security...)
+	chain = append(chain, GroupMiddlewares(group)...)
+	return append(chain, handlers...)
+}
+
+// HandlerChain 中间件列表，生成的路由代码中 gin 为引擎参数名，不能直接写 []gin.HandlerFunc
+func HandlerChain(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	return handlers
+}
@@ -0,0 +1,141 @@
+package fast_web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/tdwu/fast_go/fast_base"
+	"go.uber.org/zap"
+)
+
+func newRouteTestServer(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	fast_base.Logger = zap.NewNop()
+	previous, previousConfig := Container, fast_base.ConfigAll
+	Container = newServer()
+	Container.Gin = gin.New()
+	fast_base.ConfigAll = viper.New()
+	fast_base.ConfigAll.Set("server.password", "pw")
+	t.Cleanup(func() { Container, fast_base.ConfigAll = previous, previousConfig })
+	return Container.Gin
+}
+
+func serveRoute(engine *gin.Engine, method, path string, header map[string]string) (int, string) {
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		request.Header.Set(k, v)
+	}
+	engine.ServeHTTP(response, request)
+	return response.Code, response.Body.String()
+}
+
+func okHandler(c *gin.Context) {
+	c.String(http.StatusOK, "ok")
+}
+
+func TestRouteAuthOverridesPrefixFilter(t *testing.T) {
+	engine := newRouteTestServer(t)
+	Container.LoadLimitByPassword("/api/")
+	engine.GET("/api/private", okHandler)
+	engine.GET("/api/public", AuthMiddleware(AuthNone, http.MethodGet, "/api/public"), okHandler)
+	previousKeys := ConfigServer.ApiKeys
+	ConfigServer.ApiKeys = []string{"k1"}
+	t.Cleanup(func() { ConfigServer.ApiKeys = previousKeys })
+	engine.GET("/api/key/:id", AuthMiddleware(AuthApiKey, http.MethodGet, "/api/key/:id"), okHandler)
+
+	if _, body := serveRoute(engine, http.MethodGet, "/api/private", nil); !strings.Contains(body, `"code":403`) {
+		t.Fatalf("prefix filter should reject: %s", body)
+	}
+	if _, body := serveRoute(engine, http.MethodGet, "/api/public", nil); body != "ok" {
+		t.Fatalf("@auth none should skip prefix filter: %s", body)
+	}
+	// 声明了 apikey 的接口不再要求密码，只校验 ApiKey
+	if _, body := serveRoute(engine, http.MethodGet, "/api/key/1?tt=pw", nil); !strings.Contains(body, `"code":401`) {
+		t.Fatalf("apikey required: %s", body)
+	}
+	if _, body := serveRoute(engine, http.MethodGet, "/api/key/1", map[string]string{"ApiKey": "k1"}); body != "ok" {
+		t.Fatalf("apikey accepted: %s", body)
+	}
+}
+
+func TestPermMiddleware(t *testing.T) {
+	engine := newRouteTestServer(t)
+	t.Cleanup(func() { SetPermissionChecker(nil) })
+	login := func(c *gin.Context) {
+		if id := c.GetHeader("User"); id != "" {
+			c.Set("AccessToken", SecToken{UserId: int64(len(id))})
+		}
+	}
+	engine.GET("/users", login, PermMiddleware("sys:user:list", "sys:user:query"), okHandler)
+
+	if _, body := serveRoute(engine, http.MethodGet, "/users", nil); !strings.Contains(body, `"code":401`) {
+		t.Fatalf("anonymous: %s", body)
+	}
+	if _, body := serveRoute(engine, http.MethodGet, "/users", map[string]string{"User": "a"}); !strings.Contains(body, `"code":403`) {
+		t.Fatalf("no checker should deny: %s", body)
+	}
+	SetPermissionChecker(func(c *gin.Context, token SecToken, code string) bool {
+		return token.UserId == 2 || code == "sys:user:list"
+	})
+	if _, body := serveRoute(engine, http.MethodGet, "/users", map[string]string{"User": "a"}); !strings.Contains(body, `"code":403`) {
+		t.Fatalf("all codes required: %s", body)
+	}
+	if _, body := serveRoute(engine, http.MethodGet, "/users", map[string]string{"User": "ab"}); body != "ok" {
+		t.Fatalf("granted: %s", body)
+	}
+}
+
+func TestMiddlewareRegistry(t *testing.T) {
+	engine := newRouteTestServer(t)
+	var calls []string
+	RegisterMiddleware("audit", func(c *gin.Context) { calls = append(calls, "audit") })
+	RegisterGroupMiddleware("system", func(c *gin.Context) { calls = append(calls, "group") })
+	group := engine.Group("", GroupMiddlewares("system")...)
+	group.GET("/sys", Middleware("audit"), okHandler)
+	engine.GET("/other", okHandler)
+
+	serveRoute(engine, http.MethodGet, "/sys", nil)
+	serveRoute(engine, http.MethodGet, "/other", nil)
+	if strings.Join(calls, ",") != "group,audit" {
+		t.Fatalf("calls: %v", calls)
+	}
+	if len(GroupMiddlewares("unknown")) != 0 {
+		t.Fatal("unknown group should have no middlewares")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("unregistered middleware should panic")
+		}
+	}()
+	Middleware("missing")
+}
+
+func TestGroupHandlersRunAfterAuth(t *testing.T) {
+	engine := newRouteTestServer(t)
+	previousKeys := ConfigServer.ApiKeys
+	ConfigServer.ApiKeys = []string{"k1"}
+	t.Cleanup(func() { ConfigServer.ApiKeys = previousKeys })
+	var calls []string
+	RegisterMiddleware("trace", func(c *gin.Context) { calls = append(calls, "route") })
+	RegisterGroupMiddleware("open-api", func(c *gin.Context) { calls = append(calls, "group") })
+	engine.GET("/open/:id", GroupHandlers("open-api", HandlerChain(AuthMiddleware(AuthApiKey, http.MethodGet, "/open/:id")), Middleware("trace"), okHandler)...)
+	engine.GET("/open", GroupHandlers("open-api", nil, okHandler)...)
+
+	// 未通过认证的请求不进入分组中间件
+	if _, body := serveRoute(engine, http.MethodGet, "/open/1", nil); !strings.Contains(body, `"code":401`) || len(calls) != 0 {
+		t.Fatalf("group middleware ran before auth: %s %v", body, calls)
+	}
+	if _, body := serveRoute(engine, http.MethodGet, "/open/1", map[string]string{"ApiKey": "k1"}); body != "ok" || strings.Join(calls, ",") != "group,route" {
+		t.Fatalf("order: %s %v", body, calls)
+	}
+	calls = nil
+	if _, body := serveRoute(engine, http.MethodGet, "/open", nil); body != "ok" || strings.Join(calls, ",") != "group" {
+		t.Fatalf("group without auth: %s %v", body, calls)
+	}
+}
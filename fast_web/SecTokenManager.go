@@ -1,12 +1,16 @@
 package fast_web
 
 import (
+	"context"
+	"errors"
 	"github.com/allegro/bigcache"
 	"github.com/tdwu/fast_go/fast_base"
 	"github.com/tdwu/fast_go/fast_utils"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +19,7 @@ type SecToken struct {
 	RefreshToken string `json:"refreshToken" form:"刷新令牌"`
 	AppKey       string `json:"appKey"`
 	UserId       int64  `json:"userId"`
+	TenantId     string `json:"tenantId,omitempty"`
 	Data         string `json:"data"`
 	CreateTime   string `json:"createTime"`
 	ExpireTime   string `json:"expireTime"`
@@ -31,6 +36,51 @@ var SecTokenController = SecTokenManager{}
 type SecTokenManager struct {
 	cacheInstance *bigcache.BigCache
 	duration      time.Duration // 持续时间，秒
+	saveErr       atomic.Value  // 最近一次刷盘的错误信息，空字符串表示成功
+
+	sessionLock sync.Mutex
+	sessions    map[string]string // 登录用户的 access_user_ 键 -> 当前 AccessToken，用于统计会话数
+}
+
+// sessionCleanWindow 清理过期令牌的间隔，过期的会话在清理时从会话数中扣除
+const sessionCleanWindow = time.Minute
+
+// newCache 创建令牌缓存，记录过期或被淘汰时通过 onRemove 更新会话数
+func (t *SecTokenManager) newCache(life, clean time.Duration) (*bigcache.BigCache, error) {
+	config := bigcache.DefaultConfig(life)
+	config.CleanWindow = clean
+	config.OnRemoveWithReason = t.onRemove
+	return bigcache.NewBigCache(config)
+}
+
+// setUserAccess 记录用户当前的 AccessToken，同时计入会话
+func (t *SecTokenManager) setUserAccess(key string, accessToken string) {
+	t.sessionLock.Lock()
+	if t.sessions == nil {
+		t.sessions = map[string]string{}
+	}
+	t.sessions[key] = accessToken
+	t.sessionLock.Unlock()
+	t.cacheInstance.Set(key, []byte(accessToken))
+}
+
+// onRemove 在缓存分片的锁内调用，不能再访问缓存。被新登录覆盖的旧记录过期时值不同，不影响会话数
+func (t *SecTokenManager) onRemove(key string, entry []byte, _ bigcache.RemoveReason) {
+	if !strings.Contains(key, "_access_user_") {
+		return
+	}
+	t.sessionLock.Lock()
+	if t.sessions[key] == string(entry) {
+		delete(t.sessions, key)
+	}
+	t.sessionLock.Unlock()
+}
+
+// sessionCount 当前登录会话数
+func (t *SecTokenManager) sessionCount() int {
+	t.sessionLock.Lock()
+	defer t.sessionLock.Unlock()
+	return len(t.sessions)
 }
 
 func (t *SecTokenManager) saveCacheToFile() error {
@@ -81,6 +131,10 @@ func (t *SecTokenManager) loadFromFile() {
 		return
 	}
 	for key, value := range items {
+		if strings.Contains(key, "_access_user_") {
+			t.setUserAccess(key, string(value))
+			continue
+		}
 		t.cacheInstance.Set(key, value)
 	}
 
@@ -96,7 +150,7 @@ func (t *SecTokenManager) Init() *SecTokenManager {
 	}
 	fast_base.Logger.Info("token manager 过期时间：" + strconv.Itoa(d) + " 分钟")
 	t.duration = time.Duration(d) * time.Minute
-	cache, _ := bigcache.NewBigCache(bigcache.DefaultConfig(t.duration))
+	cache, _ := t.newCache(t.duration, sessionCleanWindow)
 	t.cacheInstance = cache
 
 	// 先从文件恢复
@@ -105,21 +159,58 @@ func (t *SecTokenManager) Init() *SecTokenManager {
 		for {
 			// 定时刷盘
 			time.Sleep(time.Second * 10)
-			t.saveCacheToFile()
+			if err := t.saveCacheToFile(); err != nil {
+				fast_base.Logger.Error("token_cache持久化失败:" + err.Error())
+				t.saveErr.Store(err.Error())
+			} else {
+				t.saveErr.Store("")
+			}
 		}
 	}()
+
+	fast_base.RegisterHealthCheck("token", fast_base.HealthReadiness, t.checkHealth)
+	fast_base.RegisterMetricsCollector("token", t.collectMetrics)
 	return t
 }
 
+// collectMetrics 输出会话数与缓存大小。会话数在登录和令牌过期时维护，采集时不遍历缓存
+func (t *SecTokenManager) collectMetrics(w *fast_base.MetricsWriter) {
+	if t.cacheInstance == nil {
+		return
+	}
+	w.Gauge("token_sessions_active", "当前登录会话数", float64(t.sessionCount()))
+	w.Gauge("token_cache_entries", "令牌缓存条目数", float64(t.cacheInstance.Len()))
+	w.Gauge("token_cache_capacity_bytes", "令牌缓存占用字节数", float64(t.cacheInstance.Capacity()))
+	stats := t.cacheInstance.Stats()
+	w.Counter("token_cache_hits_total", "令牌缓存命中次数", float64(stats.Hits))
+	w.Counter("token_cache_misses_total", "令牌缓存未命中次数", float64(stats.Misses))
+}
+
+// checkHealth 令牌缓存可用，且最近一次刷盘成功
+func (t *SecTokenManager) checkHealth(ctx context.Context) error {
+	if t.cacheInstance == nil {
+		return errors.New("令牌缓存未初始化")
+	}
+	if msg, _ := t.saveErr.Load().(string); msg != "" {
+		return errors.New("令牌持久化失败: " + msg)
+	}
+	return nil
+}
+
 func (t *SecTokenManager) CreateNewToken(appKey string, userId int64, data string) *SecToken {
+	return t.CreateNewTokenWithTenant(appKey, userId, "", data)
+}
+
+// CreateNewTokenWithTenant 创建带租户的令牌，LoadLimitByToken 校验后把租户放入请求 context
+func (t *SecTokenManager) CreateNewTokenWithTenant(appKey string, userId int64, tenantId string, data string) *SecToken {
 	// 1 立马挤掉用户之前的登录的（如果之前登录过），0代表立马
 	t.clearUserToken(appKey, userId, 0)
 
 	// 2 创建新token
-	token := t.createToken(appKey, userId, data)
+	token := t.createToken(appKey, userId, tenantId, data)
 
 	// 3 记录用户当前的token
-	t.cacheInstance.Set(appKey+"_"+"access_user_"+strconv.FormatInt(userId, 10), []byte(token.AccessToken))
+	t.setUserAccess(appKey+"_"+"access_user_"+strconv.FormatInt(userId, 10), token.AccessToken)
 	t.cacheInstance.Set(appKey+"_"+"refresh_user_"+strconv.FormatInt(userId, 10), []byte(token.RefreshToken))
 	return token
 }
@@ -129,15 +220,15 @@ func (t *SecTokenManager) RefreshNewToken(oldToken SecToken, data string) *SecTo
 	t.clearUserToken(oldToken.AppKey, oldToken.UserId, 1)
 
 	// 2 创建新token
-	token := t.createToken(oldToken.AppKey, oldToken.UserId, data)
+	token := t.createToken(oldToken.AppKey, oldToken.UserId, oldToken.TenantId, data)
 
 	// 3 记录用户当前的token
-	t.cacheInstance.Set(oldToken.AppKey+"_"+"access_user_"+strconv.FormatInt(oldToken.UserId, 10), []byte(token.AccessToken))
+	t.setUserAccess(oldToken.AppKey+"_"+"access_user_"+strconv.FormatInt(oldToken.UserId, 10), token.AccessToken)
 	t.cacheInstance.Set(oldToken.AppKey+"_"+"refresh_user_"+strconv.FormatInt(oldToken.UserId, 10), []byte(token.RefreshToken))
 	return token
 }
 
-func (t *SecTokenManager) createToken(appKey string, userId int64, data string) *SecToken {
+func (t *SecTokenManager) createToken(appKey string, userId int64, tenantId string, data string) *SecToken {
 
 	n1 := time.Now()
 	n2 := time.Now().Add(t.duration)
@@ -146,6 +237,7 @@ func (t *SecTokenManager) createToken(appKey string, userId int64, data string)
 		RefreshToken: fast_utils.GetUUIDStr(),
 		AppKey:       appKey,
 		UserId:       userId,
+		TenantId:     tenantId,
 		Data:         data,
 		CreateTime:   fast_utils.GetTimeStr(&n1),
 		ExpireTime:   fast_utils.GetTimeStr(&n2),
@@ -0,0 +1,39 @@
+package fast_web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecTokenManagerSessionCount(t *testing.T) {
+	m := &SecTokenManager{duration: time.Minute}
+	cache, err := m.newCache(time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.cacheInstance = cache
+
+	first := m.CreateNewToken("app", 1, "")
+	m.CreateNewToken("app", 2, "")
+	// 重新登录和刷新只替换用户当前的令牌，不增加会话
+	m.CreateNewToken("app", 1, "")
+	m.RefreshNewToken(*first, "")
+	if n := m.sessionCount(); n != 2 {
+		t.Fatalf("sessions: %d, want 2", n)
+	}
+
+	// 被替换的旧记录被移除时不影响会话数
+	m.onRemove("app_access_user_1", []byte(first.AccessToken), 0)
+	if n := m.sessionCount(); n != 2 {
+		t.Fatalf("superseded entry removed, sessions: %d, want 2", n)
+	}
+
+	// 令牌过期后由缓存清理，会话数随之减少
+	deadline := time.Now().Add(5 * time.Second)
+	for m.sessionCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if n := m.sessionCount(); n != 0 {
+		t.Fatalf("expired sessions: %d, want 0", n)
+	}
+}
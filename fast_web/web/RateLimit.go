@@ -12,8 +12,12 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/tdwu/fast_go/fast_base"
 )
 
+var bucketRejected = fast_base.NewCounterVec("rate_limit_rejected_total", "被限流拒绝的请求数", "limiter")
+
 // The algorithm that this implementation uses does computational work
 // only when tokens are removed from the bucket, and that work completes
 // in short, bounded-constant time (Bucket.Wait benchmarks at 175ns on
@@ -44,6 +48,9 @@ import (
 type Bucket struct {
 	clock Clock
 
+	// name labels the rate_limit_rejected_total metric.
+	name string
+
 	// startTime holds the moment when the bucket was
 	// first created and ticks began.
 	startTime time.Time
@@ -164,6 +171,23 @@ func NewBucketWithQuantumAndClock(fillInterval time.Duration, capacity, quantum
 	}
 }
 
+// SetName sets the limiter label used when counting rejected takes in
+// the rate_limit_rejected_total metric. It returns the bucket so it can
+// be chained after a constructor.
+func (tb *Bucket) SetName(name string) *Bucket {
+	tb.name = name
+	return tb
+}
+
+// rejected records a take that could not be satisfied.
+func (tb *Bucket) rejected() {
+	name := tb.name
+	if name == "" {
+		name = "bucket"
+	}
+	bucketRejected.Inc(name)
+}
+
 // Wait takes count tokens from the bucket, waiting until they are
 // available.
 func (tb *Bucket) Wait(count int64) {
@@ -232,6 +256,7 @@ func (tb *Bucket) takeAvailable(now time.Time, count int64) int64 {
 	}
 	tb.adjustAvailableTokens(tb.currentTick(now))
 	if tb.availableTokens <= 0 {
+		tb.rejected()
 		return 0
 	}
 	if count > tb.availableTokens {
@@ -294,6 +319,7 @@ func (tb *Bucket) take(now time.Time, count int64, maxWait time.Duration) (time.
 	endTime := tb.startTime.Add(time.Duration(endTick) * tb.fillInterval)
 	waitTime := endTime.Sub(now)
 	if waitTime > maxWait {
+		tb.rejected()
 		return 0, false
 	}
 	tb.availableTokens = avail
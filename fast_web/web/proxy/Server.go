@@ -18,6 +18,9 @@ import (
 
 var httpProxyServer *http.Server
 
+// 转发请求时创建 client span，并向目标服务注入 traceparent
+var proxyTransport = fast_base.TraceTransport(http.DefaultTransport)
+
 func StopProxy() {
 	if httpProxyServer != nil {
 		fast_base.Logger.Info("关闭代理服务....")
@@ -61,6 +64,15 @@ func StartProxy() {
 		}),
 	}
 
+	// 就绪检查：确认代理端口仍在监听
+	fast_base.RegisterHealthCheck("proxy", fast_base.HealthReadiness, func(ctx context.Context) error {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", "127.0.0.1:"+port)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+
 	go func() {
 		fast_base.Logger.Info("启动代理服务(正向), 使用端口:" + port)
 		err := httpProxyServer.ListenAndServe()
@@ -73,8 +85,14 @@ func StartProxy() {
 }
 
 func handleHttpsRequest(w http.ResponseWriter, r *http.Request) {
+	// 隧道内容是加密的，无法注入请求头，只记录建立隧道的过程
+	_, span := fast_base.StartSpan(fast_base.ExtractTraceparent(r.Context(), r.Header), "proxy CONNECT", fast_base.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("net.peer.name", r.Host)
+
 	destConn, err := net.DialTimeout("tcp", r.Host, 60*time.Second)
 	if err != nil {
+		span.SetError(err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
@@ -96,11 +114,18 @@ func handleHttpsRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleHttpRequest(w http.ResponseWriter, r *http.Request) {
-	resp, err := http.DefaultTransport.RoundTrip(r)
+	ctx, span := fast_base.StartSpan(fast_base.ExtractTraceparent(r.Context(), r.Header), "proxy "+r.Method, fast_base.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.URL.String())
+
+	resp, err := proxyTransport.RoundTrip(r.WithContext(ctx))
 	if err != nil {
+		span.SetError(err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
+	span.SetAttribute("http.status_code", int64(resp.StatusCode))
 	defer resp.Body.Close()
 
 	copyHeader(w.Header(), resp.Header)
@@ -0,0 +1,805 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/imports"
+)
+
+// 由模型生成增删改查脚手架：
+//
+//	gr crud -d ./model -m SysUser -o ./app/sysuser
+//
+// 生成请求、响应结构体({snake}_dto.go)、仓储({snake}_repository.go)、处理函数({snake}_handler.go)
+// 和基于 httptest、SQLite 的测试({snake}_handler_test.go)。处理函数为 fast_web.JSONHandler 形态，
+// 带 @Router 与 Swag 注解。生成后即为手写代码，已存在的文件不覆盖，-force 时覆盖。
+
+// MakeCrudCmd crud 子命令
+func MakeCrudCmd(args []string) {
+	fs := flag.NewFlagSet("crud", flag.ExitOnError)
+	modelDir := fs.String("d", "./model", "模型所在目录")
+	modelName := fs.String("m", "", "模型结构体名称")
+	output := fs.String("o", "", "输出目录，默认为模型目录")
+	pkg := fs.String("p", "", "包名，默认为输出目录名")
+	route := fs.String("r", "", "路由前缀，默认为 /{模型名首字母小写}")
+	force := fs.Bool("force", false, "覆盖已存在的文件")
+	fs.Parse(args)
+
+	if *modelName == "" {
+		fmt.Println("缺少 -m 模型名称")
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = *modelDir
+	}
+	if err := MakeCrud(*modelDir, *modelName, *output, *pkg, *route, *force); err != nil {
+		fmt.Printf("生成脚手架失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// MakeCrud 解析模型并生成脚手架
+func MakeCrud(modelDir, modelName, outputDir, pkg, route string, force bool) error {
+	e, err := parseCrudEntity(modelDir, modelName)
+	if err != nil {
+		return err
+	}
+	modelAbs, _ := filepath.Abs(modelDir)
+	outputAbs, _ := filepath.Abs(outputDir)
+	if pkg == "" {
+		if modelAbs == outputAbs {
+			pkg = e.modelPkgName
+		} else {
+			pkg = strings.ReplaceAll(filepath.Base(outputAbs), "-", "_")
+		}
+	}
+	e.Package = pkg
+	if modelAbs != outputAbs {
+		importPath, err := getPkgName(modelDir)
+		if err != nil {
+			return err
+		}
+		e.ModelImport = importPath
+		e.Model = e.modelPkgName + "." + e.Name
+	}
+	e.qualify()
+	e.Route = route
+	if e.Route == "" {
+		e.Route = "/" + strings.ToLower(e.Name[:1]) + e.Name[1:]
+	}
+	e.Route = "/" + strings.Trim(e.Route, "/")
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+	base := snakeCase(e.Name)
+	files := []struct {
+		name string
+		tpl  *template.Template
+	}{
+		{base + "_dto.go", crudDtoTemplate},
+		{base + "_repository.go", crudRepositoryTemplate},
+		{base + "_handler.go", crudHandlerTemplate},
+		{base + "_handler_test.go", crudTestTemplate},
+	}
+	for _, f := range files {
+		path := filepath.Join(outputDir, f.name)
+		if _, err := os.Stat(path); err == nil && !force {
+			fmt.Println("已存在，跳过：" + path)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := f.tpl.Execute(&buf, e); err != nil {
+			return err
+		}
+		// 模板中的导入不分组，由 imports 按标准库、第三方包分组排序
+		source, err := imports.Process(path, buf.Bytes(), &imports.Options{Comments: true, TabIndent: true, TabWidth: 8, FormatOnly: true})
+		if err != nil {
+			return fmt.Errorf("%s: 格式化失败: %w\n%s", f.name, err, buf.String())
+		}
+		if err := writeGeneratedFile(path, source); err != nil {
+			return err
+		}
+		fmt.Println("生成：" + path)
+	}
+	return nil
+}
+
+// crudEntity 模板数据
+type crudEntity struct {
+	Package     string
+	Name        string // 模型名，如 SysUser
+	Label       string // 模型注释，如 系统用户
+	Model       string // 模板中引用模型的写法，如 model.SysUser
+	ModelImport string // 模型包的导入路径，与输出同包时为空
+	Route       string
+	IdType      string // 主键在请求、响应中的类型
+	IdField     string // 模型中的主键字段
+	IdConvert   bool   // 主键需要转换为 IdType，如 fast_base.StringInt64
+	Timestamps  bool   // 包含 CreatedAt、UpdatedAt
+	Fields      []*crudField
+	Imports     []string // dto 文件需要的包
+
+	modelPkgName string
+	modelImports map[string]string // 模型文件中的 包名 -> 导入路径
+}
+
+type crudField struct {
+	Name     string
+	Type     string // 模型中的类型
+	Comment  string
+	Json     string
+	Validate string
+	Dict     string
+	Column   string
+	Pointer  bool // 类型为指针或切片，更新请求中直接使用
+	ReadOnly bool // 只出现在响应中，如 Version
+
+	expr ast.Expr
+}
+
+// CreateTag 创建请求的标签
+func (f *crudField) CreateTag() string {
+	tag := fmt.Sprintf("json:%q", f.Json)
+	if f.Validate != "" {
+		tag += fmt.Sprintf(" validate:%q", f.Validate)
+	}
+	return tag
+}
+
+// UpdateType 更新请求的字段都可以不传，非指针类型改为指针
+func (f *crudField) UpdateType() string {
+	if f.Pointer {
+		return f.Type
+	}
+	return "*" + f.Type
+}
+
+// UpdateTag 更新请求的标签，去掉 required
+func (f *crudField) UpdateTag() string {
+	tag := fmt.Sprintf("json:%q", f.Json+",omitempty")
+	var rules []string
+	for _, r := range strings.Split(f.Validate, ",") {
+		if r != "" && r != "required" && r != "omitempty" {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) > 0 {
+		tag += fmt.Sprintf(" validate:%q", "omitempty,"+strings.Join(rules, ","))
+	}
+	return tag
+}
+
+// ResponseTag 响应的标签，保留 jsonDict
+func (f *crudField) ResponseTag() string {
+	tag := fmt.Sprintf("json:%q", f.Json)
+	if f.Dict != "" {
+		tag += fmt.Sprintf(" jsonDict:%q", f.Dict)
+	}
+	return tag
+}
+
+// Sample 测试中创建请求使用的值，指针类型不填
+func (f *crudField) Sample() string {
+	if f.Pointer {
+		return ""
+	}
+	switch f.Type {
+	case "string":
+		return strconv.Quote(sampleString(f.Validate))
+	case "bool":
+		return "true"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return "1"
+	case "time.Time":
+		return "time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)"
+	}
+	return ""
+}
+
+// UpdateSample 测试中修改使用的值，与 Sample 不同
+func (f *crudField) UpdateSample() string {
+	return strconv.Quote(sampleStringFrom("done", f.Validate))
+}
+
+func sampleString(validate string) string {
+	return sampleStringFrom("test", validate)
+}
+
+// sampleStringFrom 把 s 调整为满足 max、min、len 限制的字符串
+func sampleStringFrom(s, validate string) string {
+	for _, r := range strings.Split(validate, ",") {
+		name, value, ok := strings.Cut(r, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil {
+			continue
+		}
+		switch name {
+		case "max":
+			if n < len(s) {
+				s = s[:n]
+			}
+		case "min", "len":
+			for len(s) < n {
+				s += "t"
+			}
+			if name == "len" {
+				s = s[:n]
+			}
+		}
+	}
+	return s
+}
+
+// UpdateField 测试中用于验证更新的字符串字段
+func (e *crudEntity) UpdateField() *crudField {
+	for _, f := range e.Fields {
+		if !f.ReadOnly && !f.Pointer && f.Type == "string" {
+			return f
+		}
+	}
+	return nil
+}
+
+// UsesTime 测试中是否用到 time 包
+func (e *crudEntity) UsesTime() bool {
+	for _, f := range e.Fields {
+		if !f.ReadOnly && f.Sample() != "" && f.Type == "time.Time" {
+			return true
+		}
+	}
+	return false
+}
+
+// Writable 创建、更新请求中的字段
+func (e *crudEntity) Writable() []*crudField {
+	var list []*crudField
+	for _, f := range e.Fields {
+		if !f.ReadOnly {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+var builtinTypes = map[string]bool{
+	"bool": true, "string": true, "byte": true, "rune": true, "error": true, "any": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// parseCrudEntity 在模型目录中查找结构体，读取字段、标签和注释
+func parseCrudEntity(dir, name string) (*crudEntity, error) {
+	fset := token.NewFileSet()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || ts.Name.Name != name {
+					continue
+				}
+				doc := ts.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				return newCrudEntity(fset, file, name, doc, st)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s 中没有结构体 %s", dir, name)
+}
+
+func newCrudEntity(fset *token.FileSet, file *ast.File, name string, doc *ast.CommentGroup, st *ast.StructType) (*crudEntity, error) {
+	e := &crudEntity{Name: name, Model: name, Label: name, modelPkgName: file.Name.Name, modelImports: map[string]string{}}
+	if doc != nil {
+		if label := strings.TrimSpace(strings.TrimPrefix(strings.SplitN(doc.Text(), "\n", 2)[0], name)); label != "" {
+			e.Label = label
+		}
+	}
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		alias := p[strings.LastIndex(p, "/")+1:]
+		if imp.Name != nil {
+			alias = imp.Name.Name
+		}
+		e.modelImports[alias] = p
+	}
+
+	for _, field := range st.Fields.List {
+		typ := exprString(fset, field.Type)
+		if len(field.Names) == 0 {
+			switch strings.TrimPrefix(typ, "*") {
+			case "fast_db.Model", "fast_db.AuditModel":
+				e.IdType, e.IdField, e.IdConvert, e.Timestamps = "int64", "ID", true, true
+			default:
+				return nil, fmt.Errorf("不支持嵌入的结构体 %s", typ)
+			}
+			continue
+		}
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			s, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(s)
+		}
+		gormTag := tag.Get("gorm")
+		jsonName := strings.Split(tag.Get("json"), ",")[0]
+		if gormTag == "-" || jsonName == "-" || typ == "gorm.DeletedAt" {
+			continue
+		}
+		for _, n := range field.Names {
+			if !n.IsExported() {
+				continue
+			}
+			f := &crudField{
+				Name:     n.Name,
+				Type:     typ,
+				Json:     jsonName,
+				Validate: tag.Get("validate"),
+				Dict:     tag.Get("jsonDict"),
+				Column:   gormSetting(gormTag, "column"),
+				Pointer:  strings.HasPrefix(typ, "*") || strings.HasPrefix(typ, "[]"),
+				expr:     field.Type,
+			}
+			if f.Json == "" {
+				f.Json = strings.ToLower(n.Name[:1]) + n.Name[1:]
+			}
+			if field.Doc != nil {
+				f.Comment = strings.Join(strings.Fields(field.Doc.Text()), " ")
+			}
+			primary := strings.Contains(strings.ToLower(gormTag), "primarykey") || strings.Contains(strings.ToLower(gormTag), "primary_key")
+			if primary || e.IdField == "" && (n.Name == "ID" || n.Name == "Id") {
+				e.IdField, e.IdType = n.Name, typ
+				if isIntegerType(typ) {
+					e.IdType, e.IdConvert = "int64", typ != "int64"
+				}
+				continue
+			}
+			switch n.Name {
+			case "CreatedAt", "UpdatedAt", "CreatedBy", "UpdatedBy":
+				continue
+			case "Version":
+				f.ReadOnly = true
+			}
+			e.Fields = append(e.Fields, f)
+		}
+	}
+	if e.IdField == "" {
+		return nil, fmt.Errorf("%s 没有主键", name)
+	}
+	return e, nil
+}
+
+// qualify 输出到其他包时，模型包中定义的类型加上包名，并收集 dto 需要的导入
+func (e *crudEntity) qualify() {
+	imports := map[string]bool{}
+	if e.Timestamps {
+		imports["time"] = true
+	}
+	if e.ModelImport != "" {
+		imports[e.ModelImport] = true
+	}
+	for _, f := range e.Fields {
+		ast.Inspect(f.expr, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.SelectorExpr:
+				if id, ok := x.X.(*ast.Ident); ok {
+					if p, ok := e.modelImports[id.Name]; ok {
+						imports[p] = true
+					}
+				}
+				return false
+			case *ast.Ident:
+				if e.ModelImport != "" && !builtinTypes[x.Name] {
+					imports[e.ModelImport] = true
+					x.Name = e.modelPkgName + "." + x.Name
+				}
+			}
+			return true
+		})
+		f.Type = exprString(token.NewFileSet(), f.expr)
+	}
+	for p := range imports {
+		e.Imports = append(e.Imports, p)
+	}
+	sort.Strings(e.Imports)
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var b bytes.Buffer
+	printer.Fprint(&b, fset, expr)
+	return b.String()
+}
+
+func isIntegerType(typ string) bool {
+	switch typ {
+	case "int", "int32", "int64", "uint", "uint32", "uint64", "fast_base.StringInt64":
+		return true
+	}
+	return false
+}
+
+// gormSetting 读取 gorm 标签中的设置，如 column:user_name
+func gormSetting(tag, key string) string {
+	for _, part := range strings.Split(tag, ";") {
+		k, v, _ := strings.Cut(part, ":")
+		if strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// snakeCase SysUser -> sys_user
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// tag 生成结构体标签，模板写在反引号字符串中，不能直接写反引号
+func tag(s string) string {
+	return "`" + s + "`"
+}
+
+var crudFuncs = template.FuncMap{"lowerFirst": lowerFirst, "tag": tag}
+
+func crudTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(crudFuncs).Parse(text))
+}
+
+var crudDtoTemplate = crudTemplate("dto", `package {{.Package}}
+{{if .Imports}}
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{end}}
+// Create{{.Name}}Request 创建{{.Label}}
+type Create{{.Name}}Request struct {
+{{- range .Writable}}
+{{- if .Comment}}
+	// {{.Comment}}
+{{- end}}
+	{{.Name}} {{.Type}} {{tag .CreateTag}}
+{{- end}}
+}
+
+// Update{{.Name}}Request 修改{{.Label}}，只更新传入的字段
+type Update{{.Name}}Request struct {
+	ID {{.IdType}} {{tag "json:\"-\" uri:\"id\" validate:\"required\""}}
+{{- range .Writable}}
+{{- if .Comment}}
+	// {{.Comment}}
+{{- end}}
+	{{.Name}} {{.UpdateType}} {{tag .UpdateTag}}
+{{- end}}
+}
+
+// {{.Name}}IdRequest 按主键查询、删除
+type {{.Name}}IdRequest struct {
+	ID {{.IdType}} {{tag "uri:\"id\" validate:\"required\""}}
+}
+
+// {{.Name}}Response {{.Label}}
+type {{.Name}}Response struct {
+	ID {{.IdType}} {{tag "json:\"id\""}}
+{{- range .Fields}}
+{{- if .Comment}}
+	// {{.Comment}}
+{{- end}}
+	{{.Name}} {{.Type}} {{tag .ResponseTag}}
+{{- end}}
+{{- if .Timestamps}}
+	CreatedAt time.Time {{tag "json:\"createdAt\""}}
+	UpdatedAt time.Time {{tag "json:\"updatedAt\""}}
+{{- end}}
+}
+
+func new{{.Name}}Response(m *{{.Model}}) {{.Name}}Response {
+	return {{.Name}}Response{
+		ID: {{if .IdConvert}}{{.IdType}}(m.{{.IdField}}){{else}}m.{{.IdField}}{{end}},
+{{- range .Fields}}
+		{{.Name}}: m.{{.Name}},
+{{- end}}
+{{- if .Timestamps}}
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+{{- end}}
+	}
+}
+`)
+
+var crudRepositoryTemplate = crudTemplate("repository", `package {{.Package}}
+
+import (
+{{- if .ModelImport}}
+	"{{.ModelImport}}"
+{{- end}}
+	"github.com/tdwu/fast_go/fast_db"
+)
+
+// {{.Name}}Repository {{.Label}}仓储，通用增删改查见 fast_db.Repository
+type {{.Name}}Repository struct {
+	*fast_db.Repository[{{.Model}}]
+}
+
+// New{{.Name}}Repository 使用默认数据源
+func New{{.Name}}Repository() *{{.Name}}Repository {
+	return &{{.Name}}Repository{Repository: fast_db.NewRepository[{{.Model}}]()}
+}
+
+// {{lowerFirst .Name}}Updatable 允许修改的字段
+var {{lowerFirst .Name}}Updatable = []string{ {{- range $i, $f := .Writable}}{{if $i}}, {{end}}"{{$f.Name}}"{{end -}} }
+`)
+
+var crudHandlerTemplate = crudTemplate("handler", `package {{.Package}}
+
+import (
+{{- if .ModelImport}}
+	"{{.ModelImport}}"
+{{- end}}
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"github.com/tdwu/fast_go/fast_db"
+)
+
+var {{lowerFirst .Name}}Repo = New{{.Name}}Repository()
+
+// Page{{.Name}} 分页查询{{.Label}}
+// @Summary 分页查询{{.Label}}
+// @Tags {{.Name}}
+// @Accept json
+// @Produce json
+// @Param request body fast_db.PageQuery true "分页、过滤和排序"
+// @Success 200 {object} fast_base.R{data=fast_base.PageResult[{{.Name}}Response]}
+// @Router {{.Route}}/page [post]
+func Page{{.Name}}(c *gin.Context, req *fast_db.PageQuery) (*fast_base.PageResult[{{.Name}}Response], error) {
+	page, err := {{lowerFirst .Name}}Repo.Page(c.Request.Context(), *req)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]{{.Name}}Response, 0, len(*page.List))
+	for i := range *page.List {
+		list = append(list, new{{.Name}}Response(&(*page.List)[i]))
+	}
+	return &fast_base.PageResult[{{.Name}}Response]{PageParams: page.PageParams, TotalPages: page.TotalPages, TotalRows: page.TotalRows, List: &list}, nil
+}
+
+// Get{{.Name}} 查询{{.Label}}
+// @Summary 查询{{.Label}}
+// @Tags {{.Name}}
+// @Produce json
+// @Param id path int true "主键"
+// @Success 200 {object} fast_base.R{data={{.Name}}Response}
+// @Router {{.Route}}/:id [get]
+func Get{{.Name}}(c *gin.Context, req *{{.Name}}IdRequest) ({{.Name}}Response, error) {
+	m, err := {{lowerFirst .Name}}Repo.FindById(c.Request.Context(), req.ID)
+	if err != nil {
+		return {{.Name}}Response{}, err
+	}
+	return new{{.Name}}Response(m), nil
+}
+
+// Create{{.Name}} 创建{{.Label}}
+// @Summary 创建{{.Label}}
+// @Tags {{.Name}}
+// @Accept json
+// @Produce json
+// @Param request body Create{{.Name}}Request true "{{.Label}}"
+// @Success 200 {object} fast_base.R{data={{.Name}}Response}
+// @Router {{.Route}} [post]
+func Create{{.Name}}(c *gin.Context, req *Create{{.Name}}Request) ({{.Name}}Response, error) {
+	m := &{{.Model}}{
+{{- range .Writable}}
+		{{.Name}}: req.{{.Name}},
+{{- end}}
+	}
+	if err := {{lowerFirst .Name}}Repo.Create(c.Request.Context(), m); err != nil {
+		return {{.Name}}Response{}, err
+	}
+	return new{{.Name}}Response(m), nil
+}
+
+// Update{{.Name}} 修改{{.Label}}
+// @Summary 修改{{.Label}}
+// @Tags {{.Name}}
+// @Accept json
+// @Produce json
+// @Param id path int true "主键"
+// @Param request body Update{{.Name}}Request true "{{.Label}}"
+// @Success 200 {object} fast_base.R{data={{.Name}}Response}
+// @Router {{.Route}}/:id [put]
+func Update{{.Name}}(c *gin.Context, req *Update{{.Name}}Request) ({{.Name}}Response, error) {
+	ctx := c.Request.Context()
+	fields := map[string]any{}
+{{- range .Writable}}
+	if req.{{.Name}} != nil {
+		fields["{{.Name}}"] = {{if .Pointer}}req.{{.Name}}{{else}}*req.{{.Name}}{{end}}
+	}
+{{- end}}
+	if err := {{lowerFirst .Name}}Repo.UpdateFields(ctx, req.ID, fields, {{lowerFirst .Name}}Updatable...); err != nil {
+		return {{.Name}}Response{}, err
+	}
+	m, err := {{lowerFirst .Name}}Repo.FindById(ctx, req.ID)
+	if err != nil {
+		return {{.Name}}Response{}, err
+	}
+	return new{{.Name}}Response(m), nil
+}
+
+// Delete{{.Name}} 删除{{.Label}}
+// @Summary 删除{{.Label}}
+// @Tags {{.Name}}
+// @Produce json
+// @Param id path int true "主键"
+// @Success 200 {object} fast_base.R
+// @Router {{.Route}}/:id [delete]
+func Delete{{.Name}}(c *gin.Context, req *{{.Name}}IdRequest) (*fast_base.R, error) {
+	return nil, {{lowerFirst .Name}}Repo.Delete(c.Request.Context(), req.ID)
+}
+`)
+
+var crudTestTemplate = crudTemplate("test", `package {{.Package}}
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+{{- if .UsesTime}}
+	"time"
+{{- end}}
+{{- if .ModelImport}}
+	"{{.ModelImport}}"
+{{- end}}
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_base"
+	"github.com/tdwu/fast_go/fast_db"
+	"github.com/tdwu/fast_go/fast_web"
+	"go.uber.org/zap"
+)
+
+// open{{.Name}}Test 在临时 SQLite 中建表并注册为默认数据源
+func open{{.Name}}Test(t *testing.T) *gin.Engine {
+	t.Helper()
+	fast_base.Logger = zap.NewNop()
+	db, err := fast_db.OpenDataSource(fast_db.DefaultDataSource, fast_db.DataSourceConfig{DriverName: fast_db.DriverSqlite, Database: filepath.Join(t.TempDir(), "test.db"), LogLevel: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&{{.Model}}{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := fast_db.DB
+	fast_db.RegisterDataSource(fast_db.DefaultDataSource, db)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+		fast_db.RegisterDataSource(fast_db.DefaultDataSource, previous)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("{{.Route}}/page", fast_web.JSONHandler(Page{{.Name}}))
+	router.GET("{{.Route}}/:id", fast_web.JSONHandler(Get{{.Name}}))
+	router.POST("{{.Route}}", fast_web.JSONHandler(Create{{.Name}}))
+	router.PUT("{{.Route}}/:id", fast_web.JSONHandler(Update{{.Name}}))
+	router.DELETE("{{.Route}}/:id", fast_web.JSONHandler(Delete{{.Name}}))
+	return router
+}
+
+// do{{.Name}}Request 发送 JSON 请求，成功时把响应的 data 解析到 data
+func do{{.Name}}Request(t *testing.T, router *gin.Engine, method, path string, body any, data any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		content, err := fast_base.Json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(content)
+	}
+	request := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code == http.StatusOK && data != nil {
+		result := struct {
+			Data any {{tag "json:\"data\""}}
+		}{Data: data}
+		if err := fast_base.Json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+			t.Fatalf("unexpected body: %s, %v", response.Body.String(), err)
+		}
+	}
+	return response.Code
+}
+
+func Test{{.Name}}Crud(t *testing.T) {
+	router := open{{.Name}}Test(t)
+
+	var created {{.Name}}Response
+	create := Create{{.Name}}Request{
+{{- range .Writable}}{{if .Sample}}
+		{{.Name}}: {{.Sample}},
+{{- end}}{{end}}
+	}
+	if code := do{{.Name}}Request(t, router, http.MethodPost, "{{.Route}}", create, &created); code != http.StatusOK || created.ID == {{if eq .IdType "string"}}""{{else}}0{{end}} {
+		t.Fatalf("create: %d %+v", code, created)
+	}
+	path := fmt.Sprintf("{{.Route}}/%v", created.ID)
+
+	var got {{.Name}}Response
+	if code := do{{.Name}}Request(t, router, http.MethodGet, path, nil, &got); code != http.StatusOK || got.ID != created.ID {
+		t.Fatalf("get: %d %+v", code, got)
+	}
+{{- with .UpdateField}}
+
+	value := {{.UpdateSample}}
+	var updated {{$.Name}}Response
+	if code := do{{$.Name}}Request(t, router, http.MethodPut, path, Update{{$.Name}}Request{ {{- .Name}}: &value}, &updated); code != http.StatusOK || updated.{{.Name}} != value {
+		t.Fatalf("update: %d %+v", code, updated)
+	}
+{{- end}}
+
+	var page fast_base.PageResult[{{.Name}}Response]
+	query := fast_db.PageQuery{PageParams: fast_base.PageParams{PageIndex: 1, PageSize: 10}}
+	if code := do{{.Name}}Request(t, router, http.MethodPost, "{{.Route}}/page", query, &page); code != http.StatusOK || page.TotalRows != 1 {
+		t.Fatalf("page: %d %+v", code, page)
+	}
+
+	if code := do{{.Name}}Request(t, router, http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: %d", code)
+	}
+	if code := do{{.Name}}Request(t, router, http.MethodGet, path, nil, nil); code == http.StatusOK {
+		t.Fatal("deleted record still readable")
+	}
+}
+`)
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const crudFixtureModel = `package model
+
+import (
+	"time"
+
+	"github.com/tdwu/fast_go/fast_db"
+)
+
+// Note 便签
+type Note struct {
+	fast_db.Model
+	// 标题
+	Title string ` + "`" + `json:"title" gorm:"column:title;size:64;not null" validate:"required,max=64"` + "`" + `
+	// 状态
+	Status  int8       ` + "`" + `json:"status" gorm:"column:status;not null;default:1" jsonDict:"note_status"` + "`" + `
+	Pinned  bool       ` + "`" + `json:"pinned" gorm:"column:pinned;not null;default:false"` + "`" + `
+	RemindAt *time.Time ` + "`" + `json:"remindAt" gorm:"column:remind_at"` + "`" + `
+}
+`
+
+// TestMakeCrudOutputBuilds 在临时模块中生成脚手架，对生成的代码执行 go vet 和 go test
+func TestMakeCrudOutputBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需要编译生成的代码")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("未找到 go 命令")
+	}
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(root, "fast_web", "go.mod"))
+	if err != nil {
+		t.Skip("不在 fast_go 仓库中: " + err.Error())
+	}
+	goVersion := regexp.MustCompile(`(?m)^go (\S+)`).FindSubmatch(content)
+
+	// 临时模块通过 go.work 引用仓库中的 fast_base、fast_db、fast_web
+	dir := t.TempDir()
+	write := func(name, text string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/scaffold\n\ngo "+string(goVersion[1])+"\n")
+	var work strings.Builder
+	modules := []string{"fast_base", "fast_db", "fast_utils", "fast_web"}
+	work.WriteString("go " + string(goVersion[1]) + "\n\nuse (\n\t.\n")
+	for _, m := range modules {
+		work.WriteString("\t" + filepath.Join(root, m) + "\n")
+	}
+	// 模块之间按发布版本互相依赖，这些版本替换为仓库中的目录
+	work.WriteString(")\n\nreplace (\n")
+	requires := map[string]bool{}
+	for _, m := range modules {
+		mod, err := os.ReadFile(filepath.Join(root, m, "go.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range regexp.MustCompile(`github.com/tdwu/fast_go/(fast_\w+) (v\S+)`).FindAllStringSubmatch(string(mod), -1) {
+			if line := "\t" + r[0] + " => " + filepath.Join(root, r[1]) + "\n"; !requires[line] {
+				requires[line] = true
+				work.WriteString(line)
+			}
+		}
+	}
+	work.WriteString(")\n")
+	write("go.work", work.String())
+	write("model/note.go", crudFixtureModel)
+	t.Setenv("GOWORK", filepath.Join(dir, "go.work"))
+	t.Setenv("GOFLAGS", "")
+
+	if err := MakeCrud(filepath.Join(dir, "model"), "Note", filepath.Join(dir, "app", "note"), "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	// 标准库在前，与其他包之间空一行
+	for name, want := range map[string]string{
+		"note_dto.go":          "import (\n\t\"time\"\n\n\t\"example.com/scaffold/model\"\n)",
+		"note_handler_test.go": "\t\"testing\"\n\n\t\"example.com/scaffold/model\"\n",
+	} {
+		src, err := os.ReadFile(filepath.Join(dir, "app", "note", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("%s: imports should be grouped:\n%s", name, src)
+		}
+	}
+
+	for _, args := range [][]string{{"vet", "./..."}, {"test", "-count=1", "./app/..."}} {
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 var dir = flag.String("d", "./", "扫描路径")
 var file = flag.String("s", "./LoadRouter.go", "生成的路由文件保存地址")
 var wrapper = flag.String("w", "1", "是否包装一层(内置封装)")
+var permission = flag.String("p", "", "权限清单保存地址，默认为路由文件同目录的 permissions.json")
 
 // go get -u github.com/swaggo/swag/cmd/swag
 // go install github.com/swaggo/swag/cmd/swag@latest
@@ -30,7 +31,17 @@ var wrapper = flag.String("w", "1", "是否包装一层(内置封装)")
 // go install fast_wgen/gr.go
 // go build -o gr.exe  fast_wgen/gr.go
 // -g D:\ws_go\go_tpl\src\sys\SysApplication.go --ot json  -o D:\ws_go\go_tpl\static\doc\json
+// gr model -h 由表结构生成模型，见 model.go
+// gr crud -h 由模型生成增删改查脚手架，见 crud.go
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "model" {
+		MakeModelCmd(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "crud" {
+		MakeCrudCmd(os.Args[2:])
+		return
+	}
 	flag.Parse()
 	genDir, genOutput, genWrapper := getParamFromMain()
 	if len(genDir) == 0 {
@@ -45,26 +56,39 @@ func main() {
 	fmt.Println("扫描路径：" + genDir)
 	fmt.Println("输出地址：" + genOutput)
 	fmt.Println("内置封装：" + genWrapper)
-	MakeRouter(strings.Split(genDir, ","), genOutput, genWrapper)
+	genPermission := *permission
+	if len(genPermission) == 0 {
+		genPermission = filepath.Join(filepath.Dir(genOutput), "permissions.json")
+	}
+	if err := MakeRouter(strings.Split(genDir, ","), genOutput, genWrapper, genPermission); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
-func MakeRouter(searchDirs []string, outputFile string, w string) {
+// MakeRouter 生成路由文件，permissionFile 为空时不生成权限清单
+func MakeRouter(searchDirs []string, outputFile string, w string, permissionFile string) error {
 	for i := range searchDirs {
 		searchDirs[i] = strings.Trim(searchDirs[i], " ")
 		_, err := os.Stat(searchDirs[i])
 		if os.IsNotExist(err) {
-			fmt.Println("目录不存在：" + searchDirs[i])
-			return
+			return fmt.Errorf("目录不存在：%s", searchDirs[i])
 		}
 	}
 
 	c := Collector{packages: NewPackagesDefinitions(), Routers: list.New()}
-	c.ParseAPIMultiSearchDir(searchDirs)
+	if err := c.ParseAPIMultiSearchDir(searchDirs); err != nil {
+		return err
+	}
 
 	fmt.Println("【阶段3】-----------------------")
+	fmt.Println("【阶段3】检查处理函数签名")
+	if err := resolveHandlers(searchDirs[0], c.Routers, w); err != nil {
+		return err
+	}
 	fmt.Println("【阶段3】生成文件：" + outputFile)
 	headerMap := map[string]string{}
-	header := "\t\"github.com/gin-gonic/gin\"\n\t\"reflect\"\n\t\"github.com/tdwu/fast_go/fast_web\"\n"
+	header := "\t\"github.com/gin-gonic/gin\"\n\t\"github.com/tdwu/fast_go/fast_web\"\n"
 	body := ""
 
 	limits := map[string]Limit{}
@@ -97,45 +121,35 @@ func MakeRouter(searchDirs []string, outputFile string, w string) {
 		body = body + fmt.Sprintf("\t%s := fast_web.RateLimitMiddleware(%s, %s)\n", limit.Name, limit.Num, limit.Cap)
 	}
 
-	// 生成路由，其中自动添加limit中间件
+	// 生成路由，其中自动添加认证、权限、limit等中间件
+	var routers []*RouteProperties
 	for i := c.Routers.Front(); i != nil; i = i.Next() {
 		r, _ := i.Value.(*RouteProperties)
+		routers = append(routers, r)
 		if len(headerMap[r.PackagePath]) == 0 {
 			headerMap[r.PackagePath] = firstCharUpper(r.PackagePath)
 			header = header + fmt.Sprintf("\t%v \"%v\"\n", headerMap[r.PackagePath], r.PackagePath)
 		}
 
-		var limit = ""
-		if len(r.Limit.Name) > 0 {
-			limit = r.Limit.Name + ", "
-		} else if len(r.Limit.Num) > 0 && len(r.Limit.Cap) > 0 {
-			limit = fmt.Sprintf("fast_web.RateLimitMiddleware(%s, %s), ", r.Limit.Num, r.Limit.Cap)
-		}
-
-		if len(r.Receiver) == 0 {
-			if w == "1" {
-				body = body + fmt.Sprintf("\tgin.%v(\"%v\", %vfast_web.GenHandlerFunc(reflect.ValueOf(%v.%v)))\n", strings.ToUpper(r.HTTPMethod), r.Path, limit, headerMap[r.PackagePath], r.MethodName)
-			} else {
-				body = body + fmt.Sprintf("\tgin.%v(\"%v\", %v%v.%v)\n", strings.ToUpper(r.HTTPMethod), r.Path, limit, headerMap[r.PackagePath], r.MethodName)
-			}
-		} else {
-			if w == "1" {
-				body = body + fmt.Sprintf("\tgin.%v(\"%v\", %vfast_web.GenHandlerFunc(reflect.ValueOf(%v.%v{}.%v)))\n", strings.ToUpper(r.HTTPMethod), r.Path, limit, headerMap[r.PackagePath], r.Receiver, r.MethodName)
-			} else {
-				body = body + fmt.Sprintf("\tgin.%v(\"%v\", %v%v.%v{}.%v)\n", strings.ToUpper(r.HTTPMethod), r.Path, limit, headerMap[r.PackagePath], r.Receiver, r.MethodName)
-			}
-		}
-
+		body = body + fmt.Sprintf("\tgin.%v(\"%v\", %v)\n", strings.ToUpper(r.HTTPMethod), r.Path, routeHandlers(r))
+	}
+	if strings.Contains(body, "reflect.ValueOf(") {
+		header = header + "\t\"reflect\"\n"
 	}
 	source := "package main\n\nimport (\n" + header + ")\n\nfunc LoadRouters(gin *gin.Engine) {\n" + body + "}\n"
 	formatted, err := format.Source([]byte(source))
 	if err != nil {
-		fmt.Printf("生成的路由代码格式化失败: %v\n", err)
-		return
+		return fmt.Errorf("生成的路由代码格式化失败: %w", err)
 	}
 	if err := writeGeneratedFile(outputFile, formatted); err != nil {
-		fmt.Printf("写入路由文件失败: %v\n", err)
+		return fmt.Errorf("写入路由文件失败: %w", err)
+	}
+	if len(permissionFile) > 0 {
+		if err := writePermissionManifest(permissionFile, routers); err != nil {
+			return fmt.Errorf("写入权限清单失败: %w", err)
+		}
 	}
+	return nil
 }
 
 func writeGeneratedFile(outputFile string, content []byte) error {
@@ -409,6 +423,18 @@ func findLimit(list []*ast.Comment) Limit {
 	return Limit{}
 }
 
+// findDataScope 数据权限注解：@dataScope order:list
+func findDataScope(list []*ast.Comment) string {
+	for _, comment := range list {
+		commentLine := strings.TrimSpace(strings.TrimLeft(comment.Text, "/"))
+		fields := strings.Fields(commentLine)
+		if len(fields) > 1 && strings.ToLower(fields[0]) == "@datascope" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
 // ParseRouterAPIInfo parses router api info for given astFile.
 // 【改造3】，提取每个接口上的注释，从而获取router信息，并定位出PackageName+MethodName+OperationName
 func (this *Collector) ParseRouterAPIInfo(fileName string, packagePath string, astFile *ast.File) error {
@@ -457,6 +483,7 @@ func (this *Collector) ParseRouterAPIInfo(fileName string, packagePath string, a
 							MethodName:  astDeclaration.Name.Name,
 							Receiver:    n.Name,
 							Limit:       findLimit(astDeclaration.Doc.List),
+							DataScope:   findDataScope(astDeclaration.Doc.List),
 						}
 					} else {
 						router = RouteProperties{
@@ -466,9 +493,13 @@ func (this *Collector) ParseRouterAPIInfo(fileName string, packagePath string, a
 							PackagePath: packagePath,
 							MethodName:  astDeclaration.Name.Name,
 							Limit:       findLimit(astDeclaration.Doc.List),
+							DataScope:   findDataScope(astDeclaration.Doc.List),
 						}
 					}
 
+					if err := findRouteSecurity(astDeclaration.Doc.List, &router); err != nil {
+						return fmt.Errorf("%s: %s: %w", fileName, astDeclaration.Name.Name, err)
+					}
 					fmt.Println("【阶段2】++找到接口：" + router.PackageName + "." + router.MethodName + " -> " + lineRemainder)
 					this.Routers.PushBack(&router)
 
@@ -492,6 +523,13 @@ type RouteProperties struct {
 	MethodName  string
 	Receiver    string
 	Limit       Limit
+	DataScope   string
+	Handler     string // 注册的处理函数表达式，由 resolveHandlers 按签名生成
+	Auth        string
+	Perms       []string
+	Middlewares []string
+	Group       string
+	Summary     string
 }
 
 type Limit struct {
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 路由处理函数签名检查：用 go/types 加载注解所在的包，按函数签名决定生成的注册代码。
+//   - func(*gin.Context)                                            直接注册
+//   - func(*gin.Context, *Req) (Resp, error)                        fast_web.JSONHandler
+//   - func(*gin.Context, *Req, fast_web.SecToken) (Resp, error)     fast_web.JSONHandlerWithToken
+//   - 其他旧版反射可以处理的签名(-w 1)                                  GenHandlerFunc，并输出迁移提示
+// 其余签名在生成阶段报错，不会写出路由文件。
+
+const (
+	ginImportPath     = "github.com/gin-gonic/gin"
+	fastWebImportPath = "github.com/tdwu/fast_go/fast_web"
+)
+
+// handlerDiagnostic 一个无法注册的处理函数，Pos 为函数声明位置
+type handlerDiagnostic struct {
+	Pos    token.Position
+	Name   string
+	Reason string
+}
+
+func (d handlerDiagnostic) String() string {
+	file := d.Pos.Filename
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	if file == "" {
+		return d.Name + ": " + d.Reason
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", file, d.Pos.Line, d.Pos.Column, d.Name, d.Reason)
+}
+
+// HandlerError 签名检查失败，包含所有处理函数的诊断信息
+type HandlerError struct {
+	Diagnostics []handlerDiagnostic
+}
+
+func (e *HandlerError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	lines = append(lines, fmt.Sprintf("%d 个接口的处理函数无法注册:", len(e.Diagnostics)))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, "\t"+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolveHandlers 加载路由所在的包并为每个路由生成 Handler 表达式。
+// w 为 "0" 时不允许回退到反射包装
+func resolveHandlers(dir string, routers *list.List, w string) error {
+	var patterns []string
+	seen := map[string]bool{}
+	for i := routers.Front(); i != nil; i = i.Next() {
+		r := i.Value.(*RouteProperties)
+		if !seen[r.PackagePath] {
+			seen[r.PackagePath] = true
+			patterns = append(patterns, r.PackagePath)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir:  dir,
+		Fset: token.NewFileSet(),
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return fmt.Errorf("加载接口所在的包失败: %w", err)
+	}
+	loaded := map[string]*packages.Package{}
+	var diagnostics []handlerDiagnostic
+	for _, p := range pkgs {
+		loaded[p.PkgPath] = p
+		for _, e := range p.Errors {
+			diagnostics = append(diagnostics, handlerDiagnostic{Name: p.PkgPath, Reason: e.Error()})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return &HandlerError{Diagnostics: diagnostics}
+	}
+
+	for i := routers.Front(); i != nil; i = i.Next() {
+		r := i.Value.(*RouteProperties)
+		p := loaded[r.PackagePath]
+		if p == nil || p.Types == nil {
+			diagnostics = append(diagnostics, handlerDiagnostic{Name: r.PackagePath, Reason: "未能加载包"})
+			continue
+		}
+		handler, warning, d := resolveHandler(p, r, w)
+		if d != nil {
+			diagnostics = append(diagnostics, *d)
+			continue
+		}
+		if warning != "" {
+			fmt.Println("【阶段3】!!" + warning)
+		}
+		r.Handler = handler
+	}
+	if len(diagnostics) > 0 {
+		return &HandlerError{Diagnostics: diagnostics}
+	}
+	return nil
+}
+
+// resolveHandler 返回路由的注册表达式，使用反射包装时同时返回迁移提示
+func resolveHandler(p *packages.Package, r *RouteProperties, w string) (string, string, *handlerDiagnostic) {
+	name := r.PackageName + "." + r.MethodName
+	fail := func(pos token.Pos, format string, args ...any) (string, string, *handlerDiagnostic) {
+		return "", "", &handlerDiagnostic{Pos: p.Fset.Position(pos), Name: name, Reason: fmt.Sprintf(format, args...)}
+	}
+	alias := firstCharUpper(r.PackagePath)
+	var fn *types.Func
+	var expr string
+	if len(r.Receiver) == 0 {
+		fn, _ = p.Types.Scope().Lookup(r.MethodName).(*types.Func)
+		if fn == nil {
+			return fail(token.NoPos, "包 %s 中没有函数 %s", r.PackagePath, r.MethodName)
+		}
+		expr = alias + "." + r.MethodName
+	} else {
+		name = r.PackageName + "." + r.Receiver + "." + r.MethodName
+		tn, _ := p.Types.Scope().Lookup(r.Receiver).(*types.TypeName)
+		if tn == nil {
+			return fail(token.NoPos, "包 %s 中没有类型 %s", r.PackagePath, r.Receiver)
+		}
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, p.Types, r.MethodName)
+		fn, _ = obj.(*types.Func)
+		if fn == nil {
+			return fail(tn.Pos(), "类型 %s 没有方法 %s", r.Receiver, r.MethodName)
+		}
+		if !tn.Exported() {
+			return fail(fn.Pos(), "接收者类型 %s 未导出，生成的路由文件无法引用", r.Receiver)
+		}
+		if _, ok := tn.Type().Underlying().(*types.Struct); !ok {
+			return fail(fn.Pos(), "接收者类型 %s 不是结构体，无法用 %s{} 构造", r.Receiver, r.Receiver)
+		}
+		if _, ok := fn.Type().(*types.Signature).Recv().Type().(*types.Pointer); ok {
+			expr = "(&" + alias + "." + r.Receiver + "{})." + r.MethodName
+		} else {
+			expr = alias + "." + r.Receiver + "{}." + r.MethodName
+		}
+	}
+	if !fn.Exported() {
+		return fail(fn.Pos(), "处理函数未导出，生成的路由文件无法引用")
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 || sig.RecvTypeParams().Len() > 0 {
+		return fail(fn.Pos(), "泛型处理函数无法直接注册")
+	}
+
+	switch shape, reason := handlerShapeOf(sig); shape {
+	case shapeRaw:
+		return expr, "", nil
+	case shapeJSON:
+		return "fast_web.JSONHandler(" + expr + ")", "", nil
+	case shapeToken:
+		return "fast_web.JSONHandlerWithToken(" + expr + ")", "", nil
+	case shapeReflect:
+		if w == "0" {
+			return fail(fn.Pos(), "签名 %s 需要反射包装，但已用 -w 0 关闭；请改为 func(*gin.Context, *Req) (Resp, error)", typeName(sig))
+		}
+		warning := handlerDiagnostic{Pos: p.Fset.Position(fn.Pos()), Name: name, Reason: "使用反射包装，建议改为 func(*gin.Context, *Req) (Resp, error)"}
+		return "fast_web.GenHandlerFunc(reflect.ValueOf(" + expr + "))", warning.String(), nil
+	default:
+		return fail(fn.Pos(), "%s", reason)
+	}
+}
+
+type handlerShape int
+
+const (
+	shapeInvalid handlerShape = iota
+	shapeRaw
+	shapeJSON
+	shapeToken
+	shapeReflect
+)
+
+// handlerShapeOf 按参数和返回值判断注册方式，shapeInvalid 时返回原因
+func handlerShapeOf(sig *types.Signature) (handlerShape, string) {
+	params, results := sig.Params(), sig.Results()
+	if sig.Variadic() {
+		return shapeInvalid, "不支持可变参数"
+	}
+	if params.Len() == 1 && results.Len() == 0 && isGinContext(params.At(0).Type()) {
+		return shapeRaw, ""
+	}
+	if params.Len() >= 2 && params.Len() <= 3 && isGinContext(params.At(0).Type()) &&
+		isStructPointer(params.At(1).Type()) && results.Len() == 2 && isError(results.At(1).Type()) &&
+		(params.Len() == 2 || isSecToken(params.At(2).Type())) {
+		if params.Len() == 2 {
+			return shapeJSON, ""
+		}
+		return shapeToken, ""
+	}
+
+	// 旧版反射包装支持的参数：*gin.Context、SecToken、结构体或结构体指针、map
+	for i := 0; i < params.Len(); i++ {
+		t := params.At(i).Type()
+		if isGinContext(t) || isSecToken(t) || isStruct(t) || isStructPointer(t) {
+			continue
+		}
+		if _, ok := t.Underlying().(*types.Map); ok {
+			continue
+		}
+		return shapeInvalid, fmt.Sprintf("第 %d 个参数 %s 无法绑定，请求参数必须是结构体指针", i+1, typeName(t))
+	}
+	switch results.Len() {
+	case 0, 1:
+	case 2:
+		if !isError(results.At(1).Type()) {
+			return shapeInvalid, fmt.Sprintf("第 2 个返回值 %s 必须是 error", typeName(results.At(1).Type()))
+		}
+	default:
+		return shapeInvalid, fmt.Sprintf("返回值过多(%d 个)，应为 (Resp, error)", results.Len())
+	}
+	return shapeReflect, ""
+}
+
+func isNamed(t types.Type, pkgPath, name string) bool {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+func isGinContext(t types.Type) bool {
+	p, ok := types.Unalias(t).(*types.Pointer)
+	return ok && isNamed(p.Elem(), ginImportPath, "Context")
+}
+
+func isSecToken(t types.Type) bool {
+	return isNamed(t, fastWebImportPath, "SecToken")
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+func isStructPointer(t types.Type) bool {
+	p, ok := types.Unalias(t).(*types.Pointer)
+	return ok && isStruct(p.Elem())
+}
+
+// typeName 诊断信息中的类型名使用包名而不是完整导入路径
+func typeName(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// 签名检查只比较类型所在的包路径和名称，用最小的 gin、fast_web 替身代替真实依赖
+var handlerFixtureDeps = map[string]string{
+	ginImportPath:     "package gin\n\ntype Context struct{}\n",
+	fastWebImportPath: "package fast_web\n\ntype SecToken struct{ UserId int64 }\n",
+}
+
+const handlerFixture = `package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/tdwu/fast_go/fast_web"
+)
+
+type Req struct{ ID int64 }
+type Resp struct{ Name string }
+
+func Raw(c *gin.Context) {}
+func JSON(c *gin.Context, req *Req) (Resp, error) { return Resp{}, nil }
+func Token(c *gin.Context, req *Req, token fast_web.SecToken) (*Resp, error) { return nil, nil }
+func Legacy(c *gin.Context, req Req, token fast_web.SecToken) Resp { return Resp{} }
+func LegacyMap(params map[string]any) (Resp, error) { return Resp{}, nil }
+func Variadic(c *gin.Context, reqs ...*Req) {}
+func Scalar(c *gin.Context, id int64) (Resp, error) { return Resp{}, nil }
+func NotError(c *gin.Context, req *Req) (Resp, string) { return Resp{}, "" }
+func TooMany(c *gin.Context, req *Req) (Resp, int, error) { return Resp{}, 0, nil }
+func Generic[T any](c *gin.Context, req *T) (Resp, error) { return Resp{}, nil }
+func hidden(c *gin.Context) {}
+
+var NotFunc = 1
+
+type Ctrl struct{}
+
+func (*Ctrl) Pointer(c *gin.Context, req *Req) (Resp, error) { return Resp{}, nil }
+func (Ctrl) Value(c *gin.Context) {}
+
+type ctrl struct{}
+
+func (ctrl) Hidden(c *gin.Context) {}
+
+type Handlers []int
+
+func (Handlers) List(c *gin.Context) {}
+`
+
+// fixtureImporter 从源码检查替身包，其余包交给默认导入器
+type fixtureImporter struct {
+	fset *token.FileSet
+	pkgs map[string]*types.Package
+}
+
+func (im *fixtureImporter) Import(path string) (*types.Package, error) {
+	if p, ok := im.pkgs[path]; ok {
+		return p, nil
+	}
+	src, ok := handlerFixtureDeps[path]
+	if !ok {
+		return importer.Default().Import(path)
+	}
+	p, _, err := checkFixture(im, path, src)
+	if err != nil {
+		return nil, err
+	}
+	im.pkgs[path] = p
+	return p, nil
+}
+
+func checkFixture(im *fixtureImporter, path, src string) (*types.Package, *ast.File, error) {
+	file, err := parser.ParseFile(im.fset, strings.ReplaceAll(path, "/", "_")+".go", src, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := (&types.Config{Importer: im}).Check(path, im.fset, []*ast.File{file}, nil)
+	return p, file, err
+}
+
+func loadHandlerFixture(t *testing.T) *packages.Package {
+	t.Helper()
+	im := &fixtureImporter{fset: token.NewFileSet(), pkgs: map[string]*types.Package{}}
+	p, _, err := checkFixture(im, "example.com/app/user", handlerFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &packages.Package{PkgPath: p.Path(), Name: p.Name(), Types: p, Fset: im.fset}
+}
+
+func TestResolveHandler(t *testing.T) {
+	p := loadHandlerFixture(t)
+	cases := []struct {
+		receiver, method string
+		w                string
+		handler          string
+		warning          string // 迁移提示中应包含的内容
+		err              string // 诊断信息中应包含的内容
+	}{
+		{method: "Raw", handler: "ExampleComAppUser.Raw"},
+		{method: "JSON", handler: "fast_web.JSONHandler(ExampleComAppUser.JSON)"},
+		{method: "Token", handler: "fast_web.JSONHandlerWithToken(ExampleComAppUser.Token)"},
+		{method: "Legacy", w: "1", handler: "fast_web.GenHandlerFunc(reflect.ValueOf(ExampleComAppUser.Legacy))", warning: "user.Legacy: 使用反射包装"},
+		{method: "LegacyMap", w: "1", handler: "fast_web.GenHandlerFunc(reflect.ValueOf(ExampleComAppUser.LegacyMap))", warning: "使用反射包装"},
+		{method: "Legacy", w: "0", err: "签名 func(c *gin.Context, req user.Req, token fast_web.SecToken) user.Resp 需要反射包装"},
+		{method: "Variadic", err: "不支持可变参数"},
+		{method: "Scalar", err: "第 2 个参数 int64 无法绑定"},
+		{method: "NotError", err: "第 2 个返回值 string 必须是 error"},
+		{method: "TooMany", err: "返回值过多(3 个)"},
+		{method: "Generic", err: "泛型处理函数无法直接注册"},
+		{method: "hidden", err: "处理函数未导出"},
+		{method: "Missing", err: "包 example.com/app/user 中没有函数 Missing"},
+		{method: "NotFunc", err: "没有函数 NotFunc"},
+		{receiver: "Ctrl", method: "Pointer", handler: "fast_web.JSONHandler((&ExampleComAppUser.Ctrl{}).Pointer)"},
+		{receiver: "Ctrl", method: "Value", handler: "ExampleComAppUser.Ctrl{}.Value"},
+		{receiver: "Ctrl", method: "Missing", err: "类型 Ctrl 没有方法 Missing"},
+		{receiver: "Missing", method: "Raw", err: "包 example.com/app/user 中没有类型 Missing"},
+		{receiver: "ctrl", method: "Hidden", err: "接收者类型 ctrl 未导出"},
+		{receiver: "Handlers", method: "List", err: "接收者类型 Handlers 不是结构体"},
+	}
+	for _, tc := range cases {
+		name := strings.TrimPrefix(tc.receiver+"."+tc.method, ".") + "/w" + tc.w
+		t.Run(name, func(t *testing.T) {
+			r := &RouteProperties{PackageName: "user", PackagePath: "example.com/app/user", Receiver: tc.receiver, MethodName: tc.method}
+			handler, warning, d := resolveHandler(p, r, tc.w)
+			if tc.err != "" {
+				if d == nil || !strings.Contains(d.Reason, tc.err) {
+					t.Fatalf("diagnostic: %v, want %q", d, tc.err)
+				}
+				return
+			}
+			if d != nil {
+				t.Fatalf("unexpected diagnostic: %s", d)
+			}
+			if handler != tc.handler {
+				t.Errorf("handler: %q, want %q", handler, tc.handler)
+			}
+			if !strings.Contains(warning, tc.warning) || (tc.warning == "") != (warning == "") {
+				t.Errorf("warning: %q, want %q", warning, tc.warning)
+			}
+		})
+	}
+}
+
+func TestHandlerDiagnosticPosition(t *testing.T) {
+	p := loadHandlerFixture(t)
+	_, _, d := resolveHandler(p, &RouteProperties{PackageName: "user", PackagePath: "example.com/app/user", MethodName: "Variadic"}, "1")
+	if d == nil || d.Pos.Line != 16 {
+		t.Fatalf("diagnostic should point at the declaration: %+v", d)
+	}
+	err := &HandlerError{Diagnostics: []handlerDiagnostic{*d, {Name: "example.com/app/order", Reason: "未能加载包"}}}
+	want := fmt.Sprintf("2 个接口的处理函数无法注册:\n\t%s:16:6: user.Variadic: 不支持可变参数\n\texample.com/app/order: 未能加载包", d.Pos.Filename)
+	if err.Error() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", err.Error(), want)
+	}
+}
@@ -0,0 +1,1150 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// 由表结构生成 GORM 模型：
+//
+//	gr model -m conf/db/migration -o ./model  // 按顺序解析 golang-migrate 的 *.up.sql
+//	gr model -driver mysql -dsn "root:123456@tcp(127.0.0.1:3306)/demo" -o ./model -t sys_user,sys_role
+//
+// 每张表生成 {表名}.gen.go，每次运行覆盖；自定义方法写在同一包的其他文件中，不会被修改。
+// 表包含 id(非自增的 bigint 主键)、created_at、updated_at、deleted_at(时间类型)时嵌入 fast_db.Model，
+// 另有 bigint 的 created_by、updated_by 时嵌入 fast_db.AuditModel；类型不符时逐个写出字段。
+// gorm 标签只写长度、精度、约束和默认值，不写数据库相关的 type。字段注释中的 dict:xxx 生成 jsonDict 标签，如 COMMENT '状态 dict:user_status'。
+
+// modelGeneratedHeader 生成文件的首行，只覆盖以此开头的文件
+const modelGeneratedHeader = "// Code generated by fast_wgen model. DO NOT EDIT."
+
+// MakeModelCmd model 子命令
+func MakeModelCmd(args []string) {
+	fs := flag.NewFlagSet("model", flag.ExitOnError)
+	migration := fs.String("m", "./conf/db/migration", "golang-migrate 迁移文件目录")
+	driver := fs.String("driver", "", "从数据库读取表结构：mysql、postgres")
+	dsn := fs.String("dsn", "", "数据库连接串，指定时不读取迁移文件")
+	output := fs.String("o", "./model", "模型输出目录")
+	pkg := fs.String("p", "", "包名，默认为输出目录名")
+	tables := fs.String("t", "", "只生成指定的表，逗号分隔")
+	fs.Parse(args)
+
+	if *pkg == "" {
+		abs, _ := filepath.Abs(*output)
+		*pkg = strings.ReplaceAll(filepath.Base(abs), "-", "_")
+	}
+	var defs []*tableDef
+	var err error
+	if *dsn != "" {
+		fmt.Println("读取数据库：" + *driver)
+		defs, err = loadTablesFromDB(*driver, *dsn)
+	} else {
+		fmt.Println("读取迁移文件：" + *migration)
+		defs, err = loadTablesFromMigration(*migration)
+	}
+	if err != nil {
+		fmt.Printf("读取表结构失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := MakeModel(defs, *output, *pkg, splitNames(*tables)); err != nil {
+		fmt.Printf("生成模型失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// MakeModel 为每张表生成 {表名}.gen.go。未指定表时删除已不存在的表的生成文件
+func MakeModel(defs []*tableDef, outputDir, pkg string, only []string) error {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+	selected := map[string]bool{}
+	for _, n := range only {
+		selected[strings.ToLower(n)] = true
+	}
+	written := map[string]bool{}
+	for _, t := range defs {
+		if len(selected) > 0 && !selected[strings.ToLower(t.Name)] || t.Name == "schema_migrations" {
+			continue
+		}
+		source, err := format.Source([]byte(renderModel(t, pkg)))
+		if err != nil {
+			return fmt.Errorf("%s: 格式化失败: %w", t.Name, err)
+		}
+		name := t.Name + ".gen.go"
+		if err := writeModelFile(filepath.Join(outputDir, name), source); err != nil {
+			return err
+		}
+		written[name] = true
+		fmt.Println("生成模型：" + t.Name + " -> " + filepath.Join(outputDir, name))
+	}
+	if len(selected) > 0 {
+		return nil
+	}
+	stale, _ := filepath.Glob(filepath.Join(outputDir, "*.gen.go"))
+	for _, f := range stale {
+		if !written[filepath.Base(f)] && isGeneratedModel(f) {
+			fmt.Println("删除模型：" + f)
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isGeneratedModel(path string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && strings.HasPrefix(string(content), modelGeneratedHeader)
+}
+
+// writeModelFile 不覆盖手写的同名文件
+func writeModelFile(path string, content []byte) error {
+	if _, err := os.Stat(path); err == nil && !isGeneratedModel(path) {
+		return fmt.Errorf("%s 不是生成的文件，未覆盖", path)
+	}
+	return writeGeneratedFile(path, content)
+}
+
+// ///////////////////////////////////////////生成代码////////////////////////////////
+
+var modelColumns = []string{"id", "created_at", "updated_at", "deleted_at"}
+var auditColumns = []string{"created_by", "updated_by"}
+
+// embedsModel 标准字段的类型与 fast_db.Model 一致时才能嵌入：id 为非自增的 bigint 主键(由审计插件填充雪花 ID)，
+// 时间字段为日期时间类型
+func (t *tableDef) embedsModel() bool {
+	if !t.hasColumns(modelColumns...) {
+		return false
+	}
+	id, _ := t.column("id")
+	if !id.PrimaryKey || id.AutoIncrement || id.goType() != "int64" {
+		return false
+	}
+	return t.columnsOfType("time.Time", modelColumns[1:]...)
+}
+
+// embedsAudit 在 embedsModel 的基础上，created_by、updated_by 为 bigint
+func (t *tableDef) embedsAudit() bool {
+	return t.hasColumns(auditColumns...) && t.columnsOfType("int64", auditColumns...)
+}
+
+// columnsOfType 字段的 Go 类型(不计可空)是否都为 goType
+func (t *tableDef) columnsOfType(goType string, names ...string) bool {
+	for _, n := range names {
+		if c, _ := t.column(n); c == nil || strings.TrimPrefix(c.goType(), "*") != goType {
+			return false
+		}
+	}
+	return true
+}
+
+var dictPattern = regexp.MustCompile(`(?i)[(（\[]?\s*dict\s*[:：]\s*([\w.\-]+)\s*[)）\]]?`)
+
+func renderModel(t *tableDef, pkg string) string {
+	embed := ""
+	skip := map[string]bool{}
+	if t.embedsModel() {
+		embed = "fast_db.Model"
+		for _, c := range modelColumns {
+			skip[c] = true
+		}
+		if t.embedsAudit() {
+			embed = "fast_db.AuditModel"
+			for _, c := range auditColumns {
+				skip[c] = true
+			}
+		}
+	}
+
+	imports := map[string]bool{}
+	var fields strings.Builder
+	if embed != "" {
+		imports["github.com/tdwu/fast_go/fast_db"] = true
+		fields.WriteString("\t" + embed + "\n")
+	}
+	for _, c := range t.Columns {
+		if skip[strings.ToLower(c.Name)] {
+			continue
+		}
+		goType := c.goType()
+		if strings.Contains(goType, "time.") {
+			imports["time"] = true
+		}
+		comment, dict := splitDict(c.Comment)
+		if comment != "" {
+			fields.WriteString("\t// " + strings.ReplaceAll(comment, "\n", " ") + "\n")
+		}
+		tags := []string{fmt.Sprintf("json:%q", lowerCamel(c.Name)), fmt.Sprintf("gorm:%q", c.gormTag())}
+		if v := c.validateTag(goType); v != "" {
+			tags = append(tags, fmt.Sprintf("validate:%q", v))
+		}
+		if dict != "" {
+			tags = append(tags, fmt.Sprintf("jsonDict:%q", dict))
+		}
+		fields.WriteString(fmt.Sprintf("\t%s %s `%s`\n", upperCamel(c.Name), goType, strings.Join(tags, " ")))
+	}
+
+	var b strings.Builder
+	b.WriteString(modelGeneratedHeader + "\n\npackage " + pkg + "\n\n")
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for p := range imports {
+			paths = append(paths, p)
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			si, sj := strings.Contains(paths[i], "."), strings.Contains(paths[j], ".")
+			if si != sj {
+				return sj
+			}
+			return paths[i] < paths[j]
+		})
+		b.WriteString("import (\n")
+		for i, p := range paths {
+			// 标准库在前，与第三方包之间空一行
+			if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(p, ".") {
+				b.WriteString("\n")
+			}
+			b.WriteString("\t\"" + p + "\"\n")
+		}
+		b.WriteString(")\n\n")
+	}
+	name := upperCamel(t.Name)
+	comment, _ := splitDict(t.Comment)
+	if comment == "" {
+		comment = t.Name
+	}
+	b.WriteString("// " + name + " " + strings.ReplaceAll(comment, "\n", " ") + "\n")
+	b.WriteString("type " + name + " struct {\n" + fields.String() + "}\n\n")
+	b.WriteString("// TableName 表名\nfunc (" + name + ") TableName() string {\n\treturn \"" + t.Name + "\"\n}\n")
+	return b.String()
+}
+
+// splitDict 从注释中取出 dict:xxx，返回剩余的注释和字典名
+func splitDict(comment string) (string, string) {
+	m := dictPattern.FindStringSubmatchIndex(comment)
+	if m == nil {
+		return strings.TrimSpace(comment), ""
+	}
+	rest := strings.TrimSpace(comment[:m[0]] + " " + comment[m[1]:])
+	return strings.Join(strings.Fields(rest), " "), comment[m[2]:m[3]]
+}
+
+func upperCamel(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	s := b.String()
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		s = "T" + s
+	}
+	return s
+}
+
+func lowerCamel(name string) string {
+	s := upperCamel(name)
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// ///////////////////////////////////////////表结构////////////////////////////////
+
+type tableDef struct {
+	Name    string
+	Comment string
+	Columns []*columnDef
+}
+
+type columnDef struct {
+	Name          string
+	Base          string // 小写的基础类型，如 varchar、bigint
+	Size          int    // 字符串长度，如 varchar(64) 为 64
+	Precision     int    // decimal(10, 2) 的 10
+	Scale         int    // decimal(10, 2) 的 2
+	Unsigned      bool
+	NotNull       bool
+	Default       string
+	PrimaryKey    bool
+	AutoIncrement bool
+	Unique        bool
+	Comment       string
+}
+
+func (t *tableDef) column(name string) (*columnDef, int) {
+	for i, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c, i
+		}
+	}
+	return nil, -1
+}
+
+func (t *tableDef) hasColumns(names ...string) bool {
+	for _, n := range names {
+		if c, _ := t.column(n); c == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *columnDef) nullable() bool {
+	return !c.NotNull && !c.PrimaryKey
+}
+
+func (c *columnDef) goType() string {
+	t := "string"
+	switch c.Base {
+	case "bool", "boolean":
+		t = "bool"
+	case "tinyint":
+		t = IfStr(c.Size == 1, "bool", "int8")
+	case "smallint", "int2", "smallserial":
+		t = "int16"
+	case "int", "integer", "mediumint", "int4", "serial":
+		t = "int32"
+	case "bigint", "int8", "bigserial":
+		t = "int64"
+	case "float", "real", "float4":
+		t = "float32"
+	case "double", "double precision", "float8", "decimal", "numeric":
+		t = "float64"
+	case "date", "datetime", "timestamp", "timestamptz", "timestamp with time zone", "timestamp without time zone":
+		t = "time.Time"
+	case "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary", "bytea":
+		return "[]byte"
+	}
+	if c.Unsigned && strings.HasPrefix(t, "int") {
+		t = "u" + t
+	}
+	if c.nullable() {
+		t = "*" + t
+	}
+	return t
+}
+
+// gormTag 不写数据库相关的 type，只写长度和精度，由 GORM 按驱动选择类型，模型在 MySQL、PostgreSQL、SQLite 中都可使用。
+// 默认值为函数时写 default:(-)，插入时不传零值，也不写进 AutoMigrate 的 DDL
+func (c *columnDef) gormTag() string {
+	parts := []string{"column:" + c.Name}
+	if c.Size > 0 && c.Base != "tinyint" {
+		parts = append(parts, "size:"+strconv.Itoa(c.Size))
+	}
+	if c.Precision > 0 {
+		parts = append(parts, "precision:"+strconv.Itoa(c.Precision))
+		if c.Scale > 0 {
+			parts = append(parts, "scale:"+strconv.Itoa(c.Scale))
+		}
+	}
+	if c.PrimaryKey {
+		parts = append(parts, "primaryKey")
+	}
+	if c.AutoIncrement {
+		parts = append(parts, "autoIncrement")
+	}
+	if c.NotNull && !c.PrimaryKey {
+		parts = append(parts, "not null")
+	}
+	if c.Unique {
+		parts = append(parts, "unique")
+	}
+	if strings.Contains(c.Default, "(") || isDefaultFunction(c.Default) {
+		parts = append(parts, "default:(-)")
+	} else if c.Default != "" {
+		def := c.Default
+		if strings.TrimPrefix(c.goType(), "*") == "bool" {
+			// tinyint(1) 的 0、1 改为 false、true，PostgreSQL 的 boolean 不接受数字
+			def = IfStr(def == "0", "false", IfStr(def == "1", "true", def))
+		}
+		parts = append(parts, "default:"+def)
+	}
+	return strings.Join(parts, ";")
+}
+
+func isDefaultFunction(def string) bool {
+	switch strings.ToLower(def) {
+	case "current_timestamp", "current_date", "current_time", "localtimestamp", "localtime":
+		return true
+	}
+	return false
+}
+
+// validateTag 非空且没有默认值的字符串、时间必填，字符串按长度限制。数值和布尔的零值是合法值，不加 required
+func (c *columnDef) validateTag(goType string) string {
+	var rules []string
+	if c.NotNull && c.Default == "" && !c.PrimaryKey && (goType == "string" || goType == "time.Time") {
+		rules = append(rules, "required")
+	}
+	if c.Size > 0 && strings.TrimPrefix(goType, "*") == "string" {
+		if len(rules) == 0 {
+			rules = append(rules, "omitempty")
+		}
+		rules = append(rules, "max="+strconv.Itoa(c.Size))
+	}
+	return strings.Join(rules, ",")
+}
+
+// ///////////////////////////////////////////读取表结构////////////////////////////////
+
+var migrationPattern = regexp.MustCompile(`^(\d+)_.*\.up\.sql$`)
+
+// loadTablesFromMigration 按版本号顺序执行迁移文件中的 DDL，得到最终的表结构
+func loadTablesFromMigration(dir string) ([]*tableDef, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type migration struct {
+		version uint64
+		path    string
+	}
+	var files []migration
+	for _, e := range entries {
+		m := migrationPattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		v, _ := strconv.ParseUint(m[1], 10, 64)
+		files = append(files, migration{v, filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	s := &ddlSchema{}
+	for _, f := range files {
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.apply(string(content)); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.path, err)
+		}
+	}
+	return s.tables, nil
+}
+
+// loadTablesFromDB MySQL 解析 SHOW CREATE TABLE，PostgreSQL 读取系统表
+func loadTablesFromDB(driver, dsn string) ([]*tableDef, error) {
+	switch driver {
+	case "mysql":
+		return loadMysqlTables(dsn)
+	case "postgres":
+		return loadPostgresTables(dsn)
+	}
+	return nil, fmt.Errorf("不支持的驱动: %s", driver)
+}
+
+func loadMysqlTables(dsn string) ([]*tableDef, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query("SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s := &ddlSchema{}
+	for _, name := range names {
+		var table, ddl string
+		if err := db.QueryRow("SHOW CREATE TABLE `"+strings.ReplaceAll(name, "`", "``")+"`").Scan(&table, &ddl); err != nil {
+			return nil, err
+		}
+		if err := s.apply(ddl); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return s.tables, nil
+}
+
+const postgresColumnsSQL = `SELECT c.relname, COALESCE(obj_description(c.oid, 'pg_class'), ''), a.attname,
+	format_type(a.atttypid, a.atttypmod), a.attnotnull, COALESCE(pg_get_expr(d.adbin, d.adrelid), ''),
+	COALESCE(col_description(c.oid, a.attnum), ''),
+	EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)),
+	EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisunique AND NOT i.indisprimary AND i.indnatts = 1 AND a.attnum = ANY(i.indkey))
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+LEFT JOIN pg_attrdef d ON d.adrelid = c.oid AND d.adnum = a.attnum
+WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND n.nspname = current_schema()
+ORDER BY c.relname, a.attnum`
+
+func loadPostgresTables(dsn string) ([]*tableDef, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(postgresColumnsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []*tableDef
+	for rows.Next() {
+		var table, tableComment, name, typ, def, comment string
+		var notNull, primary, unique bool
+		if err := rows.Scan(&table, &tableComment, &name, &typ, &notNull, &def, &comment, &primary, &unique); err != nil {
+			return nil, err
+		}
+		if len(tables) == 0 || tables[len(tables)-1].Name != table {
+			tables = append(tables, &tableDef{Name: table, Comment: tableComment})
+		}
+		c := &columnDef{Name: name, NotNull: notNull, PrimaryKey: primary, Unique: unique, Comment: comment}
+		parseColumnType(c, tokenizeDDL(typ))
+		if strings.HasPrefix(def, "nextval(") {
+			c.AutoIncrement = true
+		} else {
+			c.Default = def
+		}
+		t := tables[len(tables)-1]
+		t.Columns = append(t.Columns, c)
+	}
+	return tables, rows.Err()
+}
+
+// ///////////////////////////////////////////DDL 解析////////////////////////////////
+
+// ddlSchema 依次执行 CREATE TABLE、ALTER TABLE、DROP TABLE、RENAME TABLE、COMMENT ON，其他语句忽略
+type ddlSchema struct {
+	tables []*tableDef
+}
+
+func (s *ddlSchema) table(name string) (*tableDef, int) {
+	for i, t := range s.tables {
+		if strings.EqualFold(t.Name, name) {
+			return t, i
+		}
+	}
+	return nil, -1
+}
+
+type ddlToken struct {
+	text   string
+	quoted bool // 带引号的标识符
+	str    bool // 字符串
+}
+
+func (t ddlToken) is(words ...string) bool {
+	if t.quoted || t.str {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenizeDDL 拆分为标识符、字符串和符号，去掉注释和空白
+func tokenizeDDL(sql string) []ddlToken {
+	var tokens []ddlToken
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '-' && strings.HasPrefix(sql[i:], "--"), ch == '#':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			var b strings.Builder
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == '\\' && ch == '\'' && j+1 < len(sql) {
+					b.WriteByte(sql[j+1])
+					j += 2
+					continue
+				}
+				if sql[j] == closing {
+					if j+1 < len(sql) && sql[j+1] == closing && ch != '[' {
+						b.WriteByte(closing)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(sql[j])
+				j++
+			}
+			tokens = append(tokens, ddlToken{text: b.String(), quoted: ch != '\'', str: ch == '\''})
+			i = j + 1
+		case isDDLWord(ch):
+			j := i
+			for j < len(sql) && isDDLWord(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, ddlToken{text: sql[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, ddlToken{text: sql[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isDDLWord(ch byte) bool {
+	return ch == '_' || ch == '$' || ch == '.' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= 0x80
+}
+
+// splitDDL 按顶层的分号或逗号拆分
+func splitDDL(tokens []ddlToken, sep string) [][]ddlToken {
+	var parts [][]ddlToken
+	depth, start := 0, 0
+	for i, t := range tokens {
+		if t.quoted || t.str {
+			continue
+		}
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+// parenEnd 返回与 tokens[i] 的左括号匹配的右括号位置
+func parenEnd(tokens []ddlToken, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		if tokens[j].quoted || tokens[j].str {
+			continue
+		}
+		switch tokens[j].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// tableName 去掉 schema 和引号，a.b、"a"."b"、a."b" 都返回 b
+func tableName(tokens []ddlToken, i int) (string, int) {
+	name := tokens[i].text
+	if !tokens[i].quoted {
+		if k := strings.LastIndex(name, "."); k >= 0 {
+			name = name[k+1:]
+		}
+	}
+	// a."b" 拆分为 a. 和 "b"，"a".b 拆分为 "a" 和 .b
+	if i+1 < len(tokens) && tokens[i+1].quoted && !tokens[i].quoted && strings.HasSuffix(tokens[i].text, ".") {
+		i++
+		name = tokens[i].text
+	} else if i+1 < len(tokens) && tokens[i].quoted && !tokens[i+1].quoted && len(tokens[i+1].text) > 1 && tokens[i+1].text[0] == '.' {
+		i++
+		name = tokens[i].text[strings.LastIndex(tokens[i].text, ".")+1:]
+	}
+	for i+2 < len(tokens) && tokens[i+1].text == "." && !tokens[i+1].quoted {
+		i += 2
+		name = tokens[i].text
+	}
+	return name, i + 1
+}
+
+func (s *ddlSchema) apply(sql string) error {
+	for _, stmt := range splitDDL(tokenizeDDL(sql), ";") {
+		if len(stmt) == 0 {
+			continue
+		}
+		var err error
+		switch {
+		case stmt[0].is("create"):
+			err = s.create(stmt)
+		case stmt[0].is("alter") && len(stmt) > 2 && stmt[1].is("table"):
+			err = s.alter(stmt)
+		case stmt[0].is("drop") && len(stmt) > 2 && stmt[1].is("table"):
+			s.drop(stmt[2:])
+		case stmt[0].is("rename") && len(stmt) > 4 && stmt[1].is("table"):
+			for _, part := range splitDDL(stmt[2:], ",") {
+				if len(part) >= 3 {
+					from, i := tableName(part, 0)
+					if i+1 < len(part) {
+						to, _ := tableName(part, i+1)
+						s.rename(from, to)
+					}
+				}
+			}
+		case stmt[0].is("comment") && len(stmt) > 4 && stmt[1].is("on"):
+			s.comment(stmt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ddlSchema) create(stmt []ddlToken) error {
+	i := 1
+	for i < len(stmt) && stmt[i].is("temporary", "temp", "unlogged", "or", "replace") {
+		i++
+	}
+	if i >= len(stmt) || !stmt[i].is("table") {
+		return nil
+	}
+	i++
+	if i+2 < len(stmt) && stmt[i].is("if") && stmt[i+1].is("not") && stmt[i+2].is("exists") {
+		i += 3
+	}
+	if i >= len(stmt) {
+		return fmt.Errorf("缺少表名")
+	}
+	name, i := tableName(stmt, i)
+	if i >= len(stmt) || stmt[i].text != "(" {
+		// CREATE TABLE ... AS SELECT / LIKE 无法得到字段
+		fmt.Println("忽略无法解析的建表语句：" + name)
+		return nil
+	}
+	end := parenEnd(stmt, i)
+	t := &tableDef{Name: name}
+	for _, def := range splitDDL(stmt[i+1:end], ",") {
+		if len(def) > 0 {
+			t.addDefinition(def)
+		}
+	}
+	// 表选项：COMMENT='...'
+	for j := end + 1; j < len(stmt); j++ {
+		if stmt[j].is("comment") {
+			if j+1 < len(stmt) && stmt[j+1].text == "=" {
+				j++
+			}
+			if j+1 < len(stmt) && stmt[j+1].str {
+				t.Comment = stmt[j+1].text
+			}
+		}
+	}
+	if _, idx := s.table(name); idx >= 0 {
+		s.tables[idx] = t
+	} else {
+		s.tables = append(s.tables, t)
+	}
+	return nil
+}
+
+// addDefinition 字段定义或表约束
+func (t *tableDef) addDefinition(def []ddlToken) {
+	if def[0].is("constraint") && len(def) > 2 {
+		def = def[2:]
+	}
+	switch {
+	case def[0].is("primary") && len(def) > 2:
+		for _, col := range constraintColumns(def) {
+			if c, _ := t.column(col); c != nil {
+				c.PrimaryKey = true
+			}
+		}
+	case def[0].is("unique"):
+		if cols := constraintColumns(def); len(cols) == 1 {
+			if c, _ := t.column(cols[0]); c != nil {
+				c.Unique = true
+			}
+		}
+	case def[0].is("key", "index", "foreign", "check", "fulltext", "spatial", "exclude"):
+	default:
+		t.Columns = append(t.Columns, parseColumn(def))
+	}
+}
+
+// constraintColumns 约束括号中的列名
+func constraintColumns(def []ddlToken) []string {
+	for i, tok := range def {
+		if tok.text == "(" && !tok.quoted && !tok.str {
+			var cols []string
+			for _, part := range splitDDL(def[i+1:parenEnd(def, i)], ",") {
+				if len(part) > 0 {
+					cols = append(cols, part[0].text)
+				}
+			}
+			return cols
+		}
+	}
+	return nil
+}
+
+// 字段类型之后的约束关键字
+var columnConstraints = map[string]bool{
+	"not": true, "null": true, "default": true, "primary": true, "unique": true, "auto_increment": true, "autoincrement": true,
+	"comment": true, "references": true, "check": true, "collate": true, "generated": true, "constraint": true,
+	"character": true, "charset": true, "on": true, "key": true, "identity": true, "as": true,
+}
+
+func parseColumn(def []ddlToken) *columnDef {
+	c := &columnDef{Name: def[0].text}
+	i := 1
+	// 类型由连续的单词和括号参数组成，如 double precision、timestamp(3) with time zone、decimal(10, 2) unsigned
+	start := i
+	for i < len(def) {
+		tok := def[i]
+		if tok.text == "(" && !tok.quoted && !tok.str {
+			i = parenEnd(def, i) + 1
+			continue
+		}
+		if tok.quoted || tok.str || columnConstraints[strings.ToLower(tok.text)] && !(tok.is("character") && i == start) || !isDDLWord(tok.text[0]) {
+			break
+		}
+		i++
+	}
+	parseColumnType(c, def[start:i])
+	for ; i < len(def); i++ {
+		tok := def[i]
+		switch {
+		case tok.is("not") && i+1 < len(def) && def[i+1].is("null"):
+			c.NotNull = true
+			i++
+		case tok.is("primary"):
+			c.PrimaryKey = true
+		case tok.is("unique"):
+			c.Unique = true
+		case tok.is("auto_increment", "autoincrement", "identity"):
+			c.AutoIncrement = true
+		case tok.is("comment") && i+1 < len(def) && def[i+1].str:
+			c.Comment = def[i+1].text
+			i++
+		case tok.is("default") && i+1 < len(def):
+			c.Default, i = defaultValue(def, i+1)
+		}
+	}
+	if strings.HasSuffix(c.Base, "serial") {
+		c.AutoIncrement = true
+	}
+	return c
+}
+
+// defaultValue DEFAULT 后的表达式，返回最后一个 token 的位置
+func defaultValue(def []ddlToken, i int) (string, int) {
+	tok := def[i]
+	switch {
+	case tok.str:
+		if tok.text == "" {
+			return "''", i
+		}
+		return tok.text, i
+	case tok.text == "(":
+		end := parenEnd(def, i)
+		return joinDDL(def[i : end+1]), end
+	case tok.text == "-" && i+1 < len(def):
+		return "-" + def[i+1].text, i + 1
+	case tok.is("null"):
+		return "", i
+	}
+	// 函数调用，如 CURRENT_TIMESTAMP(3)、now()
+	if i+1 < len(def) && def[i+1].text == "(" {
+		end := parenEnd(def, i+1)
+		return joinDDL(def[i : end+1]), end
+	}
+	return tok.text, i
+}
+
+func joinDDL(tokens []ddlToken) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 && t.text != ")" && t.text != "(" && tokens[i-1].text != "(" && t.text != "," {
+			b.WriteByte(' ')
+		}
+		switch {
+		case t.str:
+			b.WriteString("'" + strings.ReplaceAll(t.text, "'", "''") + "'")
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// parseColumnType 得到原始类型、基础类型、长度和是否无符号
+func parseColumnType(c *columnDef, tokens []ddlToken) {
+	var words []string
+	var args []int
+	c.Unsigned = false
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.text == "(" {
+			end := parenEnd(tokens, i)
+			if args == nil {
+				for _, arg := range splitDDL(tokens[i+1:end], ",") {
+					n := 0
+					if len(arg) > 0 {
+						n, _ = strconv.Atoi(arg[0].text)
+					}
+					args = append(args, n)
+				}
+			}
+			i = end
+			continue
+		}
+		if tok.is("unsigned") {
+			c.Unsigned = true
+			continue
+		}
+		if tok.is("zerofill") {
+			continue
+		}
+		words = append(words, strings.ToLower(tok.text))
+	}
+	c.Base = strings.Join(words, " ")
+	switch c.Base {
+	case "character varying":
+		c.Base = "varchar"
+	case "character":
+		c.Base = "char"
+	}
+	// timestamp(3)、int(11) 等的参数不是长度
+	c.Size, c.Precision, c.Scale = 0, 0, 0
+	switch c.Base {
+	case "char", "varchar", "nchar", "nvarchar", "tinyint":
+		if len(args) > 0 {
+			c.Size = args[0]
+		}
+	case "decimal", "numeric":
+		if len(args) > 0 {
+			c.Precision = args[0]
+		}
+		if len(args) > 1 {
+			c.Scale = args[1]
+		}
+	}
+}
+
+func (s *ddlSchema) alter(stmt []ddlToken) error {
+	i := 2
+	if i+1 < len(stmt) && stmt[i].is("if") && stmt[i+1].is("exists") {
+		i += 2
+	}
+	if i < len(stmt) && stmt[i].is("only") {
+		i++
+	}
+	if i >= len(stmt) {
+		return fmt.Errorf("缺少表名")
+	}
+	name, i := tableName(stmt, i)
+	t, _ := s.table(name)
+	if t == nil {
+		fmt.Println("忽略未知表的修改：" + name)
+		return nil
+	}
+	for _, action := range splitDDL(stmt[i:], ",") {
+		if len(action) == 0 {
+			continue
+		}
+		s.alterAction(t, action)
+	}
+	return nil
+}
+
+func skipColumnKeyword(action []ddlToken, i int) int {
+	if i < len(action) && action[i].is("column") {
+		i++
+	}
+	if i+2 < len(action) && action[i].is("if") && action[i+1].is("not") && action[i+2].is("exists") {
+		i += 3
+	} else if i+1 < len(action) && action[i].is("if") && action[i+1].is("exists") {
+		i += 2
+	}
+	return i
+}
+
+func (s *ddlSchema) alterAction(t *tableDef, action []ddlToken) {
+	switch {
+	case action[0].is("add") && len(action) > 1:
+		if action[1].is("constraint", "primary", "unique", "key", "index", "foreign", "check", "fulltext", "spatial") {
+			t.addDefinition(action[1:])
+			return
+		}
+		if i := skipColumnKeyword(action, 1); i < len(action) {
+			if c, _ := t.column(action[i].text); c == nil {
+				t.Columns = append(t.Columns, parseColumn(action[i:]))
+			}
+		}
+	case action[0].is("drop") && len(action) > 1:
+		if action[1].is("constraint", "primary", "index", "key", "foreign", "check") {
+			return
+		}
+		if i := skipColumnKeyword(action, 1); i < len(action) {
+			if _, idx := t.column(action[i].text); idx >= 0 {
+				t.Columns = append(t.Columns[:idx], t.Columns[idx+1:]...)
+			}
+		}
+	case action[0].is("modify") && len(action) > 1:
+		if i := skipColumnKeyword(action, 1); i < len(action) {
+			if _, idx := t.column(action[i].text); idx >= 0 {
+				t.Columns[idx] = parseColumn(action[i:])
+			}
+		}
+	case action[0].is("change") && len(action) > 2:
+		if i := skipColumnKeyword(action, 1); i+1 < len(action) {
+			if _, idx := t.column(action[i].text); idx >= 0 {
+				t.Columns[idx] = parseColumn(action[i+1:])
+			}
+		}
+	case action[0].is("rename") && len(action) > 2:
+		if action[1].is("to", "as") {
+			to, _ := tableName(action, 2)
+			s.rename(t.Name, to)
+			return
+		}
+		i := skipColumnKeyword(action, 1)
+		if i+2 < len(action) && action[i+1].is("to") {
+			if c, _ := t.column(action[i].text); c != nil {
+				c.Name = action[i+2].text
+			}
+		}
+	case action[0].is("alter") && len(action) > 2:
+		// PostgreSQL：ALTER COLUMN x TYPE/SET NOT NULL/DROP NOT NULL/SET DEFAULT/DROP DEFAULT
+		i := skipColumnKeyword(action, 1)
+		c, _ := t.column(action[i].text)
+		if c == nil || i+1 >= len(action) {
+			return
+		}
+		rest := action[i+1:]
+		switch {
+		case rest[0].is("type") || rest[0].is("set") && len(rest) > 2 && rest[1].is("data"):
+			j := 1
+			if rest[0].is("set") {
+				j = 3
+			}
+			end := j
+			for end < len(rest) && !rest[end].is("using", "collate") {
+				end++
+			}
+			parseColumnType(c, rest[j:end])
+		case rest[0].is("set") && len(rest) > 2 && rest[1].is("not"):
+			c.NotNull = true
+		case rest[0].is("drop") && len(rest) > 2 && rest[1].is("not"):
+			c.NotNull = false
+		case rest[0].is("set") && len(rest) > 2 && rest[1].is("default"):
+			c.Default, _ = defaultValue(rest, 2)
+		case rest[0].is("drop") && len(rest) > 1 && rest[1].is("default"):
+			c.Default = ""
+		}
+	case action[0].is("comment") && len(action) > 1:
+		j := 1
+		if action[j].text == "=" && j+1 < len(action) {
+			j++
+		}
+		if action[j].str {
+			t.Comment = action[j].text
+		}
+	}
+}
+
+func (s *ddlSchema) drop(tokens []ddlToken) {
+	i := 0
+	if i+1 < len(tokens) && tokens[i].is("if") && tokens[i+1].is("exists") {
+		i += 2
+	}
+	for _, part := range splitDDL(tokens[i:], ",") {
+		if len(part) == 0 {
+			continue
+		}
+		name, _ := tableName(part, 0)
+		if _, idx := s.table(name); idx >= 0 {
+			s.tables = append(s.tables[:idx], s.tables[idx+1:]...)
+		}
+	}
+}
+
+func (s *ddlSchema) rename(from, to string) {
+	if t, _ := s.table(from); t != nil {
+		t.Name = to
+	}
+}
+
+// comment PostgreSQL：COMMENT ON TABLE t IS '...'、COMMENT ON COLUMN t.c IS '...'
+func (s *ddlSchema) comment(stmt []ddlToken) {
+	is := -1
+	for i, tok := range stmt {
+		if tok.is("is") {
+			is = i
+		}
+	}
+	if is < 0 || is+1 >= len(stmt) {
+		return
+	}
+	text := stmt[is+1].text
+	if stmt[is+1].is("null") {
+		text = ""
+	}
+	// 把 a.b.c 拆成名称列表
+	var names []string
+	for _, tok := range stmt[3:is] {
+		if tok.quoted {
+			names = append(names, tok.text)
+		} else {
+			// "order".paid 中的 .paid 是一个标识符，拆分后去掉空的部分
+			for _, name := range strings.Split(tok.text, ".") {
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	switch {
+	case stmt[2].is("table") && len(names) > 0:
+		if t, _ := s.table(names[len(names)-1]); t != nil {
+			t.Comment = text
+		}
+	case stmt[2].is("column") && len(names) > 1:
+		if t, _ := s.table(names[len(names)-2]); t != nil {
+			if c, _ := t.column(names[len(names)-1]); c != nil {
+				c.Comment = text
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"go/format"
+	"strings"
+	"testing"
+)
+
+// describeTable 每个字段一行：名称、Go 类型、gorm 标签、注释
+func describeTable(t *tableDef) []string {
+	lines := []string{t.Name + " " + t.Comment}
+	for _, c := range t.Columns {
+		lines = append(lines, c.Name+" "+c.goType()+" "+c.gormTag()+" "+c.Comment)
+	}
+	return lines
+}
+
+func TestDDLSchemaApply(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want map[string][]string
+	}{
+		{
+			name: "mysql create",
+			sql: "CREATE TABLE `user` (\n" +
+				"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+				"  `name` varchar(64) NOT NULL COMMENT '姓名',\n" +
+				"  `status` tinyint(1) NOT NULL DEFAULT '1' COMMENT '状态 dict:user_status',\n" +
+				"  `amount` decimal(10,2) DEFAULT NULL, -- 金额\n" +
+				"  `created_at` datetime(3) DEFAULT CURRENT_TIMESTAMP(3),\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  UNIQUE KEY `uk_name` (`name`)\n" +
+				") ENGINE=InnoDB COMMENT='用户';",
+			want: map[string][]string{"user": {
+				"user 用户",
+				"id uint64 column:id;primaryKey;autoIncrement ",
+				"name string column:name;size:64;not null;unique 姓名",
+				"status bool column:status;not null;default:true 状态 dict:user_status",
+				"amount *float64 column:amount;precision:10;scale:2 ",
+				"created_at *time.Time column:created_at;default:(-) ",
+			}},
+		},
+		{
+			name: "postgres create and comment on quoted table",
+			sql: `CREATE TABLE IF NOT EXISTS public."order" (
+				id bigserial PRIMARY KEY,
+				paid boolean NOT NULL DEFAULT false,
+				note character varying(200),
+				created_at timestamp with time zone NOT NULL DEFAULT now()
+			);
+			COMMENT ON TABLE "order" IS '订单';
+			COMMENT ON COLUMN "order".paid IS '已支付 dict:yes_no';
+			COMMENT ON COLUMN public."order".note IS 'it''s 备注';
+			COMMENT ON COLUMN "public"."order"."created_at" IS '创建时间';`,
+			want: map[string][]string{"order": {
+				"order 订单",
+				"id int64 column:id;primaryKey;autoIncrement ",
+				"paid bool column:paid;not null;default:false 已支付 dict:yes_no",
+				"note *string column:note;size:200 it's 备注",
+				"created_at time.Time column:created_at;not null;default:(-) 创建时间",
+			}},
+		},
+		{
+			name: "mysql alter",
+			sql: "CREATE TABLE t (id int NOT NULL PRIMARY KEY, a varchar(10), b int, c int);\n" +
+				"ALTER TABLE t ADD COLUMN d varchar(20) NOT NULL DEFAULT '' COMMENT 'd', MODIFY a varchar(32) NOT NULL, " +
+				"CHANGE b b2 bigint, DROP COLUMN c, COMMENT = '表 t';\n" +
+				"RENAME TABLE t TO t2;",
+			want: map[string][]string{"t2": {
+				"t2 表 t",
+				"id int32 column:id;primaryKey ",
+				"a string column:a;size:32;not null ",
+				"b2 *int64 column:b2 ",
+				"d string column:d;size:20;not null;default:'' d",
+			}},
+		},
+		{
+			name: "postgres alter column",
+			sql: `CREATE TABLE "t" (id integer PRIMARY KEY, a varchar(10) DEFAULT 'x', b text NOT NULL);
+			ALTER TABLE ONLY public.t ALTER COLUMN a TYPE numeric(12, 4) USING a::numeric, ALTER COLUMN a SET NOT NULL,
+				ALTER COLUMN a DROP DEFAULT, ALTER COLUMN b DROP NOT NULL, RENAME COLUMN b TO c;
+			ALTER TABLE t RENAME TO t3;
+			DROP TABLE IF EXISTS missing;`,
+			want: map[string][]string{"t3": {
+				"t3 ",
+				"id int32 column:id;primaryKey ",
+				"a float64 column:a;precision:12;scale:4;not null ",
+				"c *string column:c ",
+			}},
+		},
+		{
+			name: "drop and unsupported statements",
+			sql: "CREATE TABLE a (id int); CREATE TABLE b (id int); CREATE INDEX idx ON a (id);" +
+				"DROP TABLE IF EXISTS \"main\".a; CREATE TABLE c AS SELECT * FROM b; INSERT INTO b VALUES (1);",
+			want: map[string][]string{"b": {"b ", "id *int32 column:id "}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &ddlSchema{}
+			if err := s.apply(tc.sql); err != nil {
+				t.Fatal(err)
+			}
+			if len(s.tables) != len(tc.want) {
+				t.Fatalf("tables: %d, want %d", len(s.tables), len(tc.want))
+			}
+			for _, table := range s.tables {
+				want, ok := tc.want[table.Name]
+				if !ok {
+					t.Fatalf("unexpected table %q", table.Name)
+				}
+				if got := describeTable(table); strings.Join(got, "\n") != strings.Join(want, "\n") {
+					t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestSplitDict(t *testing.T) {
+	cases := []struct {
+		comment, text, dict string
+	}{
+		{"已支付 dict:yes_no", "已支付", "yes_no"},
+		{"状态(dict:user.status)，默认正常", "状态 ，默认正常", "user.status"},
+		{"类型（dict：order-type）", "类型", "order-type"},
+		{"DICT: gender 性别", "性别", "gender"},
+		{"  普通注释 ", "普通注释", ""},
+		{"", "", ""},
+	}
+	for _, tc := range cases {
+		text, dict := splitDict(tc.comment)
+		if text != tc.text || dict != tc.dict {
+			t.Errorf("%q: got (%q, %q), want (%q, %q)", tc.comment, text, dict, tc.text, tc.dict)
+		}
+	}
+}
+
+func TestColumnTags(t *testing.T) {
+	cases := []struct {
+		name     string
+		column   columnDef
+		goType   string
+		gorm     string
+		validate string
+	}{
+		{"required string", columnDef{Name: "name", Base: "varchar", Size: 64, NotNull: true}, "string", "column:name;size:64;not null", "required,max=64"},
+		{"nullable string", columnDef{Name: "note", Base: "varchar", Size: 200}, "*string", "column:note;size:200", "omitempty,max=200"},
+		{"string with default", columnDef{Name: "code", Base: "char", Size: 2, NotNull: true, Default: "CN"}, "string", "column:code;size:2;not null;default:CN", "omitempty,max=2"},
+		{"tinyint(1) as bool", columnDef{Name: "enabled", Base: "tinyint", Size: 1, NotNull: true, Default: "0"}, "bool", "column:enabled;not null;default:false", ""},
+		{"tinyint", columnDef{Name: "level", Base: "tinyint", Size: 4, NotNull: true}, "int8", "column:level;not null", ""},
+		{"unsigned int", columnDef{Name: "count", Base: "int", Unsigned: true, NotNull: true}, "uint32", "column:count;not null", ""},
+		{"decimal", columnDef{Name: "price", Base: "decimal", Precision: 10, Scale: 2, NotNull: true}, "float64", "column:price;precision:10;scale:2;not null", ""},
+		{"required time", columnDef{Name: "paid_at", Base: "timestamp with time zone", NotNull: true}, "time.Time", "column:paid_at;not null", "required"},
+		{"time with function default", columnDef{Name: "created_at", Base: "datetime", NotNull: true, Default: "CURRENT_TIMESTAMP"}, "time.Time", "column:created_at;not null;default:(-)", ""},
+		{"expression default", columnDef{Name: "uid", Base: "uuid", NotNull: true, Default: "(gen_random_uuid())"}, "string", "column:uid;not null;default:(-)", ""},
+		{"bytes", columnDef{Name: "data", Base: "bytea"}, "[]byte", "column:data", ""},
+		{"primary key", columnDef{Name: "id", Base: "bigserial", PrimaryKey: true, AutoIncrement: true}, "int64", "column:id;primaryKey;autoIncrement", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			goType := tc.column.goType()
+			if goType != tc.goType {
+				t.Errorf("goType: %q, want %q", goType, tc.goType)
+			}
+			if got := tc.column.gormTag(); got != tc.gorm {
+				t.Errorf("gorm: %q, want %q", got, tc.gorm)
+			}
+			if got := tc.column.validateTag(goType); got != tc.validate {
+				t.Errorf("validate: %q, want %q", got, tc.validate)
+			}
+		})
+	}
+}
+
+func TestRenderModel(t *testing.T) {
+	s := &ddlSchema{}
+	err := s.apply(`CREATE TABLE sys_user (
+		id bigint NOT NULL PRIMARY KEY, created_at timestamp, updated_at timestamp, deleted_at timestamp,
+		created_by bigint, updated_by bigint, user_name varchar(32) NOT NULL, gender smallint);
+		COMMENT ON TABLE sys_user IS '系统用户';
+		COMMENT ON COLUMN sys_user.gender IS '性别 dict:gender';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := renderModel(s.tables[0], "model")
+	if _, err := format.Source([]byte(src)); err != nil {
+		t.Fatalf("invalid source: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"package model",
+		"// SysUser 系统用户\ntype SysUser struct {\n\tfast_db.AuditModel\n",
+		"UserName string `json:\"userName\" gorm:\"column:user_name;size:32;not null\" validate:\"required,max=32\"`",
+		"\t// 性别\n\tGender *int16 `json:\"gender\" gorm:\"column:gender\" jsonDict:\"gender\"`",
+		"func (SysUser) TableName() string {\n\treturn \"sys_user\"\n}",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "CreatedBy") || strings.Contains(src, `"time"`) {
+		t.Errorf("audit columns should come from fast_db.AuditModel:\n%s", src)
+	}
+}
+
+func TestRenderModelExplicitFields(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "auto increment id",
+			sql: "CREATE TABLE `log` (`id` bigint NOT NULL AUTO_INCREMENT PRIMARY KEY, `created_at` datetime, `updated_at` datetime, " +
+				"`deleted_at` datetime, `created_by` bigint, `updated_by` bigint);",
+			want: []string{
+				"\tId int64 `json:\"id\" gorm:\"column:id;primaryKey;autoIncrement\"`\n",
+				"\tCreatedAt *time.Time `json:\"createdAt\" gorm:\"column:created_at\"`\n",
+				"\tDeletedAt *time.Time `json:\"deletedAt\" gorm:\"column:deleted_at\"`\n",
+				"\tCreatedBy *int64 `json:\"createdBy\" gorm:\"column:created_by\"`\n",
+			},
+		},
+		{
+			name: "varchar id",
+			sql:  "CREATE TABLE log (id varchar(32) NOT NULL PRIMARY KEY, created_at timestamp, updated_at timestamp, deleted_at timestamp);",
+			want: []string{"\tId string `json:\"id\" gorm:\"column:id;size:32;primaryKey\" validate:\"omitempty,max=32\"`\n"},
+		},
+		{
+			name: "text timestamps",
+			sql:  "CREATE TABLE log (id bigint NOT NULL PRIMARY KEY, created_at varchar(32), updated_at timestamp, deleted_at timestamp);",
+			want: []string{"\tId int64 `json:\"id\" gorm:\"column:id;primaryKey\"`\n", "\tCreatedAt *string "},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &ddlSchema{}
+			if err := s.apply(tc.sql); err != nil {
+				t.Fatal(err)
+			}
+			src := renderModel(s.tables[0], "model")
+			if _, err := format.Source([]byte(src)); err != nil {
+				t.Fatalf("invalid source: %v\n%s", err, src)
+			}
+			if strings.Contains(src, "fast_db") {
+				t.Errorf("fast_db.Model should not be embedded:\n%s", src)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(src, want) {
+					t.Errorf("missing %q in:\n%s", want, src)
+				}
+			}
+		})
+	}
+}
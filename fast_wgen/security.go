@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 接口认证、权限与中间件注解：
+//   - @auth none|token|password|apikey   生成 fast_web.AuthMiddleware，声明后 LoadLimitByToken 等前缀过滤不再处理该接口
+//   - @perm code1,code2                  生成 fast_web.PermMiddleware，要求拥有全部权限码；未写 @auth 时按 token 认证
+//   - @middleware name                   生成 fast_web.Middleware(name)，可写多行或逗号分隔，按书写顺序执行
+//   - @group name                        生成 fast_web.GroupHandlers，在认证、权限之后执行 fast_web.RegisterGroupMiddleware 注册的中间件
+// 所有 @perm 汇总为权限清单 JSON，用于初始化 RBAC 权限表。
+
+var authModes = []string{"none", "token", "password", "apikey"}
+
+// findRouteSecurity 解析接口注释中的认证、权限、中间件和分组注解
+func findRouteSecurity(list []*ast.Comment, r *RouteProperties) error {
+	for _, comment := range list {
+		fields := strings.Fields(strings.TrimSpace(strings.TrimLeft(comment.Text, "/")))
+		if len(fields) == 0 {
+			continue
+		}
+		attribute := strings.ToLower(fields[0])
+		values := fields[1:]
+		switch attribute {
+		case "@summary":
+			r.Summary = strings.Join(values, " ")
+		case "@auth":
+			if len(values) != 1 {
+				return fmt.Errorf("@auth 需要一个认证方式(%s)", strings.Join(authModes, "|"))
+			}
+			mode := strings.ToLower(values[0])
+			if !containsStr(authModes, mode) {
+				return fmt.Errorf("@auth 不支持 %q，可选 %s", values[0], strings.Join(authModes, "|"))
+			}
+			if r.Auth != "" && r.Auth != mode {
+				return fmt.Errorf("@auth 重复声明: %s、%s", r.Auth, mode)
+			}
+			r.Auth = mode
+		case "@perm":
+			codes := splitNames(strings.Join(values, ","))
+			if len(codes) == 0 {
+				return fmt.Errorf("@perm 需要至少一个权限码")
+			}
+			for _, code := range codes {
+				if !containsStr(r.Perms, code) {
+					r.Perms = append(r.Perms, code)
+				}
+			}
+		case "@middleware":
+			names := splitNames(strings.Join(values, ","))
+			if len(names) == 0 {
+				return fmt.Errorf("@middleware 需要中间件名称")
+			}
+			r.Middlewares = append(r.Middlewares, names...)
+		case "@group":
+			if len(values) != 1 {
+				return fmt.Errorf("@group 需要一个分组名")
+			}
+			if r.Group != "" && r.Group != values[0] {
+				return fmt.Errorf("@group 重复声明: %s、%s", r.Group, values[0])
+			}
+			r.Group = values[0]
+		}
+	}
+	if len(r.Perms) > 0 {
+		if r.Auth == "" {
+			r.Auth = "token"
+		} else if r.Auth != "token" {
+			return fmt.Errorf("@perm 需要 token 认证，不能与 @auth %s 同时使用", r.Auth)
+		}
+	}
+	return nil
+}
+
+func containsStr(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// routeHandlers 注册接口时路径之后的参数，顺序为认证、权限、分组中间件、限流、数据权限、自定义中间件、处理函数
+func routeHandlers(r *RouteProperties) string {
+	var security, handlers []string
+	if r.Auth != "" {
+		security = append(security, fmt.Sprintf("fast_web.AuthMiddleware(%q, %q, %q)", r.Auth, strings.ToUpper(r.HTTPMethod), r.Path))
+	}
+	if len(r.Perms) > 0 {
+		quoted := make([]string, len(r.Perms))
+		for i, code := range r.Perms {
+			quoted[i] = fmt.Sprintf("%q", code)
+		}
+		security = append(security, fmt.Sprintf("fast_web.PermMiddleware(%s)", strings.Join(quoted, ", ")))
+	}
+	if len(r.Limit.Name) > 0 {
+		handlers = append(handlers, r.Limit.Name)
+	} else if len(r.Limit.Num) > 0 && len(r.Limit.Cap) > 0 {
+		handlers = append(handlers, fmt.Sprintf("fast_web.RateLimitMiddleware(%s, %s)", r.Limit.Num, r.Limit.Cap))
+	}
+	if len(r.DataScope) > 0 {
+		handlers = append(handlers, fmt.Sprintf("fast_web.DataScopeMiddleware(%q)", r.DataScope))
+	}
+	for _, name := range r.Middlewares {
+		handlers = append(handlers, fmt.Sprintf("fast_web.Middleware(%q)", name))
+	}
+	handlers = append(handlers, r.Handler)
+	if len(r.Group) == 0 {
+		return strings.Join(append(security, handlers...), ", ")
+	}
+	// 分组中间件插在认证、权限之后
+	chain := "nil"
+	if len(security) > 0 {
+		chain = "fast_web.HandlerChain(" + strings.Join(security, ", ") + ")"
+	}
+	return fmt.Sprintf("fast_web.GroupHandlers(%q, %s, %s)...", r.Group, chain, strings.Join(handlers, ", "))
+}
+
+// PermissionManifest 权限清单，按分组和权限码排序
+type PermissionManifest struct {
+	Permissions []PermissionItem `json:"permissions"`
+}
+
+type PermissionItem struct {
+	Code   string            `json:"code"`
+	Group  string            `json:"group,omitempty"`
+	Name   string            `json:"name,omitempty"` // 第一个接口的 @Summary
+	Routes []PermissionRoute `json:"routes"`
+}
+
+type PermissionRoute struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// makePermissionManifest 汇总所有接口的 @perm，同一权限码的多个接口合并到一项
+func makePermissionManifest(routers []*RouteProperties) PermissionManifest {
+	items := map[string]*PermissionItem{}
+	for _, r := range routers {
+		handler := r.PackageName + "." + IfStr(r.Receiver == "", "", r.Receiver+".") + r.MethodName
+		for _, code := range r.Perms {
+			item, ok := items[code]
+			if !ok {
+				item = &PermissionItem{Code: code, Group: r.Group, Name: r.Summary}
+				items[code] = item
+			}
+			item.Routes = append(item.Routes, PermissionRoute{Method: strings.ToUpper(r.HTTPMethod), Path: r.Path, Handler: handler})
+		}
+	}
+	manifest := PermissionManifest{Permissions: make([]PermissionItem, 0, len(items))}
+	for _, item := range items {
+		manifest.Permissions = append(manifest.Permissions, *item)
+	}
+	sort.Slice(manifest.Permissions, func(i, j int) bool {
+		a, b := manifest.Permissions[i], manifest.Permissions[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Code < b.Code
+	})
+	return manifest
+}
+
+// writePermissionManifest 有权限码或清单已存在时写出，避免删除全部 @perm 后留下过期清单
+func writePermissionManifest(path string, routers []*RouteProperties) error {
+	manifest := makePermissionManifest(routers)
+	if len(manifest.Permissions) == 0 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println("【阶段3】生成权限清单：" + path)
+	return writeGeneratedFile(path, append(content, '\n'))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteHandlers(t *testing.T) {
+	cases := []struct {
+		name  string
+		route RouteProperties
+		want  string
+	}{
+		{
+			name:  "handler only",
+			route: RouteProperties{HTTPMethod: "get", Path: "/a", Handler: "A.Get"},
+			want:  `A.Get`,
+		},
+		{
+			name: "all middlewares in order",
+			route: RouteProperties{HTTPMethod: "post", Path: "/a", Handler: "A.Post", Auth: "token", Perms: []string{"a:add", "a:edit"},
+				Limit: Limit{Num: "10", Cap: "20"}, DataScope: "dept", Middlewares: []string{"audit", "trace"}},
+			want: `fast_web.AuthMiddleware("token", "POST", "/a"), fast_web.PermMiddleware("a:add", "a:edit"), fast_web.RateLimitMiddleware(10, 20), ` +
+				`fast_web.DataScopeMiddleware("dept"), fast_web.Middleware("audit"), fast_web.Middleware("trace"), A.Post`,
+		},
+		{
+			name:  "named limit",
+			route: RouteProperties{HTTPMethod: "get", Path: "/a", Handler: "A.Get", Limit: Limit{Name: "limitA", Num: "1", Cap: "1"}},
+			want:  `limitA, A.Get`,
+		},
+		{
+			name:  "group after auth and perm",
+			route: RouteProperties{HTTPMethod: "get", Path: "/a", Handler: "A.Get", Group: "system", Auth: "token", Perms: []string{"a:list"}, Middlewares: []string{"audit"}},
+			want:  `fast_web.GroupHandlers("system", fast_web.HandlerChain(fast_web.AuthMiddleware("token", "GET", "/a"), fast_web.PermMiddleware("a:list")), fast_web.Middleware("audit"), A.Get)...`,
+		},
+		{
+			name:  "group without auth",
+			route: RouteProperties{HTTPMethod: "get", Path: "/a", Handler: "A.Get", Group: "open"},
+			want:  `fast_web.GroupHandlers("open", nil, A.Get)...`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := routeHandlers(&tc.route); got != tc.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func comments(lines ...string) []*ast.Comment {
+	list := make([]*ast.Comment, len(lines))
+	for i, line := range lines {
+		list[i] = &ast.Comment{Text: line}
+	}
+	return list
+}
+
+func TestFindRouteSecurity(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+		want  RouteProperties
+		err   string
+	}{
+		{
+			name:  "no annotations",
+			lines: []string{"// GetUser 查询用户", "// @Router /user/:id [get]"},
+		},
+		{
+			name:  "auth is case insensitive",
+			lines: []string{"// @Auth ApiKey", "//@auth apikey"},
+			want:  RouteProperties{Auth: "apikey"},
+		},
+		{
+			name:  "perm defaults to token auth and merges codes",
+			lines: []string{"// @Summary 修改 用户", "// @perm sys:user:edit, sys:user:query", "// @perm sys:user:edit,sys:user:audit"},
+			want:  RouteProperties{Summary: "修改 用户", Auth: "token", Perms: []string{"sys:user:edit", "sys:user:query", "sys:user:audit"}},
+		},
+		{
+			name:  "perm with token auth",
+			lines: []string{"// @auth token", "// @perm a:b"},
+			want:  RouteProperties{Auth: "token", Perms: []string{"a:b"}},
+		},
+		{
+			name:  "middlewares keep order",
+			lines: []string{"// @middleware audit", "// @middleware trace, cache"},
+			want:  RouteProperties{Middlewares: []string{"audit", "trace", "cache"}},
+		},
+		{
+			name:  "same group twice",
+			lines: []string{"// @group system", "// @group system"},
+			want:  RouteProperties{Group: "system"},
+		},
+		{name: "perm with apikey auth", lines: []string{"// @auth apikey", "// @perm a:b"}, err: "@perm 需要 token 认证，不能与 @auth apikey 同时使用"},
+		{name: "perm with password auth", lines: []string{"// @perm a:b", "// @auth password"}, err: "不能与 @auth password 同时使用"},
+		{name: "duplicate group", lines: []string{"// @group system", "// @group report"}, err: "@group 重复声明: system、report"},
+		{name: "group without name", lines: []string{"// @group"}, err: "@group 需要一个分组名"},
+		{name: "group with spaces", lines: []string{"// @group sys admin"}, err: "@group 需要一个分组名"},
+		{name: "unknown auth", lines: []string{"// @auth basic"}, err: `@auth 不支持 "basic"`},
+		{name: "auth without mode", lines: []string{"// @auth"}, err: "@auth 需要一个认证方式"},
+		{name: "conflicting auth", lines: []string{"// @auth none", "// @auth token"}, err: "@auth 重复声明: none、token"},
+		{name: "empty perm", lines: []string{"// @perm ,"}, err: "@perm 需要至少一个权限码"},
+		{name: "empty middleware", lines: []string{"// @middleware"}, err: "@middleware 需要中间件名称"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var r RouteProperties
+			err := findRouteSecurity(comments(tc.lines...), &r)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error: %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r, tc.want) {
+				t.Fatalf("got %+v, want %+v", r, tc.want)
+			}
+		})
+	}
+}
+
+func TestMakePermissionManifest(t *testing.T) {
+	routers := []*RouteProperties{
+		{HTTPMethod: "get", Path: "/user/:id", PackageName: "user", MethodName: "GetUser", Summary: "查询用户", Group: "system", Perms: []string{"sys:user:query"}},
+		{HTTPMethod: "post", Path: "/user/page", PackageName: "user", Receiver: "UserApi", MethodName: "Page", Summary: "分页查询", Group: "system", Perms: []string{"sys:user:query", "sys:user:list"}},
+		{HTTPMethod: "get", Path: "/report", PackageName: "report", MethodName: "Export", Summary: "导出", Perms: []string{"report:export"}},
+		{HTTPMethod: "get", Path: "/health", PackageName: "base", MethodName: "Health"},
+	}
+	want := PermissionManifest{Permissions: []PermissionItem{
+		{Code: "report:export", Name: "导出", Routes: []PermissionRoute{{Method: "GET", Path: "/report", Handler: "report.Export"}}},
+		{Code: "sys:user:list", Group: "system", Name: "分页查询", Routes: []PermissionRoute{{Method: "POST", Path: "/user/page", Handler: "user.UserApi.Page"}}},
+		{Code: "sys:user:query", Group: "system", Name: "查询用户", Routes: []PermissionRoute{
+			{Method: "GET", Path: "/user/:id", Handler: "user.GetUser"},
+			{Method: "POST", Path: "/user/page", Handler: "user.UserApi.Page"},
+		}},
+	}}
+	if got := makePermissionManifest(routers); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	if got := makePermissionManifest(routers[3:]); got.Permissions == nil || len(got.Permissions) != 0 {
+		t.Fatalf("no perms should give an empty list: %#v", got)
+	}
+}
+
+func TestWritePermissionManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	// 没有权限码且清单不存在时不写出
+	if err := writePermissionManifest(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("manifest should not be created: %v", err)
+	}
+	routers := []*RouteProperties{{HTTPMethod: "get", Path: "/a", PackageName: "a", MethodName: "Get", Perms: []string{"a:get"}}}
+	if err := writePermissionManifest(path, routers); err != nil {
+		t.Fatal(err)
+	}
+	var manifest PermissionManifest
+	content, _ := os.ReadFile(path)
+	if err := json.Unmarshal(content, &manifest); err != nil || len(manifest.Permissions) != 1 || manifest.Permissions[0].Code != "a:get" {
+		t.Fatalf("manifest: %v %s", err, content)
+	}
+	// 删除全部 @perm 后清空已存在的清单
+	if err := writePermissionManifest(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = os.ReadFile(path)
+	if strings.TrimSpace(string(content)) != "{\n  \"permissions\": []\n}" {
+		t.Fatalf("stale manifest: %s", content)
+	}
+}